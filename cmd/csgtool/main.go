@@ -0,0 +1,326 @@
+// Command csgtool performs boolean operations on meshes, builds convex hulls and converts meshes
+// between formats.
+//
+// Usage:
+//
+//	csgtool union -o out.stl a.stl b.stl ...
+//	csgtool subtract -o out.stl part.stl fixture.stl ...
+//	csgtool intersect -o out.stl a.stl b.stl ...
+//	csgtool hull -o out.stl a.stl ...
+//	csgtool convert -o out.3mf in.stl
+//	csgtool info a.stl ...
+//	csgtool validate a.stl ...
+//
+// Meshes are read from STL and PLY files, and written to STL, PLY, OBJ, OFF, VTK, 3MF and GLB
+// files, the format is chosen by the extension of the file.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/celer/csg/csg"
+	"github.com/celer/csg/qhull"
+)
+
+// command is a subcommand of the tool
+type command struct {
+	usage string
+	run   func(ctx context.Context, args []string, stdout io.Writer) error
+}
+
+// commands are registered in init as their flag sets refer back to their usage
+var commands map[string]*command
+
+func init() {
+	commands = map[string]*command{
+		"union":     {"union [flags] -o out in1 in2 ...\n\tunion all of the meshes", booleanCommand("union")},
+		"subtract":  {"subtract [flags] -o out in1 in2 ...\n\tsubtract the rest of the meshes from the first", booleanCommand("subtract")},
+		"intersect": {"intersect [flags] -o out in1 in2 ...\n\tintersect all of the meshes", booleanCommand("intersect")},
+		"hull":      {"hull -o out in1 ...\n\tbuild the convex hull of all of the meshes", hullCommand},
+		"convert":   {"convert [flags] -o out in\n\tconvert a mesh to another format", convertCommand},
+		"info":      {"info in1 ...\n\tprint the bounding box, polygon count and volume of each mesh", infoCommand},
+		"validate":  {"validate in1 ...\n\tcheck each mesh is closed, manifold and encloses a positive volume", validateCommand},
+	}
+}
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	if err := run(ctx, os.Args[1:], os.Stdout); err == flag.ErrHelp {
+		os.Exit(2)
+	} else if err != nil {
+		fmt.Fprintf(os.Stderr, "csgtool: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(ctx context.Context, args []string, stdout io.Writer) error {
+	if len(args) == 0 {
+		return fmt.Errorf("no command given\n%s", usage())
+	}
+	cmd, ok := commands[args[0]]
+	if !ok {
+		return fmt.Errorf("unknown command %q\n%s", args[0], usage())
+	}
+	return cmd.run(ctx, args[1:], stdout)
+}
+
+func usage() string {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	lines := []string{"usage: csgtool <command> [flags] files...", "commands:"}
+	for _, name := range names {
+		lines = append(lines, "  "+strings.Replace(commands[name].usage, "\n", "\n  ", -1))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// outputFlags are the flags used by commands which write a mesh
+type outputFlags struct {
+	output string
+	ascii  bool
+}
+
+func (o *outputFlags) register(flags *flag.FlagSet) {
+	flags.StringVar(&o.output, "o", "", "output file, the format is chosen by the extension")
+	flags.BoolVar(&o.ascii, "ascii", false, "write STL and PLY files as text rather than binary")
+}
+
+func newFlagSet(name string) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "usage: csgtool %s\n", commands[name].usage)
+		flags.PrintDefaults()
+	}
+	return flags
+}
+
+func booleanCommand(name string) func(ctx context.Context, args []string, stdout io.Writer) error {
+	return func(ctx context.Context, args []string, stdout io.Writer) error {
+		flags := newFlagSet(name)
+		out := &outputFlags{}
+		out.register(flags)
+		robust := flags.Bool("robust", false, "use exact predicates to split polygons, for coincident faces or very large or small models")
+		tolerance := flags.Float64("tolerance", 0, "distance within which a point is on a plane (default 1e-5)")
+		autoTolerance := flags.Bool("auto-tolerance", false, "scale the tolerance to the size of the meshes")
+		concurrency := flags.Int("j", 0, "maximum number of goroutines to use (default the number of CPUs)")
+		if err := flags.Parse(args); err != nil {
+			return err
+		}
+		if flags.NArg() < 2 {
+			return fmt.Errorf("%s needs at least 2 meshes", name)
+		}
+		meshes, err := readMeshes(flags.Args())
+		if err != nil {
+			return err
+		}
+
+		options := &csg.Options{Tolerance: *tolerance, AutoTolerance: *autoTolerance, MaxConcurrency: *concurrency}
+		if *robust {
+			options.Splitter = &csg.RobustPolygonSplitter{}
+		}
+		var result *csg.CSG
+		switch name {
+		case "union":
+			result, err = options.UnionAll(ctx, meshes)
+		case "subtract":
+			result, err = options.SubtractAll(ctx, meshes[0], meshes[1:])
+		case "intersect":
+			result, err = options.IntersectAll(ctx, meshes)
+		}
+		if err != nil {
+			return err
+		}
+		return writeMesh(out, result)
+	}
+}
+
+func hullCommand(ctx context.Context, args []string, stdout io.Writer) error {
+	flags := newFlagSet("hull")
+	out := &outputFlags{}
+	out.register(flags)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() < 1 {
+		return fmt.Errorf("hull needs at least 1 mesh")
+	}
+	meshes, err := readMeshes(flags.Args())
+	if err != nil {
+		return err
+	}
+	points := make([]*csg.Vector, 0)
+	for _, m := range meshes {
+		for _, p := range m.ToPolygons() {
+			for _, v := range p.Vertices {
+				points = append(points, v.Position)
+			}
+		}
+	}
+	h := &qhull.Hull{}
+	if err := h.BuildCtx(ctx, points, len(points)); err != nil {
+		return err
+	}
+	return writeMesh(out, h.ToCSG())
+}
+
+func convertCommand(ctx context.Context, args []string, stdout io.Writer) error {
+	flags := newFlagSet("convert")
+	out := &outputFlags{}
+	out.register(flags)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return fmt.Errorf("convert needs exactly 1 mesh")
+	}
+	mesh, err := readMesh(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	return writeMesh(out, mesh)
+}
+
+func infoCommand(ctx context.Context, args []string, stdout io.Writer) error {
+	flags := newFlagSet("info")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	for _, name := range flags.Args() {
+		mesh, err := readMesh(name)
+		if err != nil {
+			return err
+		}
+		triangles := 0
+		for _, p := range mesh.ToPolygons() {
+			triangles += len(p.Vertices) - 2
+		}
+		b := mesh.BoundingBox()
+		size := b.Size()
+		fmt.Fprintf(stdout, "%s:\n", name)
+		fmt.Fprintf(stdout, "  polygons:  %d\n", len(mesh.ToPolygons()))
+		fmt.Fprintf(stdout, "  triangles: %d\n", triangles)
+		fmt.Fprintf(stdout, "  min:       %g %g %g\n", b.Min.X, b.Min.Y, b.Min.Z)
+		fmt.Fprintf(stdout, "  max:       %g %g %g\n", b.Max.X, b.Max.Y, b.Max.Z)
+		fmt.Fprintf(stdout, "  size:      %g %g %g\n", size.X, size.Y, size.Z)
+		fmt.Fprintf(stdout, "  volume:    %g\n", mesh.Volume())
+	}
+	return nil
+}
+
+func validateCommand(ctx context.Context, args []string, stdout io.Writer) error {
+	flags := newFlagSet("validate")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	invalid := 0
+	for _, name := range flags.Args() {
+		mesh, err := readMesh(name)
+		if err != nil {
+			return err
+		}
+		if err := mesh.Validate(); err != nil {
+			invalid++
+			fmt.Fprintf(stdout, "%s: %v\n", name, err)
+		} else {
+			fmt.Fprintf(stdout, "%s: ok\n", name)
+		}
+	}
+	if invalid > 0 {
+		return fmt.Errorf("%d of %d meshes are invalid", invalid, flags.NArg())
+	}
+	return nil
+}
+
+func readMeshes(names []string) ([]*csg.CSG, error) {
+	meshes := make([]*csg.CSG, len(names))
+	for i, name := range names {
+		mesh, err := readMesh(name)
+		if err != nil {
+			return nil, err
+		}
+		meshes[i] = mesh
+	}
+	return meshes, nil
+}
+
+func readMesh(name string) (*csg.CSG, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var mesh *csg.CSG
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".stl":
+		mesh, err = csg.NewCSGFromSTL(f)
+	case ".ply":
+		mesh, err = csg.NewCSGFromPLY(f)
+	default:
+		return nil, fmt.Errorf("%s: unsupported input format, expected .stl or .ply", name)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+	return mesh, nil
+}
+
+func writeMesh(o *outputFlags, mesh *csg.CSG) error {
+	if o.output == "" {
+		return fmt.Errorf("no output file given, use -o")
+	}
+	ext := strings.ToLower(filepath.Ext(o.output))
+	switch ext {
+	case ".stl", ".ply", ".obj", ".off", ".vtk", ".3mf", ".glb":
+	default:
+		return fmt.Errorf("%s: unsupported output format, expected .stl, .ply, .obj, .off, .vtk, .3mf or .glb", o.output)
+	}
+
+	f, err := os.Create(o.output)
+	if err != nil {
+		return err
+	}
+	switch ext {
+	case ".stl":
+		if o.ascii {
+			mesh.MarshalToASCIISTL(f)
+		} else {
+			err = mesh.MarshalToBinarySTL(f)
+		}
+	case ".ply":
+		format := csg.PLY_BINARY_LITTLE_ENDIAN
+		if o.ascii {
+			format = csg.PLY_ASCII
+		}
+		err = mesh.MarshalToPLY(f, format)
+	case ".obj":
+		err = mesh.MarshalToOBJ(f, nil, "")
+	case ".off":
+		err = mesh.MarshalToOFF(f)
+	case ".vtk":
+		err = mesh.MarshalToVTK(f, filepath.Base(o.output))
+	case ".3mf":
+		err = mesh.MarshalToThreeMF(f, csg.THREEMF_MILLIMETER)
+	case ".glb":
+		err = mesh.MarshalToGLB(f)
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %v", o.output, err)
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/celer/csg/csg"
+)
+
+func writeSTL(t *testing.T, name string, c *csg.CSG) {
+	f, err := os.Create(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err := c.MarshalToBinarySTL(f); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCommands(t *testing.T) {
+	dir := t.TempDir()
+	part := filepath.Join(dir, "part.stl")
+	fixture := filepath.Join(dir, "fixture.stl")
+	writeSTL(t, part, csg.NewCube(&csg.CubeOptions{Size: &csg.Vector{X: 2, Y: 2, Z: 2}}))
+	writeSTL(t, fixture, csg.NewCube(&csg.CubeOptions{Center: &csg.Vector{X: 1, Y: 1, Z: 1}}))
+
+	ctx := context.Background()
+	result := filepath.Join(dir, "result.ply")
+	if err := run(ctx, []string{"subtract", "-o", result, part, fixture}, &bytes.Buffer{}); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	if err := run(ctx, []string{"validate", result}, &out); err != nil {
+		t.Fatalf("%v: %s", err, out.String())
+	}
+	out.Reset()
+	if err := run(ctx, []string{"info", result}, &out); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "volume:    7.875\n") {
+		t.Errorf("Expected a volume of 7.875 got:\n%s", out.String())
+	}
+
+	converted := filepath.Join(dir, "hull.stl")
+	if err := run(ctx, []string{"hull", "-o", converted, result}, &bytes.Buffer{}); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(converted)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	hull, err := csg.NewCSGFromSTL(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the hull cuts a tetrahedron off of the cube where the corner was removed
+	if expected := 8 - 0.125/6; math.Abs(hull.Volume()-expected) > 1e-5 {
+		t.Errorf("Expected the hull to have a volume of %f got %f", expected, hull.Volume())
+	}
+
+	for _, args := range [][]string{
+		{},
+		{"bogus"},
+		{"union", "-o", filepath.Join(dir, "out.stl"), part},
+		{"convert", "-o", filepath.Join(dir, "out.xyz"), part},
+		{"convert", "-o", filepath.Join(dir, "out.stl"), filepath.Join(dir, "missing.stl")},
+	} {
+		if err := run(ctx, args, &bytes.Buffer{}); err == nil {
+			t.Errorf("Expected an error for %v", args)
+		}
+	}
+}
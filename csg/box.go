@@ -92,6 +92,27 @@ func (b *Box) Contains(v *Vector) bool {
 	return false
 }
 
+// Intersects returns true if this bounding box overlaps or touches another bounding box (given the EPSILON value used)
+func (b *Box) Intersects(o *Box) bool {
+	return b.intersectsWithin(o, EPSILON)
+}
+
+// intersectsWithin returns true if this bounding box overlaps or is within epsilon of another bounding box
+func (b *Box) intersectsWithin(o *Box, epsilon float64) bool {
+	return b.Min.X <= o.Max.X+epsilon && o.Min.X <= b.Max.X+epsilon &&
+		b.Min.Y <= o.Max.Y+epsilon && o.Min.Y <= b.Max.Y+epsilon &&
+		b.Min.Z <= o.Max.Z+epsilon && o.Min.Z <= b.Max.Z+epsilon
+}
+
+// Intersection returns the bounding box covering the overlap of this bounding box and another, the
+// result is only meaningful if the boxes intersect
+func (b *Box) Intersection(o *Box) *Box {
+	r := &Box{Min: b.Min, Max: b.Max}
+	r.Min.Max(&o.Min)
+	r.Max.Min(&o.Max)
+	return r
+}
+
 //Corners returns an slice of the vectors (as points) of the corners of the bounding box
 func (b *Box) Corners() []*Vector {
 	size := b.Size()
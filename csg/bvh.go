@@ -0,0 +1,254 @@
+package csg
+
+import (
+	"math"
+	"sort"
+)
+
+// bvhTriangle is a triangle of a polygon stored in a bvh
+type bvhTriangle struct {
+	a, b, c  *Vector
+	polygon  *Polygon
+	box      Box
+	centroid Vector
+}
+
+// closest returns the point on the triangle closest to p
+func (t *bvhTriangle) closest(p *Vector) *Vector {
+	ab, ac, ap := t.b.Minus(t.a), t.c.Minus(t.a), p.Minus(t.a)
+	d1, d2 := ab.Dot(ap), ac.Dot(ap)
+	if d1 <= 0 && d2 <= 0 {
+		return t.a
+	}
+	bp := p.Minus(t.b)
+	d3, d4 := ab.Dot(bp), ac.Dot(bp)
+	if d3 >= 0 && d4 <= d3 {
+		return t.b
+	}
+	vc := d1*d4 - d3*d2
+	if vc <= 0 && d1 >= 0 && d3 <= 0 {
+		return t.a.Plus(ab.Times(d1 / (d1 - d3)))
+	}
+	cp := p.Minus(t.c)
+	d5, d6 := ab.Dot(cp), ac.Dot(cp)
+	if d6 >= 0 && d5 <= d6 {
+		return t.c
+	}
+	vb := d5*d2 - d1*d6
+	if vb <= 0 && d2 >= 0 && d6 <= 0 {
+		return t.a.Plus(ac.Times(d2 / (d2 - d6)))
+	}
+	va := d3*d6 - d5*d4
+	if va <= 0 && d4-d3 >= 0 && d5-d6 >= 0 {
+		return t.b.Plus(t.c.Minus(t.b).Times((d4 - d3) / ((d4 - d3) + (d5 - d6))))
+	}
+	denom := 1 / (va + vb + vc)
+	return t.a.Plus(ab.Times(vb * denom)).Plus(ac.Times(vc * denom))
+}
+
+// bvhNode is a node of a bounding volume hierarchy, leaves have triangles and no children
+type bvhNode struct {
+	box         Box
+	left, right *bvhNode
+	triangles   []*bvhTriangle
+}
+
+// bvhLeafSize is the most triangles stored in a leaf
+const bvhLeafSize = 4
+
+// bvh is a bounding volume hierarchy over the triangles of a set of polygons, used to answer
+// closest point and ray queries
+type bvh struct {
+	root *bvhNode
+}
+
+func newBVH(polygons []*Polygon) *bvh {
+	triangles := make([]*bvhTriangle, 0, len(polygons))
+	for _, p := range polygons {
+		for _, t := range p.Triangles() {
+			bt := &bvhTriangle{a: t.Vertices[0].Position, b: t.Vertices[1].Position, c: t.Vertices[2].Position, polygon: p}
+			bt.box = Box{Min: *bt.a, Max: *bt.a}
+			bt.box.AddVector(bt.b)
+			bt.box.AddVector(bt.c)
+			bt.centroid = *bt.a.Plus(bt.b).Plus(bt.c).DividedBy(3)
+			triangles = append(triangles, bt)
+		}
+	}
+	if len(triangles) == 0 {
+		return &bvh{}
+	}
+	return &bvh{root: buildBVH(triangles)}
+}
+
+// buildBVH builds a node for the triangles, splitting them at the median centroid along the
+// longest axis of the centroids' bounds
+func buildBVH(triangles []*bvhTriangle) *bvhNode {
+	n := &bvhNode{box: triangles[0].box}
+	centroids := Box{Min: triangles[0].centroid, Max: triangles[0].centroid}
+	for _, t := range triangles[1:] {
+		n.box.AddVector(&t.box.Min)
+		n.box.AddVector(&t.box.Max)
+		centroids.AddVector(&t.centroid)
+	}
+	if len(triangles) <= bvhLeafSize {
+		n.triangles = triangles
+		return n
+	}
+	size := centroids.Size()
+	axis := 0
+	if size.Y > size.Get(axis) {
+		axis = 1
+	}
+	if size.Z > size.Get(axis) {
+		axis = 2
+	}
+	sort.Slice(triangles, func(i, j int) bool {
+		return triangles[i].centroid.Get(axis) < triangles[j].centroid.Get(axis)
+	})
+	mid := len(triangles) / 2
+	n.left = buildBVH(triangles[:mid])
+	n.right = buildBVH(triangles[mid:])
+	return n
+}
+
+// boxDistanceSquared returns the squared distance from p to the closest point of the box
+func boxDistanceSquared(b *Box, p *Vector) float64 {
+	d := 0.0
+	for i := 0; i < 3; i++ {
+		v := p.Get(i)
+		if l := b.Min.Get(i); v < l {
+			d += (l - v) * (l - v)
+		} else if u := b.Max.Get(i); v > u {
+			d += (v - u) * (v - u)
+		}
+	}
+	return d
+}
+
+// bvhHit is the result of a closest point query
+type bvhHit struct {
+	point           *Vector
+	triangle        *bvhTriangle
+	distanceSquared float64
+}
+
+// closest finds the point on the triangles closest to p, the hit has a nil triangle if the bvh
+// is empty
+func (b *bvh) closest(p *Vector) bvhHit {
+	hit := bvhHit{distanceSquared: math.Inf(1)}
+	if b.root != nil {
+		b.root.closest(p, &hit)
+	}
+	return hit
+}
+
+func (n *bvhNode) closest(p *Vector, hit *bvhHit) {
+	if n.triangles != nil {
+		for _, t := range n.triangles {
+			if boxDistanceSquared(&t.box, p) >= hit.distanceSquared {
+				continue
+			}
+			c := t.closest(p)
+			if d := c.Minus(p).LengthSquared(); d < hit.distanceSquared {
+				hit.point, hit.triangle, hit.distanceSquared = c, t, d
+			}
+		}
+		return
+	}
+	// visit the nearer child first so the farther one is more likely to be pruned
+	first, second := n.left, n.right
+	df, ds := boxDistanceSquared(&first.box, p), boxDistanceSquared(&second.box, p)
+	if ds < df {
+		first, second, df, ds = second, first, ds, df
+	}
+	if df < hit.distanceSquared {
+		first.closest(p, hit)
+	}
+	if ds < hit.distanceSquared {
+		second.closest(p, hit)
+	}
+}
+
+// crossedBy checks if the ray from o along d passes through the triangle
+func (t *bvhTriangle) crossedBy(o, d *Vector) bool {
+	e1, e2 := t.b.Minus(t.a), t.c.Minus(t.a)
+	h := d.Cross(e2)
+	det := e1.Dot(h)
+	if det == 0 {
+		return false
+	}
+	f := 1 / det
+	s := o.Minus(t.a)
+	u := f * s.Dot(h)
+	if u < 0 || u > 1 {
+		return false
+	}
+	q := s.Cross(e1)
+	v := f * d.Dot(q)
+	if v < 0 || u+v > 1 {
+		return false
+	}
+	return f*e2.Dot(q) > 0
+}
+
+// rayHitsBox checks if the ray from o with the inverse direction inv passes through the box
+func rayHitsBox(b *Box, o, inv *Vector) bool {
+	near, far := 0.0, math.Inf(1)
+	for i := 0; i < 3; i++ {
+		t1 := (b.Min.Get(i) - o.Get(i)) * inv.Get(i)
+		t2 := (b.Max.Get(i) - o.Get(i)) * inv.Get(i)
+		if t1 > t2 {
+			t1, t2 = t2, t1
+		}
+		near, far = math.Max(near, t1), math.Min(far, t2)
+		if near > far {
+			return false
+		}
+	}
+	return true
+}
+
+// crossings counts the triangles crossed by the ray from o along d, which must not be parallel
+// to an axis
+func (b *bvh) crossings(o, d *Vector) int {
+	if b.root == nil {
+		return 0
+	}
+	return b.root.crossings(o, d, &Vector{1 / d.X, 1 / d.Y, 1 / d.Z})
+}
+
+func (n *bvhNode) crossings(o, d, inv *Vector) int {
+	if !rayHitsBox(&n.box, o, inv) {
+		return 0
+	}
+	if n.triangles != nil {
+		count := 0
+		for _, t := range n.triangles {
+			if t.crossedBy(o, d) {
+				count++
+			}
+		}
+		return count
+	}
+	return n.left.crossings(o, d, inv) + n.right.crossings(o, d, inv)
+}
+
+// insideRays are the directions of the rays cast to find if a point is inside, they're skewed
+// so they're unlikely to pass exactly through the edges of axis aligned meshes
+var insideRays = []*Vector{
+	{0.6147, 0.7052, 0.3531},
+	{-0.4231, 0.5317, -0.7337},
+	{0.2891, -0.8114, 0.5079},
+}
+
+// inside checks if p is inside the closed mesh by casting rays from it and counting the
+// crossings, the majority of the rays decide in case one passes through an edge
+func (b *bvh) inside(p *Vector) bool {
+	votes := 0
+	for _, d := range insideRays {
+		if b.crossings(p, d)%2 == 1 {
+			votes++
+		}
+	}
+	return votes*2 > len(insideRays)
+}
@@ -1,8 +1,10 @@
 package csg
 
 import (
+	"context"
 	"fmt"
 	"io"
+	"sync"
 )
 
 // CSG is a mesh which represents some constructive solid geometry, it's made up of polygons and
@@ -12,6 +14,9 @@ import (
 // For a more comprehensive discussion of the algorithm see: https://github.com/evanw/csg.js/blob/master/csg.js:w
 type CSG struct {
 	polygons []*Polygon
+	// tree is built by the first distance query
+	treeOnce sync.Once
+	tree     *bvh
 }
 
 // NewCSGFromPolygons constructs a new CSG from a slice of polygons
@@ -24,12 +29,29 @@ func NewCSGFromPolygons(polygons []*Polygon) *CSG {
 // BoundingBox returns the bounding box for the CSG
 func (c *CSG) BoundingBox() *Box {
 	b := &Box{}
+	if len(c.polygons) > 0 && len(c.polygons[0].Vertices) > 0 {
+		// start from a point in the mesh, otherwise the box would always include the origin
+		b.Min.CopyFrom(c.polygons[0].Vertices[0].Position)
+		b.Max.CopyFrom(c.polygons[0].Vertices[0].Position)
+	}
 	for _, p := range c.polygons {
 		b.AddPolygon(p)
 	}
 	return b
 }
 
+// Volume returns the volume enclosed by the CSG, which is only meaningful if the mesh is closed
+func (c *CSG) Volume() float64 {
+	v := 0.0
+	for _, p := range c.polygons {
+		for _, t := range p.Triangles() {
+			a := t.Vertices[0].Position
+			v += a.Dot(t.Vertices[1].Position.Cross(t.Vertices[2].Position))
+		}
+	}
+	return v / 6.0
+}
+
 // Clone copies this CSG into a new CSG
 func (c *CSG) Clone() *CSG {
 	n := &CSG{}
@@ -38,11 +60,53 @@ func (c *CSG) Clone() *CSG {
 	return n
 }
 
+// deepClone copies this CSG and all of it's polygons and vertices into a new CSG
+func (c *CSG) deepClone() *CSG {
+	n := &CSG{}
+	n.polygons = make([]*Polygon, len(c.polygons))
+	for i, p := range c.polygons {
+		n.polygons[i] = p.clone()
+	}
+	return n
+}
+
+// SetShared sets the shared metadata of every polygon in this CSG, which is carried through booleans
+// to the polygons of the result
+func (c *CSG) SetShared(shared *Shared) {
+	for _, p := range c.polygons {
+		p.Shared = shared
+	}
+}
+
 // ToPolygons returns the list of polygons constituting this CSG
 func (c *CSG) ToPolygons() []*Polygon {
 	return c.polygons
 }
 
+// Transform returns a new CSG with all of the polygons transformed by the matrix
+func (c *CSG) Transform(m *Matrix) *CSG {
+	polygons := make([]*Polygon, len(c.polygons))
+	for i, p := range c.polygons {
+		polygons[i] = p.Transform(m)
+	}
+	return NewCSGFromPolygons(polygons)
+}
+
+// Translate returns a new CSG translated by the vector
+func (c *CSG) Translate(v *Vector) *CSG {
+	return c.Transform(NewTranslationMatrix(v))
+}
+
+// Scale returns a new CSG scaled along each axis by the components of the vector
+func (c *CSG) Scale(v *Vector) *CSG {
+	return c.Transform(NewScalingMatrix(v))
+}
+
+// Rotate returns a new CSG rotated around the axis by the angle (in degrees)
+func (c *CSG) Rotate(axis *Vector, degrees float64) *CSG {
+	return c.Transform(NewRotationMatrix(axis, degrees))
+}
+
 // MarshalToASCIISTL writes out this CSG object to an ASCII STL representation
 func (c *CSG) MarshalToASCIISTL(out io.Writer) {
 	fmt.Fprintf(out, "solid %s\n", "name")
@@ -55,57 +119,211 @@ func (c *CSG) MarshalToASCIISTL(out io.Writer) {
 	fmt.Fprintf(out, "endsolid %s\n", "name")
 }
 
+// operands is the polygons of two CSGs split by whether they could possibly interact with the
+// other CSG. Only polygons whose bounding box touches the bounding box of the other CSG need
+// to be clipped, everything else is outside of the other CSG and can be passed through
+// untouched.
+type operands struct {
+	// overlap is true if the bounding boxes of both CSGs overlap
+	overlap bool
+
+	a *CSG
+	b *CSG
+
+	aInside  []*Polygon
+	aOutside []*Polygon
+	bInside  []*Polygon
+	bOutside []*Polygon
+}
+
+func newOperands(op *operation, a, b *CSG) *operands {
+	o := &operands{a: a, b: b}
+	if len(a.polygons) == 0 || len(b.polygons) == 0 {
+		o.aOutside = a.polygons
+		o.bOutside = b.polygons
+		return o
+	}
+
+	aBox := a.BoundingBox()
+	bBox := b.BoundingBox()
+	op.scale(aBox, bBox)
+	epsilon := op.tolerance()
+	if !aBox.intersectsWithin(bBox, epsilon) {
+		o.aOutside = a.polygons
+		o.bOutside = b.polygons
+		return o
+	}
+
+	o.overlap = true
+	o.aInside, o.aOutside = splitByBox(a.polygons, bBox, epsilon)
+	o.bInside, o.bOutside = splitByBox(b.polygons, aBox, epsilon)
+	return o
+}
+
+// trees builds the BSP trees used to classify polygons against each CSG, these are always built
+// from every polygon in the CSG, as polygons far from the overlapping region still determine
+// what is inside or outside of the CSG
+func (o *operands) trees(op *operation) (a *Node, b *Node) {
+	return newNodeFromPolygons(op, o.a.deepClone().polygons), newNodeFromPolygons(op, o.b.deepClone().polygons)
+}
+
+// splitByBox splits the polygons into those whose bounding box is within epsilon of the box and those which don't,
+// the polygons which intersect the box are cloned as they will be modified while clipping
+func splitByBox(polygons []*Polygon, box *Box, epsilon float64) (inside []*Polygon, outside []*Polygon) {
+	for _, p := range polygons {
+		pb := &Box{}
+		pb.Min.CopyFrom(p.Vertices[0].Position)
+		pb.Max.CopyFrom(p.Vertices[0].Position)
+		pb.AddPolygon(p)
+		if box.intersectsWithin(pb, epsilon) {
+			inside = append(inside, p.clone())
+		} else {
+			outside = append(outside, p)
+		}
+	}
+	return inside, outside
+}
+
+// flipPolygons flips each of the polygons in place, returning the same slice
+func flipPolygons(polygons []*Polygon) []*Polygon {
+	for _, p := range polygons {
+		p.Flip()
+	}
+	return polygons
+}
+
+// concatPolygons returns a new slice containing all of the polygons from each of the slices
+func concatPolygons(polygons ...[]*Polygon) []*Polygon {
+	n := 0
+	for _, p := range polygons {
+		n += len(p)
+	}
+	r := make([]*Polygon, 0, n)
+	for _, p := range polygons {
+		r = append(r, p...)
+	}
+	return r
+}
+
 // Union combines this CSG object with another CSG object and returns the newly combined mesh.
+//
+// If the bounding boxes of the two CSGs don't overlap the polygons are simply combined, otherwise
+// only the polygons which touch the bounding box of the other CSG are clipped.
 func (c *CSG) Union(csg *CSG) *CSG {
-	a := NewNodeFromPolygons(c.Clone().polygons)
-	b := NewNodeFromPolygons(csg.Clone().polygons)
+	r, _ := c.UnionCtx(context.Background(), csg)
+	return r
+}
 
-	a.ClipTo(b)
-	b.ClipTo(a)
-	b.Invert()
-	b.ClipTo(a)
-	b.Invert()
-	a.Build(b.AllPolygons())
+// UnionCtx is the same as Union, but will stop and return the context's error if the context is cancelled,
+// and will report progress to any ProgressFunc associated with the context using WithProgress
+func (c *CSG) UnionCtx(ctx context.Context, csg *CSG) (*CSG, error) {
+	return c.union(newOperation(ctx, nil), csg)
+}
+
+func (c *CSG) union(op *operation, csg *CSG) (*CSG, error) {
+	o := newOperands(op, c, csg)
+	if !o.overlap {
+		return NewCSGFromPolygons(concatPolygons(o.aOutside, o.bOutside)), nil
+	}
+
+	a, b := o.trees(op)
+	if op.err != nil {
+		return nil, op.err
+	}
+
+	// remove the parts of each CSG inside the other, the polygons of b are clipped twice
+	// so that coplanar polygons facing the same way are only kept once
+	ap := b.clip(op, o.aInside)
+	bp := a.clip(op, o.bInside)
+	bp = flipPolygons(a.clip(op, flipPolygons(bp)))
+	if op.err != nil {
+		return nil, op.err
+	}
 
-	return NewCSGFromPolygons(a.AllPolygons())
+	return NewCSGFromPolygons(concatPolygons(o.aOutside, o.bOutside, ap, bp)), nil
 }
 
 // Subtract subtracts another CSG object from this object returning the resulting mesh.
+//
+// If the bounding boxes of the two CSGs don't overlap this object is returned unchanged, otherwise
+// only the polygons which touch the bounding box of the other CSG are clipped.
 func (c *CSG) Subtract(csg *CSG) *CSG {
-	a := NewNodeFromPolygons(c.Clone().polygons)
-	b := NewNodeFromPolygons(csg.Clone().polygons)
+	r, _ := c.SubtractCtx(context.Background(), csg)
+	return r
+}
 
+// SubtractCtx is the same as Subtract, but will stop and return the context's error if the context is cancelled,
+// and will report progress to any ProgressFunc associated with the context using WithProgress
+func (c *CSG) SubtractCtx(ctx context.Context, csg *CSG) (*CSG, error) {
+	return c.subtract(newOperation(ctx, nil), csg)
+}
+
+func (c *CSG) subtract(op *operation, csg *CSG) (*CSG, error) {
+	o := newOperands(op, c, csg)
+	if !o.overlap {
+		return NewCSGFromPolygons(concatPolygons(o.aOutside)), nil
+	}
+
+	a, b := o.trees(op)
+	if op.err != nil {
+		return nil, op.err
+	}
 	a.Invert()
-	a.ClipTo(b)
-	b.ClipTo(a)
-	b.Invert()
-	b.ClipTo(a)
-	b.Invert()
-	a.Build(b.AllPolygons())
-	a.Invert()
 
-	return NewCSGFromPolygons(a.AllPolygons())
+	// remove the parts of this CSG inside the other, and the parts of the other
+	// outside of this CSG, which then form the inside of the resulting cavity
+	ap := flipPolygons(b.clip(op, flipPolygons(o.aInside)))
+	bp := a.clip(op, o.bInside)
+	bp = a.clip(op, flipPolygons(bp))
+	if op.err != nil {
+		return nil, op.err
+	}
+
+	return NewCSGFromPolygons(concatPolygons(o.aOutside, ap, bp)), nil
 }
 
 // Intersect returns the intersection of two CSGs
+//
+// If the bounding boxes of the two CSGs don't overlap an empty CSG is returned, otherwise
+// only the polygons which touch the bounding box of the other CSG are clipped.
 func (c *CSG) Intersect(csg *CSG) *CSG {
-	a := NewNodeFromPolygons(c.Clone().polygons)
-	b := NewNodeFromPolygons(csg.Clone().polygons)
+	r, _ := c.IntersectCtx(context.Background(), csg)
+	return r
+}
+
+// IntersectCtx is the same as Intersect, but will stop and return the context's error if the context is cancelled,
+// and will report progress to any ProgressFunc associated with the context using WithProgress
+func (c *CSG) IntersectCtx(ctx context.Context, csg *CSG) (*CSG, error) {
+	return c.intersect(newOperation(ctx, nil), csg)
+}
+
+func (c *CSG) intersect(op *operation, csg *CSG) (*CSG, error) {
+	o := newOperands(op, c, csg)
+	if !o.overlap {
+		return &CSG{}, nil
+	}
 
+	a, b := o.trees(op)
+	if op.err != nil {
+		return nil, op.err
+	}
 	a.Invert()
-	b.ClipTo(a)
 	b.Invert()
-	a.ClipTo(b)
-	b.ClipTo(a)
-	a.Build(b.AllPolygons())
-	a.Invert()
 
-	return NewCSGFromPolygons(a.AllPolygons())
+	// remove the parts of each CSG outside of the other
+	bp := a.clip(op, o.bInside)
+	ap := flipPolygons(b.clip(op, flipPolygons(o.aInside)))
+	bp = flipPolygons(a.clip(op, flipPolygons(bp)))
+	if op.err != nil {
+		return nil, op.err
+	}
+
+	return NewCSGFromPolygons(concatPolygons(ap, bp)), nil
 }
 
 // Inverse clones this CSG and returns a CSG with the normals flipped on all the polygons
 func (c *CSG) Inverse() *CSG {
-	csg := c.Clone()
+	csg := c.deepClone()
 	for _, p := range csg.polygons {
 		p.Flip()
 	}
@@ -1,9 +1,20 @@
 package csg
 
 import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"encoding/xml"
 	"fmt"
+	"image/color"
+	"math"
+	"math/big"
+	"math/rand"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 )
@@ -36,16 +47,6 @@ func BenchmarkSubtraction(b *testing.B) {
 
 }
 
-func TestSubtraction(b *testing.T) {
-	s1 := NewCube(&CubeOptions{Size: &Vector{2, 2, 2}})
-	s2 := NewSphere(&SphereOptions{Center: &Vector{1, 1, 1}, Radius: 1.2, Slices: 15, Stacks: 15})
-
-	c := s1.Subtract(s2)
-
-	SaveCSG(c, "basic_sub.stl")
-
-}
-
 func TestSphereGrid(b *testing.T) {
 	var last *CSG
 	now := time.Now()
@@ -103,3 +104,1125 @@ func TestBasicCube(t *testing.T) {
 	AssertVectorEq(t, bb.Center(), 1, 1, 1)
 
 }
+
+func TestTransform(t *testing.T) {
+	c := NewCube(&CubeOptions{Size: &Vector{2, 2, 2}})
+
+	bb := c.Translate(&Vector{1, 2, 3}).BoundingBox()
+	AssertVectorEq(t, bb.Size(), 2, 2, 2)
+
+	m := NewScalingMatrix(&Vector{-1, 1, 1})
+	for _, p := range c.Transform(m).ToPolygons() {
+		n := NewPlaneFromPoints(p.Vertices[0].Position, p.Vertices[1].Position, p.Vertices[2].Position).Normal
+		if n.Dot(p.Plane.Normal) < 0.99 {
+			t.Fatalf("Expected mirrored polygon to keep facing outwards, got %v and %v", n, p.Plane.Normal)
+		}
+	}
+}
+
+func TestEvaluator(t *testing.T) {
+	sphere := &SphereOptions{Center: &Vector{1, 1, 1}, Radius: 1.2, Slices: 8, Stacks: 8}
+	cube := NewCubeOp(&CubeOptions{Size: &Vector{2, 2, 2}})
+	tool := NewSphereOp(sphere).Union(NewCylinderOp(&CylinderOptions{Radius: 0.5}))
+	op := cube.Subtract(tool).Translate(&Vector{0, 0, 1})
+
+	e := NewEvaluator()
+	c := e.Evaluate(op)
+	if len(c.ToPolygons()) == 0 {
+		t.Fatal("Expected evaluated operation to have polygons")
+	}
+	_, misses := e.Stats()
+	if misses != 6 {
+		t.Fatalf("Expected 6 misses on first evaluation, got %d", misses)
+	}
+
+	e.Evaluate(op)
+	hits, misses := e.Stats()
+	if hits != 1 || misses != 6 {
+		t.Fatalf("Expected the root to be cached, got %d hits and %d misses", hits, misses)
+	}
+
+	// changing the sphere should only re-evaluate the sphere and it's ancestors
+	sphere.Radius = 1.4
+	e.Evaluate(op)
+	hits, misses = e.Stats()
+	if hits != 3 || misses != 10 {
+		t.Fatalf("Expected only the changed branch to be re-evaluated, got %d hits and %d misses", hits, misses)
+	}
+
+	e.Prune(op)
+	if e.Len() != 6 {
+		t.Fatalf("Expected pruning to leave 6 cached results, got %d", e.Len())
+	}
+}
+
+func sphereGrid(n int) []*CSG {
+	spheres := make([]*CSG, 0, n*n)
+	for i := 0; i < n; i++ {
+		for j := 0; j < n; j++ {
+			spheres = append(spheres, NewSphere(&SphereOptions{Center: &Vector{float64(i), float64(j), 0}, Radius: 2.0, Slices: 6, Stacks: 6}))
+		}
+	}
+	return spheres
+}
+
+func AssertAlmostEq(t *testing.T, what string, v, expected, tolerance float64) {
+	if math.Abs(v-expected) > tolerance {
+		t.Fatalf("Expected %s %f to equal %f (+/- %f)", what, v, expected, tolerance)
+	}
+}
+
+func TestUnionAll(t *testing.T) {
+	spheres := sphereGrid(4)
+	all := UnionAll(spheres)
+
+	var last *CSG
+	for _, s := range spheres {
+		if last != nil {
+			last = last.Union(s)
+		} else {
+			last = s
+		}
+	}
+
+	AssertAlmostEq(t, "volume", all.Volume(), last.Volume(), 1e-6)
+
+	// cubes which don't overlap are just concatenated
+	cubes := make([]*CSG, 0)
+	for i := 0; i < 10; i++ {
+		cubes = append(cubes, NewCube(&CubeOptions{Center: &Vector{float64(i) * 2, 0, 0}}))
+	}
+	all = UnionAll(cubes)
+	if len(all.ToPolygons()) != 60 {
+		t.Fatalf("Expected disjoint cubes to be concatenated, got %d polygons", len(all.ToPolygons()))
+	}
+	AssertAlmostEq(t, "volume", all.Volume(), 10, 1e-9)
+}
+
+func TestSubtractAllIntersectAll(t *testing.T) {
+	plate := NewCube(&CubeOptions{Size: &Vector{10, 10, 1}})
+	holes := make([]*CSG, 0)
+	for i := 0; i < 4; i++ {
+		for j := 0; j < 4; j++ {
+			holes = append(holes, NewCube(&CubeOptions{Center: &Vector{float64(i)*2 - 3, float64(j)*2 - 3, 0}, Size: &Vector{1, 1, 2}}))
+		}
+	}
+	// this hole is outside of the plate, and should be ignored
+	holes = append(holes, NewCube(&CubeOptions{Center: &Vector{20, 20, 20}}))
+
+	c := SubtractAll(plate, holes)
+	AssertAlmostEq(t, "volume", c.Volume(), 100-16, 1e-6)
+
+	c = IntersectAll([]*CSG{
+		NewCube(&CubeOptions{Size: &Vector{2, 2, 2}}),
+		NewCube(&CubeOptions{Size: &Vector{2, 2, 2}, Center: &Vector{1, 0, 0}}),
+		NewCube(&CubeOptions{Size: &Vector{2, 2, 2}, Center: &Vector{0, 1, 0}}),
+	})
+	AssertAlmostEq(t, "volume", c.Volume(), 2, 1e-6)
+
+	c = IntersectAll([]*CSG{NewCube(nil), NewCube(&CubeOptions{Center: &Vector{5, 5, 5}})})
+	if len(c.ToPolygons()) != 0 {
+		t.Fatal("Expected intersection of disjoint cubes to be empty")
+	}
+}
+
+func BenchmarkUnionAll(b *testing.B) {
+	spheres := sphereGrid(8)
+	for i := 0; i < b.N; i++ {
+		UnionAll(spheres)
+	}
+}
+
+func TestBooleanFastPaths(t *testing.T) {
+	a := NewCube(&CubeOptions{Size: &Vector{2, 2, 2}})
+	b := NewCube(&CubeOptions{Center: &Vector{5, 0, 0}})
+
+	if len(a.Union(b).ToPolygons()) != 12 {
+		t.Fatal("Expected union of disjoint cubes to be concatenated")
+	}
+	if len(a.Subtract(b).ToPolygons()) != 6 {
+		t.Fatal("Expected subtraction of a disjoint cube to be unchanged")
+	}
+	if len(a.Intersect(b).ToPolygons()) != 0 {
+		t.Fatal("Expected intersection of disjoint cubes to be empty")
+	}
+
+	// a cube well inside of a sphere has no polygons near the sphere's surface
+	s := NewSphere(&SphereOptions{Radius: 10, Slices: 32, Stacks: 16})
+	sv := s.Volume()
+	AssertAlmostEq(t, "volume", s.Union(NewCube(nil)).Volume(), sv, 1e-6)
+	AssertAlmostEq(t, "volume", s.Subtract(NewCube(nil)).Volume(), sv-1, 1e-6)
+	AssertAlmostEq(t, "volume", s.Intersect(NewCube(nil)).Volume(), 1, 1e-6)
+
+	// booleans shouldn't modify their operands
+	c := NewCube(&CubeOptions{Center: &Vector{1, 1, 1}, Size: &Vector{2, 2, 2}})
+	a.Subtract(c)
+	a.Intersect(c)
+	c.Union(a)
+	AssertAlmostEq(t, "volume", a.Volume(), 8, 1e-9)
+	AssertAlmostEq(t, "volume", c.Volume(), 8, 1e-9)
+	AssertAlmostEq(t, "volume", a.Subtract(c).Volume(), 7, 1e-9)
+	AssertAlmostEq(t, "volume", a.Intersect(c).Volume(), 1, 1e-9)
+	AssertAlmostEq(t, "volume", a.Union(c).Volume(), 15, 1e-9)
+}
+
+func TestBooleanCtx(t *testing.T) {
+	s1 := NewSphere(&SphereOptions{Radius: 5.0, Slices: 40, Stacks: 40})
+	s2 := NewSphere(&SphereOptions{Center: &Vector{1, 1, 1}, Radius: 5.0, Slices: 40, Stacks: 40})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	calls := 0
+	ctx = WithProgress(ctx, func(p Progress) {
+		calls++
+		if p.Polygons > 1000 {
+			cancel()
+		}
+	})
+
+	_, err := s1.SubtractCtx(ctx, s2)
+	if err != context.Canceled {
+		t.Fatalf("Expected subtraction to be cancelled, got %v", err)
+	}
+	if calls == 0 {
+		t.Fatal("Expected progress to be reported")
+	}
+
+	c, err := s1.UnionCtx(context.Background(), s2)
+	if err != nil || len(c.ToPolygons()) == 0 {
+		t.Fatalf("Expected union to succeed, got %v", err)
+	}
+}
+
+// The following are the original recursive implementations of the BSP tree operations, which
+// are used to ensure the explicit stack implementations produce the same results, and to
+// benchmark the two approaches against each other
+
+func buildRecursive(n *Node, polygons []*Polygon) {
+	if len(polygons) == 0 {
+		return
+	}
+	if n.plane == nil {
+		n.plane = polygons[0].Plane.Clone()
+	}
+	front := make([]*Polygon, 0)
+	back := make([]*Polygon, 0)
+	n.getPolygonSplitter(&operation{}, len(polygons)).SplitPolygons(n.plane, polygons, &n.polygons, &n.polygons, &front, &back)
+	if len(front) > 0 {
+		if n.front == nil {
+			n.front = &Node{}
+		}
+		buildRecursive(n.front, front)
+	}
+	if len(back) > 0 {
+		if n.back == nil {
+			n.back = &Node{}
+		}
+		buildRecursive(n.back, back)
+	}
+}
+
+func clipPolygonsRecursive(n *Node, splitter IPolygonSplitter, polygons []*Polygon) []*Polygon {
+	if n.plane == nil {
+		return append([]*Polygon{}, polygons...)
+	}
+	front := make([]*Polygon, 0, len(polygons)/5)
+	back := make([]*Polygon, 0, len(polygons)/5)
+	splitter.SplitPolygons(n.plane, polygons, &front, &back, &front, &back)
+	if n.front != nil {
+		front = clipPolygonsRecursive(n.front, splitter, front)
+	}
+	if n.back != nil {
+		back = clipPolygonsRecursive(n.back, splitter, back)
+		return append(front, back...)
+	}
+	return front
+}
+
+func clipToRecursive(n *Node, bsp *Node) {
+	n.polygons = clipPolygonsRecursive(bsp, bsp.getPolygonSplitter(&operation{}, len(n.polygons)), n.polygons)
+	if n.front != nil {
+		clipToRecursive(n.front, bsp)
+	}
+	if n.back != nil {
+		clipToRecursive(n.back, bsp)
+	}
+}
+
+func invertRecursive(n *Node) {
+	for _, p := range n.polygons {
+		p.Flip()
+	}
+	n.plane.Flip()
+	if n.front != nil {
+		invertRecursive(n.front)
+	}
+	if n.back != nil {
+		invertRecursive(n.back)
+	}
+	n.front, n.back = n.back, n.front
+}
+
+func allPolygonsRecursive(n *Node) []*Polygon {
+	polygons := append([]*Polygon{}, n.polygons...)
+	if n.front != nil {
+		polygons = append(polygons, allPolygonsRecursive(n.front)...)
+	}
+	if n.back != nil {
+		polygons = append(polygons, allPolygonsRecursive(n.back)...)
+	}
+	return polygons
+}
+
+func AssertPolygonsEq(t *testing.T, a, b []*Polygon) {
+	if len(a) != len(b) {
+		t.Fatalf("Expected %d polygons to equal %d polygons", len(a), len(b))
+	}
+	for i := range a {
+		if len(a[i].Vertices) != len(b[i].Vertices) {
+			t.Fatalf("Expected polygon %d to have %d vertices, got %d", i, len(b[i].Vertices), len(a[i].Vertices))
+		}
+		for j := range a[i].Vertices {
+			if !a[i].Vertices[j].Position.Equals(b[i].Vertices[j].Position) {
+				t.Fatalf("Expected polygon %d vertex %d %v to equal %v", i, j, a[i].Vertices[j].Position, b[i].Vertices[j].Position)
+			}
+		}
+	}
+}
+
+func TestIterativeNode(t *testing.T) {
+	s1 := NewSphere(&SphereOptions{Radius: 5.0, Slices: 12, Stacks: 12})
+	s2 := NewSphere(&SphereOptions{Center: &Vector{1, 1, 1}, Radius: 5.0, Slices: 12, Stacks: 12})
+
+	a := NewNodeFromPolygons(s1.deepClone().polygons)
+	b := NewNodeFromPolygons(s2.deepClone().polygons)
+	ra := &Node{}
+	buildRecursive(ra, s1.deepClone().polygons)
+	rb := &Node{}
+	buildRecursive(rb, s2.deepClone().polygons)
+	AssertPolygonsEq(t, a.AllPolygons(), allPolygonsRecursive(ra))
+	AssertPolygonsEq(t, a.Clone().AllPolygons(), allPolygonsRecursive(ra))
+
+	AssertPolygonsEq(t, b.ClipPolygons(s1.deepClone().polygons), clipPolygonsRecursive(rb, &BasicPolygonSplitter{}, s1.deepClone().polygons))
+
+	a.ClipTo(b)
+	clipToRecursive(ra, rb)
+	AssertPolygonsEq(t, a.AllPolygons(), allPolygonsRecursive(ra))
+
+	a.Invert()
+	invertRecursive(ra)
+	AssertPolygonsEq(t, a.AllPolygons(), allPolygonsRecursive(ra))
+	AssertPolygonsEq(t, b.ClipPolygons(a.AllPolygons()), clipPolygonsRecursive(rb, &BasicPolygonSplitter{}, allPolygonsRecursive(ra)))
+}
+
+func benchmarkSpheres() (*CSG, *CSG) {
+	s1 := NewSphere(&SphereOptions{Radius: 5.0, Slices: 30, Stacks: 30})
+	s2 := NewSphere(&SphereOptions{Center: &Vector{1, 1, 1}, Radius: 5.0, Slices: 30, Stacks: 30})
+	return s1, s2
+}
+
+func BenchmarkBuildIterative(b *testing.B) {
+	s1, _ := benchmarkSpheres()
+	for i := 0; i < b.N; i++ {
+		NewNodeFromPolygons(s1.polygons)
+	}
+}
+
+func BenchmarkBuildRecursive(b *testing.B) {
+	s1, _ := benchmarkSpheres()
+	for i := 0; i < b.N; i++ {
+		buildRecursive(&Node{}, s1.polygons)
+	}
+}
+
+func BenchmarkClipIterative(b *testing.B) {
+	s1, s2 := benchmarkSpheres()
+	n := NewNodeFromPolygons(s2.deepClone().polygons)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		n.ClipPolygons(s1.polygons)
+	}
+}
+
+func BenchmarkClipRecursive(b *testing.B) {
+	s1, s2 := benchmarkSpheres()
+	n := NewNodeFromPolygons(s2.deepClone().polygons)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		clipPolygonsRecursive(n, n.getPolygonSplitter(&operation{}, len(s1.polygons)), s1.polygons)
+	}
+}
+
+func BenchmarkAllPolygonsIterative(b *testing.B) {
+	s1, _ := benchmarkSpheres()
+	n := NewNodeFromPolygons(s1.polygons)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		n.AllPolygons()
+	}
+}
+
+func BenchmarkAllPolygonsRecursive(b *testing.B) {
+	s1, _ := benchmarkSpheres()
+	n := NewNodeFromPolygons(s1.polygons)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		allPolygonsRecursive(n)
+	}
+}
+
+func TestPlaneSelectors(t *testing.T) {
+	// a mostly axis aligned part, a plate with a grid of square pockets and a round hole
+	plate := NewCube(&CubeOptions{Size: &Vector{10, 10, 1}})
+	pockets := make([]*CSG, 0)
+	for i := 0; i < 4; i++ {
+		for j := 0; j < 4; j++ {
+			pockets = append(pockets, NewCube(&CubeOptions{Center: &Vector{float64(i)*2 - 3, float64(j)*2 - 3, 0.5}, Size: &Vector{1, 1, 1}}))
+		}
+	}
+	part := SubtractAll(plate, pockets)
+	tool := NewCylinder(&CylinderOptions{Start: &Vector{0, 0, -1}, End: &Vector{0, 0, 1}, Radius: 2})
+	expected := part.Subtract(tool).Volume()
+
+	selectors := []PlaneSelector{
+		&FirstPolygonPlaneSelector{},
+		&SampledPlaneSelector{},
+		&AxisAlignedPlaneSelector{},
+	}
+	for _, s := range selectors {
+		stats := &BSPStats{}
+		o := &Options{PlaneSelector: s, Stats: stats}
+		c, err := o.Subtract(context.Background(), part, tool)
+		if err != nil {
+			t.Fatal(err)
+		}
+		AssertAlmostEq(t, "volume", c.Volume(), expected, 1e-6)
+		if stats.Nodes == 0 || stats.Depth == 0 {
+			t.Fatalf("Expected stats to be populated for %T, got %+v", s, stats)
+		}
+		t.Logf("%T %+v", s, *stats)
+	}
+}
+
+// orientationRat computes the sign of orientation using rational arithmetic
+func orientationRat(a, b, c, d *Vector) int {
+	r := func(f float64) *big.Rat { return new(big.Rat).SetFloat64(f) }
+	sub := func(x, y float64) *big.Rat { return new(big.Rat).Sub(r(x), r(y)) }
+	mul := func(x, y *big.Rat) *big.Rat { return new(big.Rat).Mul(x, y) }
+	bx, by, bz := sub(b.X, a.X), sub(b.Y, a.Y), sub(b.Z, a.Z)
+	cx, cy, cz := sub(c.X, a.X), sub(c.Y, a.Y), sub(c.Z, a.Z)
+	dx, dy, dz := sub(d.X, a.X), sub(d.Y, a.Y), sub(d.Z, a.Z)
+	nx := new(big.Rat).Sub(mul(by, cz), mul(bz, cy))
+	ny := new(big.Rat).Sub(mul(bz, cx), mul(bx, cz))
+	nz := new(big.Rat).Sub(mul(bx, cy), mul(by, cx))
+	dot := new(big.Rat).Add(mul(nx, dx), mul(ny, dy))
+	return dot.Add(dot, mul(nz, dz)).Sign()
+}
+
+func sign(f float64) int {
+	if f > 0 {
+		return 1
+	} else if f < 0 {
+		return -1
+	}
+	return 0
+}
+
+func TestOrientation(t *testing.T) {
+	if orientation(&Vector{0, 0, 0}, &Vector{1, 0, 0}, &Vector{0, 1, 0}, &Vector{0, 0, 1}) <= 0 {
+		t.Fatalf("Expected point to be in front of the plane")
+	}
+	if orientation(&Vector{0, 0, 0}, &Vector{1, 0, 0}, &Vector{0, 1, 0}, &Vector{0, 0, -1}) >= 0 {
+		t.Fatalf("Expected point to be behind the plane")
+	}
+
+	// points which are nearly coplanar, with large offsets so the floating point approximation is unreliable
+	rnd := rand.New(rand.NewSource(1))
+	for i := 0; i < 2000; i++ {
+		offset := &Vector{rnd.Float64() * 1e6, rnd.Float64() * 1e6, rnd.Float64() * 1e6}
+		a := offset.Plus(&Vector{rnd.Float64(), rnd.Float64(), rnd.Float64()})
+		b := offset.Plus(&Vector{rnd.Float64(), rnd.Float64(), rnd.Float64()})
+		c := offset.Plus(&Vector{rnd.Float64(), rnd.Float64(), rnd.Float64()})
+		d := a.Lerp(b, rnd.Float64()).Lerp(c, rnd.Float64())
+		if e := orientationRat(a, b, c, d); sign(orientation(a, b, c, d)) != e {
+			t.Fatalf("Expected orientation of %v %v %v %v to have sign %d", a, b, c, d, e)
+		}
+	}
+}
+
+func TestRobustPolygonSplitter(t *testing.T) {
+	o := &Options{Splitter: &RobustPolygonSplitter{}}
+	ctx := context.Background()
+
+	for _, scale := range []float64{1e-7, 1, 1e7} {
+		s := &Vector{scale, scale, scale}
+		offset := &Vector{scale * 1e3, scale * 1e3, 0}
+
+		// sheet metal, a flange welded along a coincident face and a slot which is flush with the top
+		sheet := NewCube(&CubeOptions{Center: &Vector{5, 5, 0.05}, Size: &Vector{10, 10, 0.1}}).Scale(s).Translate(offset)
+		flange := NewCube(&CubeOptions{Center: &Vector{10.05, 5, 2}, Size: &Vector{0.1, 10, 4}}).Scale(s).Translate(offset)
+		slot := NewCube(&CubeOptions{Center: &Vector{5, 5, 0.075}, Size: &Vector{4, 1, 0.05}}).Scale(s).Translate(offset)
+
+		u, err := o.Union(ctx, sheet, flange)
+		if err != nil {
+			t.Fatal(err)
+		}
+		volume := scale * scale * scale
+		AssertAlmostEq(t, fmt.Sprintf("union volume at scale %g", scale), u.Volume()/volume, 14, 1e-6)
+
+		c, err := o.Subtract(ctx, u, slot)
+		if err != nil {
+			t.Fatal(err)
+		}
+		AssertAlmostEq(t, fmt.Sprintf("subtract volume at scale %g", scale), c.Volume()/volume, 13.8, 1e-6)
+
+		c, err = o.Intersect(ctx, u, slot)
+		if err != nil {
+			t.Fatal(err)
+		}
+		AssertAlmostEq(t, fmt.Sprintf("intersect volume at scale %g", scale), c.Volume()/volume, 0.2, 1e-6)
+
+		// the result should be deterministic, the same operation should produce the same polygons
+		d, _ := o.Subtract(ctx, u, slot)
+		e, _ := o.Subtract(ctx, u, slot)
+		AssertPolygonsEq(t, d.ToPolygons(), e.ToPolygons())
+	}
+}
+
+func TestOptionsTolerance(t *testing.T) {
+	ctx := context.Background()
+	s := &Vector{1e-7, 1e-7, 1e-7}
+	a := NewCube(&CubeOptions{Size: &Vector{2, 2, 2}}).Scale(s)
+	b := NewCube(&CubeOptions{Size: &Vector{2, 2, 2}, Center: &Vector{1, 1, 1}}).Scale(s)
+
+	for _, o := range []*Options{{AutoTolerance: true}, {Tolerance: 1e-12}} {
+		c, err := o.Union(ctx, a, b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		AssertAlmostEq(t, "union volume", c.Volume()/1e-21, 15, 1e-6)
+	}
+
+	o := &Options{AutoTolerance: true, MaxConcurrency: 1}
+	spheres := sphereGrid(3)
+	c, err := o.UnionAll(ctx, spheres)
+	if err != nil {
+		t.Fatal(err)
+	}
+	AssertAlmostEq(t, "union all volume", c.Volume(), UnionAll(spheres).Volume(), 1e-6)
+
+	// cubes closer than the tolerance are joined rather than passed through by the bounding box check
+	b = NewCube(&CubeOptions{Size: &Vector{2, 2, 2}, Center: &Vector{2.0005, 0, 0}})
+	c, err = (&Options{Tolerance: 1e-3}).Union(ctx, NewCube(&CubeOptions{Size: &Vector{2, 2, 2}}), b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range c.ToPolygons() {
+		if math.Abs(p.Plane.Normal.X) > 0.5 && math.Abs(p.Vertices[0].Position.X-1) < 1e-2 {
+			t.Fatalf("Expected the faces between the cubes to be removed")
+		}
+	}
+
+	// primitives are checked against the same tolerance
+	tiny := &SphereOptions{Radius: 1e-6}
+	if _, err := (&Options{}).Sphere(tiny); err == nil || !strings.Contains(err.Error(), "no longer than the tolerance") {
+		t.Fatalf("Expected a tiny sphere to be smaller than the default tolerance, got %v", err)
+	}
+	if _, err := (&Options{AutoTolerance: true}).Sphere(tiny); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := (&Options{Tolerance: 0.1}).Cylinder(&CylinderOptions{Slices: 64}); err == nil {
+		t.Fatal("Expected a cylinder with edges shorter than the tolerance to fail")
+	}
+	if _, err := (*Options)(nil).Cube(nil); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestShared(t *testing.T) {
+	stock := &Shared{Material: "aluminium stock", FeatureID: 1}
+	pocket := &Shared{Color: color.RGBA{255, 0, 0, 255}, FeatureID: 2}
+
+	a := NewCube(&CubeOptions{Size: &Vector{4, 4, 2}})
+	a.SetShared(stock)
+	b := NewCylinder(&CylinderOptions{Start: &Vector{0, 0, 0}, End: &Vector{0, 0, 2}}).Translate(&Vector{1, 1, 0})
+	b.SetShared(pocket)
+
+	c := a.Subtract(b).Rotate(&Vector{0, 0, 1}, 45)
+	counts := make(map[*Shared]int)
+	for _, p := range c.ToPolygons() {
+		counts[p.Shared]++
+		if p.Shared == pocket && p.Plane.Normal.Z < -0.5 {
+			t.Fatalf("Expected the pocket floor to face up")
+		}
+	}
+	if counts[stock] == 0 || counts[pocket] == 0 || len(counts) != 2 {
+		t.Fatalf("Expected polygons from both the stock and the pocket, got %v", counts)
+	}
+
+	obj := &bytes.Buffer{}
+	mtl := &bytes.Buffer{}
+	if err := c.MarshalToOBJ(obj, mtl, "part.mtl"); err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{"mtllib part.mtl\n", "usemtl aluminium_stock\n", "usemtl color_ff0000ff\n", "g feature_2\n"} {
+		if !strings.Contains(obj.String(), s) {
+			t.Fatalf("Expected OBJ to contain %q", s)
+		}
+	}
+	for _, s := range []string{"newmtl aluminium_stock\nKd 0.8 0.8 0.8\n", "newmtl color_ff0000ff\nKd 1 0 0\n"} {
+		if !strings.Contains(mtl.String(), s) {
+			t.Fatalf("Expected MTL to contain %q", s)
+		}
+	}
+}
+
+func TestVertexAttributes(t *testing.T) {
+	a := NewCube(nil)
+	for _, p := range a.ToPolygons() {
+		for _, v := range p.Vertices {
+			v.Color = &Color{R: v.Position.Y + 0.5, A: 1}
+			v.Channels = []float64{v.Position.X, v.Position.Z}
+		}
+	}
+	b := NewSphere(&SphereOptions{Center: &Vector{0.2, 0.1, 0.5}, Radius: 0.3})
+
+	c := a.Subtract(b)
+	top := 0
+	for _, p := range c.ToPolygons() {
+		for _, v := range p.Vertices {
+			if v.UV == nil {
+				t.Fatalf("Expected every vertex to have a UV")
+			}
+		}
+		if p.Plane.Normal.Z < 1-EPSILON {
+			continue
+		}
+		// the UVs, colors and channels on the top face are all linear in the position
+		top++
+		for _, v := range p.Vertices {
+			AssertAlmostEq(t, "u", v.UV.U, v.Position.X+0.5, 1e-9)
+			AssertAlmostEq(t, "v", v.UV.V, v.Position.Y+0.5, 1e-9)
+			AssertAlmostEq(t, "red", v.Color.R, v.Position.Y+0.5, 1e-9)
+			AssertAlmostEq(t, "channel 0", v.Channels[0], v.Position.X, 1e-9)
+			AssertAlmostEq(t, "channel 1", v.Channels[1], 0.5, 1e-9)
+		}
+	}
+	if top < 2 {
+		t.Fatalf("Expected the top face to have been split, got %d polygons", top)
+	}
+}
+
+func TestPLY(t *testing.T) {
+	a := NewCube(&CubeOptions{Size: &Vector{2, 2, 2}})
+	a.SetShared(&Shared{Color: color.RGBA{0, 0, 255, 255}})
+	b := NewCylinder(nil)
+	b.ToPolygons()[0].Vertices[1].Color = &Color{R: 1, A: 1}
+	c := a.Subtract(b)
+
+	for _, format := range []PLYFormat{PLY_ASCII, PLY_BINARY_LITTLE_ENDIAN, PLY_BINARY_BIG_ENDIAN} {
+		buf := &bytes.Buffer{}
+		if err := c.MarshalToPLY(buf, format); err != nil {
+			t.Fatal(err)
+		}
+		r, err := NewCSGFromPLY(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		AssertAlmostEq(t, "volume", r.Volume(), c.Volume(), 1e-9)
+		if len(r.ToPolygons()) != len(c.ToPolygons()) {
+			t.Fatalf("Expected %d polygons in %s, got %d", len(c.ToPolygons()), format, len(r.ToPolygons()))
+		}
+		blue := 0
+		for i, p := range r.ToPolygons() {
+			if len(p.Vertices) != len(c.ToPolygons()[i].Vertices) || p.Vertices[0].UV == nil {
+				t.Fatalf("Expected polygon %d to be read in %s with UVs", i, format)
+			}
+			if p.Vertices[0].Color.B == 1 {
+				blue++
+			}
+		}
+		if blue == 0 {
+			t.Fatalf("Expected the colors of the cube to be read in %s", format)
+		}
+	}
+
+	ply := `ply
+format ascii 1.0
+comment a unit square
+element vertex 4
+property float x
+property float y
+property float z
+property uchar red
+property uchar green
+property uchar blue
+element face 1
+property list uchar int vertex_indices
+end_header
+0 0 0 255 0 0
+1 0 0 255 0 0
+1 1 0 255 0 0
+0 1 0 255 0 0
+4 0 1 2 3
+`
+	r, err := NewCSGFromPLY(strings.NewReader(ply))
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := r.ToPolygons()[0]
+	if len(p.Vertices) != 4 || p.Vertices[2].Color.R != 1 || p.Vertices[0].Normal.Z != 1 {
+		t.Fatalf("Expected a red square facing up, got %+v", p.Vertices[0])
+	}
+
+	_, err = NewCSGFromPLY(strings.NewReader(strings.Replace(ply, "4 0 1 2 3", "4 0 1 2 4", 1)))
+	if err == nil || !strings.Contains(err.Error(), "references vertex 4") {
+		t.Fatalf("Expected an error for an invalid vertex index, got %v", err)
+	}
+	_, err = NewCSGFromPLY(strings.NewReader(strings.Replace(ply, "4 0 1 2 3\n", "", 1)))
+	if err == nil {
+		t.Fatal("Expected an error for a truncated file")
+	}
+	for _, count := range []string{"-1", "2.5", "NaN", "Inf", "1e300"} {
+		_, err = NewCSGFromPLY(strings.NewReader(strings.Replace(ply, "4 0 1 2 3", count+" 0 1 2 3", 1)))
+		if err == nil || !strings.Contains(err.Error(), "invalid list length") {
+			t.Fatalf("Expected an error for a list length of %s, got %v", count, err)
+		}
+	}
+
+	// a list claiming billions of items in a short file fails without allocating them
+	binary := "ply\nformat binary_little_endian 1.0\nelement face 1\nproperty list uint int vertex_indices\nend_header\n\xff\xff\xff\x7f\x00\x00\x00\x00"
+	_, err = NewCSGFromPLY(strings.NewReader(binary))
+	if err == nil || !strings.Contains(err.Error(), "unexpected EOF") {
+		t.Fatalf("Expected an error for a truncated list, got %v", err)
+	}
+}
+
+func TestThreeMF(t *testing.T) {
+	a := NewCube(nil)
+	b := NewSphere(nil)
+	b.SetShared(&Shared{Color: color.RGBA{255, 0, 0, 255}})
+	b.ToPolygons()[0].Shared = nil
+
+	buf := &bytes.Buffer{}
+	err := WriteThreeMF(buf, THREEMF_INCH, []*ThreeMFObject{
+		{Name: "cube", Mesh: a},
+		{Name: "sphere", Mesh: b, Transform: NewTranslationMatrix(&Vector{10, 20, 30})},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	z, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := make(map[string]string)
+	for _, f := range z.File {
+		r, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		b := &bytes.Buffer{}
+		b.ReadFrom(r)
+		files[f.Name] = b.String()
+	}
+	if files["[Content_Types].xml"] == "" || files["_rels/.rels"] == "" {
+		t.Fatalf("Expected the package to contain the content types and relationships, got %v", files)
+	}
+
+	var model struct {
+		Unit      string `xml:"unit,attr"`
+		Materials []struct {
+			Color string `xml:"displaycolor,attr"`
+		} `xml:"resources>basematerials>base"`
+		Objects []struct {
+			Name      string     `xml:"name,attr"`
+			Vertices  []struct{} `xml:"mesh>vertices>vertex"`
+			Triangles []struct {
+				P1 *int `xml:"p1,attr"`
+			} `xml:"mesh>triangles>triangle"`
+		} `xml:"resources>object"`
+		Items []struct {
+			Transform string `xml:"transform,attr"`
+		} `xml:"build>item"`
+	}
+	if err := xml.Unmarshal([]byte(files["3D/3dmodel.model"]), &model); err != nil {
+		t.Fatal(err)
+	}
+	if model.Unit != "inch" || len(model.Objects) != 2 || model.Objects[0].Name != "cube" {
+		t.Fatalf("Expected two objects in inches, got %+v", model)
+	}
+	if len(model.Objects[0].Vertices) != 8 || len(model.Objects[0].Triangles) != 12 {
+		t.Fatalf("Expected the cube to have 8 vertices and 12 triangles, got %d and %d", len(model.Objects[0].Vertices), len(model.Objects[0].Triangles))
+	}
+	if len(model.Materials) != 2 || model.Materials[1].Color != "#FF0000FF" {
+		t.Fatalf("Expected a red material, got %+v", model.Materials)
+	}
+	if model.Objects[1].Triangles[0].P1 != nil || *model.Objects[1].Triangles[1].P1 != 1 {
+		t.Fatalf("Expected the sphere to be red except for the first triangle")
+	}
+	if model.Items[0].Transform != "" || model.Items[1].Transform != "1 0 0 0 1 0 0 0 1 10 20 30" {
+		t.Fatalf("Expected the sphere to be translated, got %+v", model.Items)
+	}
+
+	if err := WriteThreeMF(&bytes.Buffer{}, "", []*ThreeMFObject{{Mesh: &CSG{}}}); err == nil {
+		t.Fatal("Expected an error for an empty object")
+	}
+}
+
+func TestGLB(t *testing.T) {
+	a := NewCube(nil)
+	a.ToPolygons()[0].Shared = &Shared{Material: "red", Color: color.RGBA{255, 0, 0, 255}}
+	b := NewSphere(&SphereOptions{Center: &Vector{2, 0, 0}})
+
+	buf := &bytes.Buffer{}
+	err := WriteGLB(buf, []*GLTFMesh{{Name: "cube", Mesh: a}, {Name: "sphere", Mesh: b, Transform: NewTranslationMatrix(&Vector{1, 2, 3})}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := buf.Bytes()
+	le := binary.LittleEndian
+	if le.Uint32(data) != 0x46546C67 || le.Uint32(data[4:]) != 2 || int(le.Uint32(data[8:])) != len(data) {
+		t.Fatalf("Expected a GLB header")
+	}
+	jsonLength := int(le.Uint32(data[12:]))
+	var doc struct {
+		Nodes []struct {
+			Name   string
+			Matrix []float64
+		}
+		Meshes []struct {
+			Primitives []struct {
+				Attributes map[string]int
+				Indices    int
+				Material   *int
+			}
+		}
+		Materials []struct {
+			Name string
+		}
+		Accessors []struct {
+			BufferView    int
+			ComponentType int
+			Count         int
+			Min           []float64
+			Max           []float64
+		}
+		BufferViews []struct {
+			ByteOffset int
+			ByteLength int
+		}
+	}
+	if err := json.Unmarshal(data[20:20+jsonLength], &doc); err != nil {
+		t.Fatal(err)
+	}
+	bin := data[20+jsonLength+8:]
+
+	if len(doc.Nodes) != 2 || doc.Nodes[1].Name != "sphere" || doc.Nodes[1].Matrix[12] != 1 || doc.Nodes[1].Matrix[14] != 3 {
+		t.Fatalf("Expected two nodes with the sphere translated, got %+v", doc.Nodes)
+	}
+	if len(doc.Materials) != 1 || doc.Materials[0].Name != "red" {
+		t.Fatalf("Expected a red material, got %+v", doc.Materials)
+	}
+
+	// the cube has 24 vertices as the normals differ on each face, with one red face
+	cube := doc.Meshes[0].Primitives
+	if len(cube) != 2 || *cube[0].Material != 0 || cube[1].Material != nil {
+		t.Fatalf("Expected the cube to have a red primitive and a default primitive")
+	}
+	position := doc.Accessors[cube[0].Attributes["POSITION"]]
+	if position.Count != 24 || position.Min[0] != -0.5 || position.Max[2] != 0.5 {
+		t.Fatalf("Expected 24 cube vertices with bounds, got %+v", position)
+	}
+	triangles := 0
+	for _, p := range cube {
+		indices := doc.Accessors[p.Indices]
+		view := doc.BufferViews[indices.BufferView]
+		if indices.ComponentType != 5123 || view.ByteLength != 2*indices.Count {
+			t.Fatalf("Expected unsigned short indices, got %+v", indices)
+		}
+		for i := 0; i < indices.Count; i++ {
+			if int(le.Uint16(bin[view.ByteOffset+2*i:])) >= position.Count {
+				t.Fatalf("Expected index %d to be in range", i)
+			}
+		}
+		triangles += indices.Count / 3
+	}
+	if triangles != 12 {
+		t.Fatalf("Expected 12 triangles in the cube, got %d", triangles)
+	}
+
+	if err := WriteGLB(&bytes.Buffer{}, nil); err == nil {
+		t.Fatal("Expected an error writing no meshes")
+	}
+}
+
+func TestOFFAndVTK(t *testing.T) {
+	c := NewCube(nil).Union(NewCube(&CubeOptions{Center: &Vector{0.5, 0.5, 0.5}}))
+	points, faces := indexedPolygons(c)
+
+	b := &bytes.Buffer{}
+	if err := c.MarshalToOFF(b); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(b.String(), fmt.Sprintf("OFF\n%d %d 0\n", len(points), len(faces))) {
+		t.Fatalf("Expected an OFF header, got %q", b.String())
+	}
+
+	b.Reset()
+	if err := c.MarshalToVTK(b, "union"); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(b.String(), "# vtk DataFile Version 3.0\nunion\nASCII\nDATASET POLYDATA\n") {
+		t.Fatalf("Expected a VTK header, got %q", b.String())
+	}
+}
+
+func TestSCAD(t *testing.T) {
+	tetrahedron := NewCSGFromPolygons([]*Polygon{
+		NewPolygonFromVertices([]*Vertex{{Position: &Vector{0, 0, 0}}, {Position: &Vector{0, 1, 0}}, {Position: &Vector{1, 0, 0}}}),
+		NewPolygonFromVertices([]*Vertex{{Position: &Vector{0, 0, 0}}, {Position: &Vector{0, 0, 1}}, {Position: &Vector{0, 1, 0}}}),
+		NewPolygonFromVertices([]*Vertex{{Position: &Vector{0, 0, 0}}, {Position: &Vector{1, 0, 0}}, {Position: &Vector{0, 0, 1}}}),
+		NewPolygonFromVertices([]*Vertex{{Position: &Vector{1, 0, 0}}, {Position: &Vector{0, 1, 0}}, {Position: &Vector{0, 0, 1}}}),
+	})
+	hole := NewCylinderOp(&CylinderOptions{Start: &Vector{-2, 0, 0}, End: &Vector{2, 0, 0}, Radius: 0.5, Slices: 12})
+	op := NewCubeOp(&CubeOptions{Size: &Vector{2, 2, 2}}).
+		Subtract(hole, hole.Translate(&Vector{0, 0, 1})).
+		Union(NewSphereOp(&SphereOptions{Center: &Vector{0, 2, 0}, Radius: 0.5}), NewMeshOp(tetrahedron))
+
+	var out bytes.Buffer
+	if err := op.MarshalToSCAD(&out); err != nil {
+		t.Fatal(err)
+	}
+	scad := out.String()
+	for _, expected := range []string{
+		"module part1() {\n  multmatrix(",
+		"union() {\n  difference() {\n    translate([0, 0, 0]) cube([2, 2, 2], center=true);\n    part1();\n    multmatrix([[1, 0, 0, 0], [0, 1, 0, 0], [0, 0, 1, 1], [0, 0, 0, 1]]) {\n      part1();\n    }\n  }\n",
+		"cylinder(h=4, r=0.5, $fn=12);",
+		"translate([0, 2, 0]) sphere(r=0.5, $fn=16);",
+		"[2,1,0],",
+	} {
+		if !strings.Contains(scad, expected) {
+			t.Errorf("Expected SCAD to contain %q:\n%s", expected, scad)
+		}
+	}
+	if strings.Count(scad, "cylinder(") != 1 {
+		t.Errorf("Expected the shared cylinder to be written once:\n%s", scad)
+	}
+
+	// the cylinder is moved from the Z axis onto its axis
+	m := alignZ(&Vector{-2, 0, 0}, &Vector{4, 0, 0})
+	if p := m.TransformPoint(&Vector{0, 0, 4}); p.Minus(&Vector{2, 0, 0}).Length() > 1e-12 {
+		t.Errorf("Expected the end of the cylinder at 2,0,0 got %v", p)
+	}
+}
+
+func TestSTL(t *testing.T) {
+	c := NewSphere(&SphereOptions{Radius: 2})
+
+	var binarySTL, asciiSTL bytes.Buffer
+	if err := c.MarshalToBinarySTL(&binarySTL); err != nil {
+		t.Fatal(err)
+	}
+	c.MarshalToASCIISTL(&asciiSTL)
+	for _, in := range []*bytes.Buffer{&binarySTL, &asciiSTL} {
+		r, err := NewCSGFromSTL(in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if math.Abs(r.Volume()-c.Volume()) > 1e-4 {
+			t.Errorf("Expected volume %f got %f", c.Volume(), r.Volume())
+		}
+	}
+
+	if _, err := NewCSGFromSTL(strings.NewReader("solid x\nfacet normal 0 0 0\nouter loop\nvertex 0 0 0\nvertex 1 0 0\nendloop\n")); err == nil {
+		t.Errorf("Expected an error for a facet with 2 vertices")
+	}
+	if _, err := NewCSGFromSTL(bytes.NewReader(binarySTL.Bytes()[:100])); err == nil {
+		t.Errorf("Expected an error for a truncated binary STL")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	a := NewCube(&CubeOptions{Size: &Vector{2, 2, 2}})
+	b := NewSphere(&SphereOptions{Center: &Vector{1, 1, 1}, Radius: 1.2, Slices: 15, Stacks: 15})
+	for _, c := range []*CSG{a, b, a.Subtract(b), a.Union(b), a.Intersect(b)} {
+		if err := c.Validate(); err != nil {
+			t.Error(err)
+		}
+	}
+
+	open := NewCSGFromPolygons(a.ToPolygons()[1:])
+	if err, ok := open.Validate().(*ValidationError); !ok || err.OpenEdges != 4 {
+		t.Errorf("Expected 4 open edges got %v", err)
+	}
+	if err, ok := a.Inverse().Validate().(*ValidationError); !ok || err.Volume >= 0 {
+		t.Errorf("Expected an inside out cube to be invalid got %v", err)
+	}
+}
+
+func TestSurfaceDeviation(t *testing.T) {
+	s := NewSphere(&SphereOptions{Radius: 1, Slices: 16, Stacks: 8})
+	tree := newBVH(s.ToPolygons())
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 100; i++ {
+		p := &Vector{r.Float64()*4 - 2, r.Float64()*4 - 2, r.Float64()*4 - 2}
+		expected := math.Inf(1)
+		for _, bp := range s.ToPolygons() {
+			for _, tp := range bp.Triangles() {
+				bt := &bvhTriangle{a: tp.Vertices[0].Position, b: tp.Vertices[1].Position, c: tp.Vertices[2].Position}
+				expected = math.Min(expected, bt.closest(p).Minus(p).LengthSquared())
+			}
+		}
+		if hit := tree.closest(p); math.Abs(hit.distanceSquared-expected) > 1e-12 {
+			t.Errorf("Expected the closest point to %v to be %f away got %f", p, math.Sqrt(expected), math.Sqrt(hit.distanceSquared))
+		}
+	}
+
+	cube := NewCube(&CubeOptions{Size: &Vector{2, 2, 2}})
+	if d := SurfaceDeviation(cube, cube.Clone(), nil); d.Max > 1e-12 || d.Mean > 1e-12 || d.RMS > 1e-12 {
+		t.Errorf("Expected identical meshes to have no deviation got %v", d)
+	}
+	d := SurfaceDeviation(cube, cube.Translate(&Vector{0.25, 0, 0}), nil)
+	if math.Abs(d.Max-0.25) > 1e-12 || d.Mean <= 0 || d.Mean > d.RMS || d.RMS > d.Max {
+		t.Errorf("Expected a maximum deviation of 0.25 got %v", d)
+	}
+	if h := HausdorffDistance(s, s.Scale(&Vector{1.1, 1.1, 1.1})); math.Abs(h-0.1) > 1e-9 {
+		t.Errorf("Expected a Hausdorff distance of 0.1 got %f", h)
+	}
+	if h := HausdorffDistance(cube, NewCSGFromPolygons(nil)); !math.IsInf(h, 1) {
+		t.Errorf("Expected an infinite distance to an empty mesh got %f", h)
+	}
+
+	// the samples of a long thin triangle grow with its area rather than its length
+	for _, v := range [][3]*Vector{{{0, 0, 0}, {1000, 0, 0}, {400, 0.5, 0}}, {{400, 0.5, 0}, {0, 0, 0}, {1000, 0, 0}}} {
+		samples, area := 0, 0.0
+		sampleTriangle(v[0], v[1], v[2], 1, func(p *Vector, weight float64) {
+			samples++
+			area += weight
+		})
+		AssertAlmostEq(t, "sampled area", area, 250, 1e-9)
+		if samples > 4000 {
+			t.Errorf("Expected at most 4000 samples of a 1000 by 0.5 triangle got %d", samples)
+		}
+	}
+}
+
+func TestClosestPoint(t *testing.T) {
+	cube := NewCube(&CubeOptions{Size: &Vector{2, 2, 2}})
+	point, polygon, dist := cube.ClosestPoint(&Vector{3, 0.5, 0})
+	if !point.AlmostEquals(&Vector{1, 0.5, 0}) || polygon.Plane.Normal.X != 1 || dist != 2 {
+		t.Errorf("Expected the closest point to be 1,0.5,0 on the +X face got %v %v %f", point, polygon.Plane.Normal, dist)
+	}
+	for _, test := range []struct {
+		p        *Vector
+		distance float64
+	}{
+		{&Vector{0, 0, 0}, -1},
+		{&Vector{0.5, 0, 0}, -0.5},
+		{&Vector{1, 0.2, 0.3}, 0},
+		{&Vector{2, 2, 1}, math.Sqrt(2)},
+	} {
+		if d := cube.SignedDistance(test.p); math.Abs(d-test.distance) > 1e-12 {
+			t.Errorf("Expected a signed distance of %f from %v got %f", test.distance, test.p, d)
+		}
+	}
+
+	// the sign follows the solid through the T-junctions left by a boolean
+	s := NewSphere(&SphereOptions{Center: &Vector{1, 1, 1}, Radius: 1.2, Slices: 32, Stacks: 16})
+	c := cube.Subtract(s)
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 500; i++ {
+		p := &Vector{r.Float64()*3 - 1.5, r.Float64()*3 - 1.5, r.Float64()*3 - 1.5}
+		inCube := math.Max(math.Abs(p.X), math.Max(math.Abs(p.Y), math.Abs(p.Z))) - 1
+		inSphere := p.Minus(&Vector{1, 1, 1}).Length() - 1.2
+		if math.Abs(inCube) < 0.05 || math.Abs(inSphere) < 0.05 {
+			continue
+		}
+		expected := inCube < 0 && inSphere > 0
+		if d := c.SignedDistance(p); (d < 0) != expected {
+			t.Errorf("Expected %v to be inside %v got a distance of %f", p, expected, d)
+		}
+	}
+
+	if point, polygon, dist := NewCSGFromPolygons(nil).ClosestPoint(&Vector{}); point != nil || polygon != nil || !math.IsInf(dist, 1) {
+		t.Errorf("Expected no closest point on an empty mesh got %v %v %f", point, polygon, dist)
+	}
+}
+
+func TestIntersects(t *testing.T) {
+	triangle := func(a, b, c *Vector) *bvhTriangle {
+		return &bvhTriangle{a: a, b: b, c: c}
+	}
+	base := triangle(&Vector{0, 0, 0}, &Vector{2, 0, 0}, &Vector{0, 2, 0})
+	for _, test := range []struct {
+		t        *bvhTriangle
+		expected bool
+	}{
+		{triangle(&Vector{0.5, 0.5, -1}, &Vector{0.5, 0.5, 1}, &Vector{3, 3, 0}), true},
+		{triangle(&Vector{1.5, 1.5, -1}, &Vector{1.5, 1.5, 1}, &Vector{3, 3, 0}), false},
+		{triangle(&Vector{0, 0, 1}, &Vector{2, 0, 1}, &Vector{0, 2, 1}), false},
+		{triangle(&Vector{1, 1, 0}, &Vector{3, 1, 0}, &Vector{1, 3, 0}), true},
+		{triangle(&Vector{0.2, 0.2, 0}, &Vector{0.5, 0.2, 0}, &Vector{0.2, 0.5, 0}), true},
+		{triangle(&Vector{1.5, 1.5, 0}, &Vector{3, 1.5, 0}, &Vector{1.5, 3, 0}), false},
+		{triangle(&Vector{2, 0, 0}, &Vector{3, 0, 1}, &Vector{3, 0, -1}), true},
+	} {
+		if r := trianglesIntersect(base, test.t); r != test.expected {
+			t.Errorf("Expected %v for %v %v %v got %v", test.expected, test.t.a, test.t.b, test.t.c, r)
+		}
+	}
+
+	cube := func(center *Vector, size float64) *CSG {
+		return NewCube(&CubeOptions{Center: center, Size: &Vector{size, size, size}})
+	}
+	a := cube(&Vector{}, 2)
+	for _, test := range []struct {
+		b      *CSG
+		hit    bool
+		volume float64
+	}{
+		{cube(&Vector{1, 1, 1}, 2), true, 1},
+		{cube(&Vector{3, 0, 0}, 1), false, 0},
+		{cube(&Vector{0.2, 0, 0}, 0.5), true, 0.125},
+		{cube(&Vector{2, 0, 0}, 2), true, 0},
+		{NewCube(&CubeOptions{Size: &Vector{4, 0.5, 0.5}}), true, 0.5},
+	} {
+		if r := Intersects(a, test.b); r != test.hit {
+			t.Errorf("Expected %v for %v got %v", test.hit, test.b.BoundingBox(), r)
+		}
+		if r := Intersects(test.b, a); r != test.hit {
+			t.Errorf("Expected %v for %v the other way around got %v", test.hit, test.b.BoundingBox(), r)
+		}
+		if v := InterferenceVolume(a, test.b); math.Abs(v-test.volume) > 1e-9 {
+			t.Errorf("Expected an interference volume of %f for %v got %f", test.volume, test.b.BoundingBox(), v)
+		}
+	}
+
+	// a polygon with a collinear vertex has a triangle without any area, which touches nothing
+	polygon := func(points ...*Vector) *CSG {
+		vertices := make([]*Vertex, len(points))
+		for i, p := range points {
+			vertices[i] = &Vertex{Position: p}
+		}
+		return NewCSGFromPolygons([]*Polygon{NewPolygonFromVertices(vertices)})
+	}
+	strip := polygon(&Vector{0, 0, 0}, &Vector{1, 0, 0}, &Vector{2, 0, 0}, &Vector{2, 0.1, 0}, &Vector{0, 0.1, 0})
+	across := polygon(&Vector{1.5, -1.1, -1.1}, &Vector{1.5, 0.9, -1.1}, &Vector{1.5, -1.1, 0.9})
+	if Intersects(strip, across) || Intersects(across, strip) {
+		t.Errorf("Expected a strip with a collinear vertex not to intersect a triangle beside it")
+	}
+
+	s := NewSphere(&SphereOptions{Radius: 1, Slices: 24, Stacks: 12})
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 200; i++ {
+		offset := &Vector{r.Float64()*5 - 2.5, r.Float64()*5 - 2.5, r.Float64()*5 - 2.5}
+		d := offset.Length()
+		if math.Abs(d-2) < 0.05 {
+			continue
+		}
+		if hit := Intersects(s, s.Translate(offset)); hit != (d < 2) {
+			t.Errorf("Expected %v for spheres %f apart got %v", d < 2, d, hit)
+		}
+	}
+}
@@ -9,7 +9,8 @@ type CubeOptions struct {
 }
 
 // NewCube creates a new CSG cube with the specified options, if no options are
-// specified then a default center of 0,0,0 and size of 1,1,1 will be used
+// specified then a default center of 0,0,0 and size of 1,1,1 will be used. Each face
+// of the cube is mapped to the whole of the UV space.
 func NewCube(options *CubeOptions) *CSG {
 	center := &Vector{X: 0.0, Y: 0.0, Z: 0.0}
 	size := &Vector{X: 1.0, Y: 1.0, Z: 1.0}
@@ -41,17 +42,20 @@ func NewCube(options *CubeOptions) *CSG {
 		&Vector{0, 0, +1},
 	}
 
+	uvs := []*UV{{0, 0}, {1, 0}, {1, 1}, {0, 1}}
+
 	polygons := make([]*Polygon, 0, 6)
 
 	for j, o := range order {
 		vx := make([]*Vertex, 0)
-		for _, i := range o {
+		for k, i := range o {
 			v := &Vector{
 				X: center.X + size.X/2.0*(2.0*float64(i&1)-1.0),
 				Y: center.Y + size.Y/2.0*(2.0*float64(i&2>>1)-1.0),
 				Z: center.Z + size.Z/2.0*(2.0*float64(i&4>>2)-1.0),
 			}
-			vx = append(vx, &Vertex{Position: v, Normal: normals[j]})
+			uv := *uvs[k]
+			vx = append(vx, &Vertex{Position: v, Normal: normals[j], UV: &uv})
 		}
 		polygons = append(polygons, NewPolygonFromVertices(vx))
 	}
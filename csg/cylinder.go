@@ -14,7 +14,8 @@ type CylinderOptions struct {
 	Slices int
 }
 
-//NewCylinder returns a new CSG cylinder
+//NewCylinder returns a new CSG cylinder, the side of the cylinder is mapped to the whole of
+//UV space and each of the caps is mapped to a circle in the center of UV space
 func NewCylinder(options *CylinderOptions) *CSG {
 	s := &Vector{0, -1, 0}
 	e := &Vector{0, 1, 0}
@@ -50,14 +51,23 @@ func NewCylinder(options *CylinderOptions) *CSG {
 	axisY := axisX.Cross(axisZ).Unit()
 
 	start := NewVertexFromVectors(s, axisZ.Negated())
+	start.UV = &UV{U: 0.5, V: 0.5}
 	end := NewVertexFromVectors(e, axisZ.Unit())
+	end.UV = &UV{U: 0.5, V: 0.5}
 
 	point := func(stack float64, slice float64, normalBlend float64) *Vertex {
 		angle := slice * math.Pi * 2.0
 		out := axisX.Times(math.Cos(angle)).Plus(axisY.Times(math.Sin(angle)))
 		pos := start.Position.Plus(ray.Times(stack)).Plus(out.Times(radius))
 		normal := out.Times(1.0 - math.Abs(normalBlend)).Plus(axisZ.Times(normalBlend))
-		return NewVertexFromVectors(pos, normal)
+		v := NewVertexFromVectors(pos, normal)
+		if normalBlend == 0 {
+			v.UV = &UV{U: slice, V: stack}
+		} else {
+			// the caps are mapped to a circle in the center of UV space
+			v.UV = &UV{U: 0.5 + 0.5*math.Cos(angle), V: 0.5 + 0.5*math.Sin(angle)}
+		}
+		return v
 	}
 
 	polygons := make([]*Polygon, 0)
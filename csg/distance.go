@@ -0,0 +1,156 @@
+package csg
+
+import (
+	"math"
+)
+
+// Deviation summarizes how far apart the surfaces of two meshes are
+type Deviation struct {
+	// Max is the largest distance from a point on either surface to the other, which is the
+	// Hausdorff distance between them
+	Max float64
+	// Mean is the average distance from a point on either surface to the other, weighted by area
+	Mean float64
+	// RMS is the root mean square of the distances, weighted by area
+	RMS float64
+}
+
+// DeviationOptions control how the surfaces are sampled when measuring the deviation
+type DeviationOptions struct {
+	// Spacing is the largest distance between the samples taken on a triangle, if not specified
+	// 1/100 of the diagonal of the bounding box of both meshes is used
+	Spacing float64
+}
+
+// SurfaceDeviation measures the distances between the surfaces of two meshes in both directions
+// by sampling points on the triangles of each and finding the closest point on the other. The
+// options may be nil. If only one of the meshes is empty the distances are infinite.
+func SurfaceDeviation(a, b *CSG, options *DeviationOptions) *Deviation {
+	if options == nil {
+		options = &DeviationOptions{}
+	}
+	if len(a.polygons) == 0 && len(b.polygons) == 0 {
+		return &Deviation{}
+	}
+	if len(a.polygons) == 0 || len(b.polygons) == 0 {
+		return &Deviation{Max: math.Inf(1), Mean: math.Inf(1), RMS: math.Inf(1)}
+	}
+
+	spacing := options.Spacing
+	if spacing <= 0 {
+		box := a.BoundingBox()
+		o := b.BoundingBox()
+		box.AddVector(&o.Min)
+		box.AddVector(&o.Max)
+		spacing = box.Size().Length() / 100
+	}
+
+	d := &Deviation{}
+	var area, sum, sumSquared float64
+	for _, pair := range [][2]*CSG{{a, b}, {b, a}} {
+		tree := pair[1].spatialTree()
+		for _, p := range pair[0].polygons {
+			for _, t := range p.Triangles() {
+				sampleTriangle(t.Vertices[0].Position, t.Vertices[1].Position, t.Vertices[2].Position, spacing,
+					func(s *Vector, weight float64) {
+						distance := math.Sqrt(tree.closest(s).distanceSquared)
+						d.Max = math.Max(d.Max, distance)
+						area += weight
+						sum += weight * distance
+						sumSquared += weight * distance * distance
+					})
+			}
+		}
+	}
+	if area > 0 {
+		d.Mean = sum / area
+		d.RMS = math.Sqrt(sumSquared / area)
+	}
+	return d
+}
+
+// HausdorffDistance returns the largest distance from a point on the surface of either mesh to
+// the surface of the other, see SurfaceDeviation
+func HausdorffDistance(a, b *CSG) float64 {
+	return SurfaceDeviation(a, b, nil).Max
+}
+
+// sampleTriangle divides the triangle into strips parallel to its longest edge which are no wider
+// than spacing, and each strip into cells no longer than spacing, calling sample with the center of
+// each cell along with its area. The number of samples grows with the area of the triangle rather
+// than the square of its longest edge. The vertices and points along the edges no further apart
+// than spacing are sampled with no weight so they are covered by the maximum.
+func sampleTriangle(a, b, c *Vector, spacing float64, sample func(p *Vector, weight float64)) {
+	// make ab the longest edge
+	if l := c.Minus(b).LengthSquared(); l > b.Minus(a).LengthSquared() && l >= a.Minus(c).LengthSquared() {
+		a, b, c = b, c, a
+	} else if a.Minus(c).LengthSquared() > b.Minus(a).LengthSquared() {
+		a, b, c = c, a, b
+	}
+	count := func(length float64) int {
+		return int(math.Max(1, math.Ceil(length/spacing)))
+	}
+
+	for _, e := range [][2]*Vector{{a, b}, {b, c}, {c, a}} {
+		d := e[1].Minus(e[0])
+		n := count(d.Length())
+		for i := 0; i < n; i++ {
+			sample(e[0].Plus(d.Times(float64(i)/float64(n))), 0)
+		}
+	}
+
+	ab := b.Minus(a)
+	area := ab.Cross(c.Minus(a)).Length() / 2
+	base := ab.Length()
+	if area == 0 || base == 0 {
+		return
+	}
+	// at v the strip runs from a to b moved v of the way to c, and is 1-v of the length of ab
+	at := func(u, v float64) *Vector {
+		l := a.Lerp(c, v)
+		return l.Lerp(b.Lerp(c, v), u)
+	}
+	strips := count(2 * area / base)
+	for i := 0; i < strips; i++ {
+		v0, v1 := float64(i)/float64(strips), float64(i+1)/float64(strips)
+		cells := count((1 - v0) * base)
+		// the strip is the difference of the triangles above v0 and v1
+		weight := area * ((1-v0)*(1-v0) - (1-v1)*(1-v1)) / float64(cells)
+		for j := 0; j < cells; j++ {
+			sample(at((float64(j)+0.5)/float64(cells), (v0+v1)/2), weight)
+		}
+	}
+}
+
+// spatialTree returns the bvh of the polygons, building it on the first call
+func (c *CSG) spatialTree() *bvh {
+	c.treeOnce.Do(func() {
+		c.tree = newBVH(c.polygons)
+	})
+	return c.tree
+}
+
+// ClosestPoint returns the point on the surface of the CSG closest to p, along with the polygon
+// it's on and its distance from p. The polygon is nil and the distance infinite if the CSG is
+// empty. The first query builds an index of the polygons which is reused by later queries, so
+// the polygons must not be modified afterwards.
+func (c *CSG) ClosestPoint(p *Vector) (point *Vector, polygon *Polygon, dist float64) {
+	hit := c.spatialTree().closest(p)
+	if hit.triangle == nil {
+		return nil, nil, math.Inf(1)
+	}
+	return hit.point.Clone(), hit.triangle.polygon, math.Sqrt(hit.distanceSquared)
+}
+
+// SignedDistance returns the distance from p to the surface of the CSG, which is negative if p
+// is inside the solid. The CSG should be closed, see Validate, and is indexed as it is by
+// ClosestPoint.
+func (c *CSG) SignedDistance(p *Vector) float64 {
+	tree := c.spatialTree()
+	hit := tree.closest(p)
+	d := math.Sqrt(hit.distanceSquared)
+	if d > 0 && tree.inside(p) {
+		return -d
+	}
+	return d
+}
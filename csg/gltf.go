@@ -0,0 +1,308 @@
+package csg
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"image/color"
+	"io"
+	"math"
+)
+
+// GLTFMesh is a named mesh to be written to a glTF scene
+type GLTFMesh struct {
+	// Name of the mesh, which is optional
+	Name string
+	// Mesh to be written
+	Mesh *CSG
+	// Transform places the mesh in the scene, which is optional
+	Transform *Matrix
+}
+
+const (
+	gltfFloat         = 5126
+	gltfUnsignedShort = 5123
+	gltfUnsignedInt   = 5125
+
+	gltfArrayBuffer        = 34962
+	gltfElementArrayBuffer = 34963
+
+	glbMagic     = 0x46546C67
+	glbJSONChunk = 0x4E4F534A
+	glbBINChunk  = 0x004E4942
+)
+
+type gltfDocument struct {
+	Asset       gltfAsset         `json:"asset"`
+	Scene       int               `json:"scene"`
+	Scenes      []gltfScene       `json:"scenes"`
+	Nodes       []gltfNode        `json:"nodes"`
+	Meshes      []gltfMeshObject  `json:"meshes"`
+	Materials   []gltfMaterial    `json:"materials,omitempty"`
+	Accessors   []gltfAccessor    `json:"accessors"`
+	BufferViews []gltfBufferView  `json:"bufferViews"`
+	Buffers     []gltfBufferEntry `json:"buffers"`
+}
+
+type gltfAsset struct {
+	Version   string `json:"version"`
+	Generator string `json:"generator,omitempty"`
+}
+
+type gltfScene struct {
+	Nodes []int `json:"nodes"`
+}
+
+type gltfNode struct {
+	Name   string    `json:"name,omitempty"`
+	Mesh   int       `json:"mesh"`
+	Matrix []float64 `json:"matrix,omitempty"`
+}
+
+type gltfMeshObject struct {
+	Name       string          `json:"name,omitempty"`
+	Primitives []gltfPrimitive `json:"primitives"`
+}
+
+type gltfPrimitive struct {
+	Attributes map[string]int `json:"attributes"`
+	Indices    int            `json:"indices"`
+	Material   *int           `json:"material,omitempty"`
+}
+
+type gltfMaterial struct {
+	Name                 string               `json:"name,omitempty"`
+	PBRMetallicRoughness gltfPBRMetallicRough `json:"pbrMetallicRoughness"`
+	AlphaMode            string               `json:"alphaMode,omitempty"`
+}
+
+type gltfPBRMetallicRough struct {
+	BaseColorFactor [4]float64 `json:"baseColorFactor"`
+	MetallicFactor  float64    `json:"metallicFactor"`
+	RoughnessFactor float64    `json:"roughnessFactor"`
+}
+
+type gltfAccessor struct {
+	BufferView    int       `json:"bufferView"`
+	ComponentType int       `json:"componentType"`
+	Count         int       `json:"count"`
+	Type          string    `json:"type"`
+	Min           []float64 `json:"min,omitempty"`
+	Max           []float64 `json:"max,omitempty"`
+}
+
+type gltfBufferView struct {
+	Buffer     int `json:"buffer"`
+	ByteOffset int `json:"byteOffset"`
+	ByteLength int `json:"byteLength"`
+	Target     int `json:"target,omitempty"`
+}
+
+type gltfBufferEntry struct {
+	ByteLength int `json:"byteLength"`
+}
+
+// gltfWriter accumulates the JSON document and binary buffer of a GLB
+type gltfWriter struct {
+	doc       *gltfDocument
+	bin       *bytes.Buffer
+	materials map[string]int
+}
+
+// view appends the data to the binary buffer as a new buffer view, returning the index of the view
+func (w *gltfWriter) view(data interface{}, target int) int {
+	for w.bin.Len()%4 != 0 {
+		w.bin.WriteByte(0)
+	}
+	offset := w.bin.Len()
+	binary.Write(w.bin, binary.LittleEndian, data)
+	w.doc.BufferViews = append(w.doc.BufferViews, gltfBufferView{ByteOffset: offset, ByteLength: w.bin.Len() - offset, Target: target})
+	return len(w.doc.BufferViews) - 1
+}
+
+func (w *gltfWriter) accessor(a gltfAccessor) int {
+	w.doc.Accessors = append(w.doc.Accessors, a)
+	return len(w.doc.Accessors) - 1
+}
+
+// linear converts an 8 bit sRGB component to a linear component as used by glTF
+func linear(c uint8) float64 {
+	f := float64(c) / 255
+	if f <= 0.04045 {
+		return f / 12.92
+	}
+	return math.Pow((f+0.055)/1.055, 2.4)
+}
+
+// material returns the index of the material for the shared metadata, or nil if it has no color
+func (w *gltfWriter) material(s *Shared) *int {
+	if s == nil || s.Color == nil {
+		return nil
+	}
+	c := color.NRGBAModel.Convert(s.Color).(color.NRGBA)
+	key := fmt.Sprintf("%s#%02x%02x%02x%02x", s.Material, c.R, c.G, c.B, c.A)
+	index, ok := w.materials[key]
+	if !ok {
+		m := gltfMaterial{Name: s.Material}
+		m.PBRMetallicRoughness.BaseColorFactor = [4]float64{linear(c.R), linear(c.G), linear(c.B), float64(c.A) / 255}
+		m.PBRMetallicRoughness.RoughnessFactor = 1
+		if c.A < 255 {
+			m.AlphaMode = "BLEND"
+		}
+		index = len(w.doc.Materials)
+		w.materials[key] = index
+		w.doc.Materials = append(w.doc.Materials, m)
+	}
+	return &index
+}
+
+// mesh adds the mesh to the document, with a primitive for each material
+func (w *gltfWriter) mesh(name string, c *CSG) (int, error) {
+	m := newIndexedMesh(c, true)
+	if len(m.triangles) == 0 {
+		return 0, fmt.Errorf("gltf: mesh %q has no triangles", name)
+	}
+
+	positions := make([]float32, 0, 3*len(m.positions))
+	normals := make([]float32, 0, 3*len(m.normals))
+	for i, p := range m.positions {
+		n := m.normals[i]
+		if l := n.Length(); l > 0 {
+			n = n.DividedBy(l)
+		}
+		positions = append(positions, float32(p.X), float32(p.Y), float32(p.Z))
+		normals = append(normals, float32(n.X), float32(n.Y), float32(n.Z))
+	}
+	b := m.bounds()
+	position := w.accessor(gltfAccessor{
+		BufferView:    w.view(positions, gltfArrayBuffer),
+		ComponentType: gltfFloat,
+		Count:         len(m.positions),
+		Type:          "VEC3",
+		Min:           []float64{float64(float32(b.Min.X)), float64(float32(b.Min.Y)), float64(float32(b.Min.Z))},
+		Max:           []float64{float64(float32(b.Max.X)), float64(float32(b.Max.Y)), float64(float32(b.Max.Z))},
+	})
+	normal := w.accessor(gltfAccessor{
+		BufferView:    w.view(normals, gltfArrayBuffer),
+		ComponentType: gltfFloat,
+		Count:         len(m.normals),
+		Type:          "VEC3",
+	})
+
+	// group the triangles by material, keeping the order in which the materials first appear
+	groups := make(map[int][]int)
+	order := make([]*int, 0)
+	for i, s := range m.shared {
+		material := w.material(s)
+		key := -1
+		if material != nil {
+			key = *material
+		}
+		if _, ok := groups[key]; !ok {
+			order = append(order, material)
+		}
+		groups[key] = append(groups[key], m.triangles[i][:]...)
+	}
+
+	mesh := gltfMeshObject{Name: name}
+	for _, material := range order {
+		key := -1
+		if material != nil {
+			key = *material
+		}
+		indices := groups[key]
+		a := gltfAccessor{ComponentType: gltfUnsignedInt, Count: len(indices), Type: "SCALAR"}
+		if len(m.positions) < math.MaxUint16 {
+			short := make([]uint16, len(indices))
+			for i, index := range indices {
+				short[i] = uint16(index)
+			}
+			a.ComponentType = gltfUnsignedShort
+			a.BufferView = w.view(short, gltfElementArrayBuffer)
+		} else {
+			long := make([]uint32, len(indices))
+			for i, index := range indices {
+				long[i] = uint32(index)
+			}
+			a.BufferView = w.view(long, gltfElementArrayBuffer)
+		}
+		mesh.Primitives = append(mesh.Primitives, gltfPrimitive{
+			Attributes: map[string]int{"POSITION": position, "NORMAL": normal},
+			Indices:    w.accessor(a),
+			Material:   material,
+		})
+	}
+	w.doc.Meshes = append(w.doc.Meshes, mesh)
+	return len(w.doc.Meshes) - 1, nil
+}
+
+// WriteGLB writes the meshes to a binary glTF 2.0 file as a single scene, with a node for each mesh.
+// The meshes are triangulated with vertices welded by position and normal, and polygons with a color
+// in their Shared metadata are given a material of that color. At least one mesh is required.
+func WriteGLB(out io.Writer, meshes []*GLTFMesh) error {
+	if len(meshes) == 0 {
+		return fmt.Errorf("gltf: no meshes to write")
+	}
+	w := &gltfWriter{
+		doc: &gltfDocument{
+			Asset:  gltfAsset{Version: "2.0", Generator: "github.com/celer/csg"},
+			Scenes: []gltfScene{{Nodes: []int{}}},
+		},
+		bin:       &bytes.Buffer{},
+		materials: make(map[string]int),
+	}
+
+	for _, m := range meshes {
+		index, err := w.mesh(m.Name, m.Mesh)
+		if err != nil {
+			return err
+		}
+		node := gltfNode{Name: m.Name, Mesh: index}
+		if m.Transform != nil {
+			// glTF matrices are column major
+			node.Matrix = make([]float64, 16)
+			for i := 0; i < 4; i++ {
+				for j := 0; j < 4; j++ {
+					node.Matrix[j*4+i] = m.Transform[i*4+j]
+				}
+			}
+		}
+		w.doc.Nodes = append(w.doc.Nodes, node)
+		w.doc.Scenes[0].Nodes = append(w.doc.Scenes[0].Nodes, len(w.doc.Nodes)-1)
+	}
+
+	for w.bin.Len()%4 != 0 {
+		w.bin.WriteByte(0)
+	}
+	w.doc.Buffers = []gltfBufferEntry{{ByteLength: w.bin.Len()}}
+
+	doc, err := json.Marshal(w.doc)
+	if err != nil {
+		return err
+	}
+	for len(doc)%4 != 0 {
+		doc = append(doc, ' ')
+	}
+
+	header := []uint32{
+		glbMagic, 2, uint32(12 + 8 + len(doc) + 8 + w.bin.Len()),
+		uint32(len(doc)), glbJSONChunk,
+	}
+	if err := binary.Write(out, binary.LittleEndian, header); err != nil {
+		return err
+	}
+	if _, err := out.Write(doc); err != nil {
+		return err
+	}
+	if err := binary.Write(out, binary.LittleEndian, []uint32{uint32(w.bin.Len()), glbBINChunk}); err != nil {
+		return err
+	}
+	_, err = out.Write(w.bin.Bytes())
+	return err
+}
+
+// MarshalToGLB writes this CSG out as a binary glTF 2.0 file containing a single mesh
+func (c *CSG) MarshalToGLB(out io.Writer) error {
+	return WriteGLB(out, []*GLTFMesh{{Mesh: c}})
+}
@@ -0,0 +1,30 @@
+package csg_test
+
+import (
+	"testing"
+
+	"github.com/celer/csg/csg"
+	"github.com/celer/csg/csgtest"
+)
+
+// These tests compare against the goldens in testdata, run them with -update after an intended
+// change to the results
+
+func TestSubtraction(t *testing.T) {
+	s1 := csg.NewCube(&csg.CubeOptions{Size: &csg.Vector{X: 2, Y: 2, Z: 2}})
+	s2 := csg.NewSphere(&csg.SphereOptions{Center: &csg.Vector{X: 1, Y: 1, Z: 1}, Radius: 1.2, Slices: 15, Stacks: 15})
+
+	c := s1.Subtract(s2)
+
+	csgtest.AssertMesh(t, "basic_sub", c, nil)
+	csgtest.AssertImage(t, "basic_sub", c, nil)
+}
+
+func TestUnionIntersect(t *testing.T) {
+	a := csg.NewCube(&csg.CubeOptions{Size: &csg.Vector{X: 2, Y: 2, Z: 2}})
+	b := csg.NewCylinder(&csg.CylinderOptions{Start: &csg.Vector{X: -2}, End: &csg.Vector{X: 2}, Radius: 0.75, Slices: 16})
+	s := csg.NewSphere(&csg.SphereOptions{Radius: 1.3, Slices: 16, Stacks: 8})
+
+	csgtest.AssertMesh(t, "union", a.Union(b), nil)
+	csgtest.AssertMesh(t, "intersect", a.Intersect(s), nil)
+}
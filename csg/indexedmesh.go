@@ -0,0 +1,85 @@
+package csg
+
+// indexedMesh is a triangulation of a CSG where vertices are shared between triangles, which is
+// the representation used by most mesh file formats
+type indexedMesh struct {
+	positions []*Vector
+	// normals of each vertex, only populated if the vertices were welded by normal
+	normals []*Vector
+	// triangles are the indices of the vertices of each triangle
+	triangles [][3]int
+	// shared is the metadata of the polygon each triangle came from
+	shared []*Shared
+}
+
+// newIndexedMesh triangulates the CSG, welding vertices with the same position, and if normals is
+// true the same normal. Triangles which are degenerate once welded are discarded.
+func newIndexedMesh(c *CSG, normals bool) *indexedMesh {
+	type key struct {
+		position Vector
+		normal   Vector
+	}
+	m := &indexedMesh{}
+	indices := make(map[key]int)
+	for _, p := range c.polygons {
+		for _, t := range p.Triangles() {
+			var tri [3]int
+			for i, v := range t.Vertices {
+				k := key{position: *v.Position}
+				if normals {
+					k.normal = *v.Normal
+				}
+				index, ok := indices[k]
+				if !ok {
+					index = len(m.positions)
+					indices[k] = index
+					m.positions = append(m.positions, v.Position)
+					if normals {
+						m.normals = append(m.normals, v.Normal)
+					}
+				}
+				tri[i] = index
+			}
+			if tri[0] == tri[1] || tri[1] == tri[2] || tri[0] == tri[2] {
+				continue
+			}
+			m.triangles = append(m.triangles, tri)
+			m.shared = append(m.shared, p.Shared)
+		}
+	}
+	return m
+}
+
+// bounds returns the box containing all of the positions
+func (m *indexedMesh) bounds() *Box {
+	b := &Box{}
+	if len(m.positions) > 0 {
+		b.Min.CopyFrom(m.positions[0])
+		b.Max.CopyFrom(m.positions[0])
+	}
+	for _, p := range m.positions {
+		b.AddVector(p)
+	}
+	return b
+}
+
+// indexedPolygons returns the polygons of the CSG as indices into a list of positions, welding
+// vertices with the same position
+func indexedPolygons(c *CSG) ([]*Vector, [][]int) {
+	indices := make(map[Vector]int)
+	positions := make([]*Vector, 0)
+	faces := make([][]int, len(c.polygons))
+	for i, p := range c.polygons {
+		faces[i] = make([]int, len(p.Vertices))
+		for j, v := range p.Vertices {
+			index, ok := indices[*v.Position]
+			if !ok {
+				index = len(positions)
+				indices[*v.Position] = index
+				positions = append(positions, v.Position)
+			}
+			faces[i][j] = index
+		}
+	}
+	return positions, faces
+}
@@ -0,0 +1,203 @@
+package csg
+
+import (
+	"math"
+)
+
+// Intersects checks if two meshes interfere, which is when their surfaces cross or touch or when
+// one is entirely inside the other, InterferenceVolume measures how much they overlap
+func Intersects(a, b *CSG) bool {
+	if len(a.polygons) == 0 || len(b.polygons) == 0 || !a.BoundingBox().Intersects(b.BoundingBox()) {
+		return false
+	}
+	ta, tb := a.spatialTree(), b.spatialTree()
+	if ta.root == nil || tb.root == nil {
+		return false
+	}
+	if ta.root.intersects(tb.root) {
+		return true
+	}
+	// the surfaces don't meet, so either one is inside the other or they're apart
+	return tb.inside(ta.root.anyVertex()) || ta.inside(tb.root.anyVertex())
+}
+
+// InterferenceVolume returns the volume of the overlap between two closed meshes, which is zero
+// if they don't intersect or only touch
+func InterferenceVolume(a, b *CSG) float64 {
+	if !Intersects(a, b) {
+		return 0
+	}
+	return math.Max(0, a.Intersect(b).Volume())
+}
+
+// anyVertex returns a vertex of one of the triangles under the node
+func (n *bvhNode) anyVertex() *Vector {
+	for n.triangles == nil {
+		n = n.left
+	}
+	return n.triangles[0].a
+}
+
+// intersects checks if any triangle under this node intersects a triangle under the other
+func (n *bvhNode) intersects(o *bvhNode) bool {
+	if !n.box.Intersects(&o.box) {
+		return false
+	}
+	if n.triangles != nil && o.triangles != nil {
+		for _, t := range n.triangles {
+			// skip the triangle if the other node is entirely on one side of it
+			if boxBeside(&o.box, t.polygon.Plane) {
+				continue
+			}
+			for _, u := range o.triangles {
+				if trianglesIntersect(t, u) {
+					return true
+				}
+			}
+		}
+		return false
+	}
+	// descend into the larger node until both are leaves
+	if o.triangles != nil || (n.triangles == nil && n.box.Size().LengthSquared() >= o.box.Size().LengthSquared()) {
+		return n.left.intersects(o) || n.right.intersects(o)
+	}
+	return n.intersects(o.left) || n.intersects(o.right)
+}
+
+// boxBeside checks if the box is entirely on one side of the plane without touching it, unlike
+// Box.RelationToPlane which treats corners within EPSILON of the plane as on the plane
+func boxBeside(b *Box, p *Plane) bool {
+	min, max := math.Inf(1), math.Inf(-1)
+	for _, c := range b.Corners() {
+		d := p.Normal.Dot(c) - p.W
+		min, max = math.Min(min, d), math.Max(max, d)
+	}
+	return min > EPSILON || max < -EPSILON
+}
+
+// sameSide checks if the values are all positive or all negative
+func sameSide(d [3]float64) bool {
+	return (d[0] > 0 && d[1] > 0 && d[2] > 0) || (d[0] < 0 && d[1] < 0 && d[2] < 0)
+}
+
+// degenerate checks if the triangle has no area, which is when its normal (b-a)x(c-a) is exactly
+// zero. Moving a along a single axis gives a point whose orientation has the sign of that
+// component of the normal, it's moved by at least 1 so the products in orientation don't underflow.
+func (t *bvhTriangle) degenerate() bool {
+	move := func(v float64) float64 {
+		return v + math.Max(1, math.Abs(v))
+	}
+	for _, d := range []*Vector{
+		{move(t.a.X), t.a.Y, t.a.Z},
+		{t.a.X, move(t.a.Y), t.a.Z},
+		{t.a.X, t.a.Y, move(t.a.Z)},
+	} {
+		if orientation(t.a, t.b, t.c, d) != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// trianglesIntersect checks if two triangles cross or touch, the side of each triangle's plane
+// the vertices of the other are on is found exactly. Triangles without any area are ignored, as
+// they are covered by the other triangles of their polygon.
+func trianglesIntersect(t, u *bvhTriangle) bool {
+	if t.degenerate() || u.degenerate() {
+		return false
+	}
+	du := [3]float64{orientation(t.a, t.b, t.c, u.a), orientation(t.a, t.b, t.c, u.b), orientation(t.a, t.b, t.c, u.c)}
+	if sameSide(du) {
+		return false
+	}
+	dt := [3]float64{orientation(u.a, u.b, u.c, t.a), orientation(u.a, u.b, u.c, t.b), orientation(u.a, u.b, u.c, t.c)}
+	if sameSide(dt) {
+		return false
+	}
+	if du[0] == 0 && du[1] == 0 && du[2] == 0 {
+		return coplanarTrianglesIntersect(t, u)
+	}
+
+	// both triangles cross the line where the planes meet, they intersect if the parts of the
+	// line within each triangle overlap
+	line := t.b.Minus(t.a).Cross(t.c.Minus(t.a)).Cross(u.b.Minus(u.a).Cross(u.c.Minus(u.a)))
+	tMin, tMax := planeCrossing(t, dt, line)
+	uMin, uMax := planeCrossing(u, du, line)
+	return tMin <= uMax && uMin <= tMax
+}
+
+// planeCrossing returns the interval along the direction covered by the points where the triangle
+// meets a plane, given the sides of the plane its vertices are on
+func planeCrossing(t *bvhTriangle, d [3]float64, direction *Vector) (float64, float64) {
+	vertices := [3]*Vector{t.a, t.b, t.c}
+	min, max := math.Inf(1), math.Inf(-1)
+	add := func(p *Vector) {
+		s := p.Dot(direction)
+		min, max = math.Min(min, s), math.Max(max, s)
+	}
+	for i := 0; i < 3; i++ {
+		j := (i + 1) % 3
+		if d[i] == 0 {
+			add(vertices[i])
+		} else if (d[i] > 0) != (d[j] > 0) && d[j] != 0 {
+			add(vertices[i].Lerp(vertices[j], d[i]/(d[i]-d[j])))
+		}
+	}
+	return min, max
+}
+
+// coplanarTrianglesIntersect checks if two triangles in the same plane overlap by projecting them
+// onto the axis aligned plane the normal is closest to
+func coplanarTrianglesIntersect(t, u *bvhTriangle) bool {
+	normal := t.b.Minus(t.a).Cross(t.c.Minus(t.a))
+	x, y := 1, 2
+	if math.Abs(normal.Y) > math.Abs(normal.X) && math.Abs(normal.Y) >= math.Abs(normal.Z) {
+		x, y = 0, 2
+	} else if math.Abs(normal.Z) > math.Abs(normal.X) && math.Abs(normal.Z) > math.Abs(normal.Y) {
+		x, y = 0, 1
+	}
+	project := func(t *bvhTriangle) [3][2]float64 {
+		var p [3][2]float64
+		for i, v := range []*Vector{t.a, t.b, t.c} {
+			p[i] = [2]float64{v.Get(x), v.Get(y)}
+		}
+		return p
+	}
+	p, q := project(t), project(u)
+	for i := 0; i < 3; i++ {
+		for j := 0; j < 3; j++ {
+			if segmentsIntersect2D(p[i], p[(i+1)%3], q[j], q[(j+1)%3]) {
+				return true
+			}
+		}
+	}
+	return insideTriangle2D(p[0], q) || insideTriangle2D(q[0], p)
+}
+
+// orient2D is twice the signed area of the triangle a, b, c
+func orient2D(a, b, c [2]float64) float64 {
+	return (b[0]-a[0])*(c[1]-a[1]) - (b[1]-a[1])*(c[0]-a[0])
+}
+
+// segmentsIntersect2D checks if the segments ab and cd cross or touch
+func segmentsIntersect2D(a, b, c, d [2]float64) bool {
+	d1, d2 := orient2D(c, d, a), orient2D(c, d, b)
+	d3, d4 := orient2D(a, b, c), orient2D(a, b, d)
+	if ((d1 > 0 && d2 < 0) || (d1 < 0 && d2 > 0)) && ((d3 > 0 && d4 < 0) || (d3 < 0 && d4 > 0)) {
+		return true
+	}
+	onSegment := func(a, b, p [2]float64) bool {
+		return math.Min(a[0], b[0]) <= p[0] && p[0] <= math.Max(a[0], b[0]) &&
+			math.Min(a[1], b[1]) <= p[1] && p[1] <= math.Max(a[1], b[1])
+	}
+	return (d1 == 0 && onSegment(c, d, a)) || (d2 == 0 && onSegment(c, d, b)) ||
+		(d3 == 0 && onSegment(a, b, c)) || (d4 == 0 && onSegment(a, b, d))
+}
+
+// insideTriangle2D checks if p is inside or on the edge of the triangle
+func insideTriangle2D(p [2]float64, t [3][2]float64) bool {
+	d1, d2, d3 := orient2D(t[0], t[1], p), orient2D(t[1], t[2], p), orient2D(t[2], t[0], p)
+	negative := d1 < 0 || d2 < 0 || d3 < 0
+	positive := d1 > 0 || d2 > 0 || d3 > 0
+	return !(negative && positive)
+}
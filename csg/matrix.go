@@ -0,0 +1,133 @@
+package csg
+
+import (
+	"fmt"
+	"math"
+)
+
+// Matrix is a 4x4 affine transformation matrix, stored in row major order
+type Matrix [16]float64
+
+// NewIdentityMatrix returns a new identity matrix
+func NewIdentityMatrix() *Matrix {
+	return &Matrix{
+		1, 0, 0, 0,
+		0, 1, 0, 0,
+		0, 0, 1, 0,
+		0, 0, 0, 1,
+	}
+}
+
+// NewTranslationMatrix returns a matrix which translates by the specified vector
+func NewTranslationMatrix(v *Vector) *Matrix {
+	return &Matrix{
+		1, 0, 0, v.X,
+		0, 1, 0, v.Y,
+		0, 0, 1, v.Z,
+		0, 0, 0, 1,
+	}
+}
+
+// NewScalingMatrix returns a matrix which scales each axis by the components of the specified vector
+func NewScalingMatrix(v *Vector) *Matrix {
+	return &Matrix{
+		v.X, 0, 0, 0,
+		0, v.Y, 0, 0,
+		0, 0, v.Z, 0,
+		0, 0, 0, 1,
+	}
+}
+
+// NewRotationMatrix returns a matrix which rotates around the specified axis by the angle (in degrees),
+// following the right hand rule
+func NewRotationMatrix(axis *Vector, degrees float64) *Matrix {
+	a := axis.Unit()
+	r := degrees * math.Pi / 180.0
+	c := math.Cos(r)
+	s := math.Sin(r)
+	t := 1 - c
+	return &Matrix{
+		t*a.X*a.X + c, t*a.X*a.Y - s*a.Z, t*a.X*a.Z + s*a.Y, 0,
+		t*a.X*a.Y + s*a.Z, t*a.Y*a.Y + c, t*a.Y*a.Z - s*a.X, 0,
+		t*a.X*a.Z - s*a.Y, t*a.Y*a.Z + s*a.X, t*a.Z*a.Z + c, 0,
+		0, 0, 0, 1,
+	}
+}
+
+// Clone returns a copy of this matrix
+func (m *Matrix) Clone() *Matrix {
+	r := *m
+	return &r
+}
+
+// Multiply returns a new matrix which is the product of this matrix and another, so that the
+// resulting matrix applies o first and then this matrix
+func (m *Matrix) Multiply(o *Matrix) *Matrix {
+	r := &Matrix{}
+	for row := 0; row < 4; row++ {
+		for col := 0; col < 4; col++ {
+			v := 0.0
+			for k := 0; k < 4; k++ {
+				v += m[row*4+k] * o[k*4+col]
+			}
+			r[row*4+col] = v
+		}
+	}
+	return r
+}
+
+// TransformPoint returns a new vector which is the vector (as a point) transformed by this matrix
+func (m *Matrix) TransformPoint(v *Vector) *Vector {
+	return &Vector{
+		X: m[0]*v.X + m[1]*v.Y + m[2]*v.Z + m[3],
+		Y: m[4]*v.X + m[5]*v.Y + m[6]*v.Z + m[7],
+		Z: m[8]*v.X + m[9]*v.Y + m[10]*v.Z + m[11],
+	}
+}
+
+// TransformDirection returns a new vector which is the vector (as a direction) transformed by this matrix,
+// ignoring any translation
+func (m *Matrix) TransformDirection(v *Vector) *Vector {
+	return &Vector{
+		X: m[0]*v.X + m[1]*v.Y + m[2]*v.Z,
+		Y: m[4]*v.X + m[5]*v.Y + m[6]*v.Z,
+		Z: m[8]*v.X + m[9]*v.Y + m[10]*v.Z,
+	}
+}
+
+// TransformNormal returns a new unit vector which is the normal transformed by the inverse transpose of
+// the upper 3x3 portion of this matrix, which keeps normals perpendicular under non-uniform scaling
+func (m *Matrix) TransformNormal(v *Vector) *Vector {
+	// the cofactor matrix is the inverse transpose scaled by the determinant, and since
+	// we normalize the result only the sign of the determinant matters
+	n := &Vector{
+		X: (m[5]*m[10]-m[6]*m[9])*v.X + (m[6]*m[8]-m[4]*m[10])*v.Y + (m[4]*m[9]-m[5]*m[8])*v.Z,
+		Y: (m[2]*m[9]-m[1]*m[10])*v.X + (m[0]*m[10]-m[2]*m[8])*v.Y + (m[1]*m[8]-m[0]*m[9])*v.Z,
+		Z: (m[1]*m[6]-m[2]*m[5])*v.X + (m[2]*m[4]-m[0]*m[6])*v.Y + (m[0]*m[5]-m[1]*m[4])*v.Z,
+	}
+	if m.Determinant() < 0 {
+		n = n.Negated()
+	}
+	if n.LengthSquared() == 0 {
+		return n
+	}
+	return n.Unit()
+}
+
+// Determinant returns the determinant of the upper 3x3 portion of this matrix
+func (m *Matrix) Determinant() float64 {
+	return m[0]*(m[5]*m[10]-m[6]*m[9]) -
+		m[1]*(m[4]*m[10]-m[6]*m[8]) +
+		m[2]*(m[4]*m[9]-m[5]*m[8])
+}
+
+// IsMirroring returns true if this matrix mirrors geometry, which means polygon windings must be
+// reversed to keep them facing outwards
+func (m *Matrix) IsMirroring() bool {
+	return m.Determinant() < 0
+}
+
+// String returns a string representation of this matrix
+func (m *Matrix) String() string {
+	return fmt.Sprintf("[%v %v %v %v]", m[0:4], m[4:8], m[8:12], m[12:16])
+}
@@ -1,6 +1,9 @@
 package csg
 
 // Node is a node from a BSP tree
+//
+// All of the operations on the tree use an explicit stack rather than recursion, as degenerate
+// inputs can produce trees which are thousands of nodes deep.
 type Node struct {
 	plane    *Plane
 	front    *Node
@@ -8,6 +11,13 @@ type Node struct {
 	polygons []*Polygon
 }
 
+// nodeWork is an item on the explicit stack used to traverse a BSP tree
+type nodeWork struct {
+	node     *Node
+	polygons []*Polygon
+	depth    int
+}
+
 // NewNodeFromPolygons constructs a node from a slice of polygons
 func NewNodeFromPolygons(p []*Polygon) *Node {
 	n := &Node{}
@@ -15,136 +25,193 @@ func NewNodeFromPolygons(p []*Polygon) *Node {
 	return n
 }
 
+// newNodeFromPolygons constructs a node from a slice of polygons as part of an operation
+func newNodeFromPolygons(op *operation, p []*Polygon) *Node {
+	n := &Node{}
+	n.build(op, p)
+	return n
+}
+
 // Clone will clone this node and it's children
 func (n *Node) Clone() *Node {
-	r := &Node{}
-	if n.plane != nil {
-		r.plane = n.plane.Clone()
-	}
-	if n.front != nil {
-		r.front = n.front.Clone()
-	}
-	if n.back != nil {
-		r.back = n.back.Clone()
+	root := &Node{}
+	stack := [][2]*Node{{n, root}}
+	for len(stack) > 0 {
+		pair := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		src, dst := pair[0], pair[1]
+
+		if src.plane != nil {
+			dst.plane = src.plane.Clone()
+		}
+		if src.front != nil {
+			dst.front = &Node{}
+			stack = append(stack, [2]*Node{src.front, dst.front})
+		}
+		if src.back != nil {
+			dst.back = &Node{}
+			stack = append(stack, [2]*Node{src.back, dst.back})
+		}
+		dst.polygons = make([]*Polygon, 0, len(src.polygons))
+		dst.polygons = append(dst.polygons, src.polygons...)
 	}
-	r.polygons = make([]*Polygon, 0)
-	for _, p := range n.polygons {
-		r.polygons = append(r.polygons, p)
+	return root
+}
+
+// nodes returns every node in the tree, with each node appearing before it's children
+// and front children appearing before back children
+func (n *Node) nodes() []*Node {
+	nodes := make([]*Node, 0)
+	stack := []*Node{n}
+	for len(stack) > 0 {
+		node := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		nodes = append(nodes, node)
+		if node.back != nil {
+			stack = append(stack, node.back)
+		}
+		if node.front != nil {
+			stack = append(stack, node.front)
+		}
 	}
-	return r
+	return nodes
 }
 
 // Invert flips all the normals of the polygons in this node and it's children
 func (n *Node) Invert() {
-	for _, p := range n.polygons {
-		p.Flip()
-	}
-	n.plane.Flip()
-	if n.front != nil {
-		n.front.Invert()
-	}
-	if n.back != nil {
-		n.back.Invert()
+	for _, node := range n.nodes() {
+		for _, p := range node.polygons {
+			p.Flip()
+		}
+		node.plane.Flip()
+		node.front, node.back = node.back, node.front
 	}
-	temp := n.front
-	n.front = n.back
-	n.back = temp
 }
 
-func (n *Node) clipPolygons(splitter IPolygonSplitter, polygons []*Polygon) []*Polygon {
+func (n *Node) clipPolygons(op *operation, splitter IPolygonSplitter, polygons []*Polygon) []*Polygon {
 	if n.plane == nil {
 		p := make([]*Polygon, 0)
 		p = append(p, polygons...)
 		return p
 	}
 
-	front := make([]*Polygon, 0, len(polygons)/5)
-	back := make([]*Polygon, 0, len(polygons)/5)
+	result := make([]*Polygon, 0, len(polygons))
+	stack := []nodeWork{{node: n, polygons: polygons}}
+	for len(stack) > 0 {
+		if op.cancelled() {
+			return nil
+		}
+		w := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		op.processed(len(w.polygons), w.depth)
 
-	splitter.SplitPolygons(n.plane, polygons, &front, &back, &front, &back)
+		front := make([]*Polygon, 0, len(w.polygons)/5)
+		back := make([]*Polygon, 0, len(w.polygons)/5)
 
-	if n.front != nil {
-		front = n.front.clipPolygons(splitter, front)
-	}
-	if n.back != nil {
-		back = n.back.clipPolygons(splitter, back)
-		return append(front, back...)
-	}
-	return front
+		splitter.SplitPolygons(w.node.plane, w.polygons, &front, &back, &front, &back)
+		op.split(len(w.polygons), len(front)+len(back))
 
+		// polygons behind a node without a back child are inside the solid and are discarded,
+		// the back work is pushed first so that the front is completed first, which keeps the
+		// resulting polygons in the same order as a depth first traversal
+		if w.node.back != nil && len(back) > 0 {
+			stack = append(stack, nodeWork{node: w.node.back, polygons: back, depth: w.depth + 1})
+		}
+		if w.node.front != nil {
+			if len(front) > 0 {
+				stack = append(stack, nodeWork{node: w.node.front, polygons: front, depth: w.depth + 1})
+			}
+		} else {
+			result = append(result, front...)
+		}
+	}
+	return result
 }
 
 // ClipPolygons will clip the slice of polygons to this node and it's children
 func (n *Node) ClipPolygons(polygons []*Polygon) []*Polygon {
-	return n.clipPolygons(n.getPolygonSplitter(len(polygons)), polygons)
+	return n.clip(&operation{}, polygons)
+}
+
+// clip will clip the slice of polygons to this node and it's children as part of an operation
+func (n *Node) clip(op *operation, polygons []*Polygon) []*Polygon {
+	return n.clipPolygons(op, n.getPolygonSplitter(op, len(polygons)), polygons)
 }
 
 // ClipTo will clip the node to this node and vice versa
 func (n *Node) ClipTo(bsp *Node) {
-	n.polygons = bsp.ClipPolygons(n.polygons)
-	if n.front != nil {
-		n.front.ClipTo(bsp)
-	}
-	if n.back != nil {
-		n.back.ClipTo(bsp)
+	for _, node := range n.nodes() {
+		node.polygons = bsp.ClipPolygons(node.polygons)
 	}
 }
 
 // AllPolygons will return all the polygons associated with this node and it's children
 func (n *Node) AllPolygons() []*Polygon {
 	polygons := make([]*Polygon, 0)
-
-	polygons = append(polygons, n.polygons...)
-	if n.front != nil {
-		polygons = append(polygons, n.front.AllPolygons()...)
-	}
-	if n.back != nil {
-		polygons = append(polygons, n.back.AllPolygons()...)
+	for _, node := range n.nodes() {
+		polygons = append(polygons, node.polygons...)
 	}
 	return polygons
 }
 
-func (n *Node) build(splitter IPolygonSplitter, polygons []*Polygon) {
+func (n *Node) build(op *operation, polygons []*Polygon) {
 	if len(polygons) == 0 {
 		return
 	}
-	if n.plane == nil {
-		n.plane = polygons[0].Plane.Clone()
-	}
 
-	front := make([]*Polygon, 0)
-	back := make([]*Polygon, 0)
+	stack := []nodeWork{{node: n, polygons: polygons}}
+	for len(stack) > 0 {
+		if op.cancelled() {
+			return
+		}
+		w := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		op.processed(len(w.polygons), w.depth)
+
+		node := w.node
+		if node.plane == nil {
+			node.plane = op.selectPlane(w.polygons)
+			op.node(w.depth)
+		}
+
+		front := make([]*Polygon, 0)
+		back := make([]*Polygon, 0)
+		coplanar := len(node.polygons)
 
-	splitter.SplitPolygons(n.plane, polygons, &n.polygons, &n.polygons, &front, &back)
+		splitter := n.getPolygonSplitter(op, len(w.polygons))
+		splitter.SplitPolygons(node.plane, w.polygons, &node.polygons, &node.polygons, &front, &back)
+		op.split(len(w.polygons), len(node.polygons)-coplanar+len(front)+len(back))
 
-	if len(front) > 0 {
-		if n.front == nil {
-			n.front = &Node{}
+		if len(back) > 0 {
+			if node.back == nil {
+				node.back = &Node{}
+			}
+			stack = append(stack, nodeWork{node: node.back, polygons: back, depth: w.depth + 1})
 		}
-		n.front.Build(front)
-	}
-	if len(back) > 0 {
-		if n.back == nil {
-			n.back = &Node{}
+		if len(front) > 0 {
+			if node.front == nil {
+				node.front = &Node{}
+			}
+			stack = append(stack, nodeWork{node: node.front, polygons: front, depth: w.depth + 1})
 		}
-		n.back.Build(back)
 	}
 }
 
 // getPolygonSplitter will use different polygon splitter implementations depending upon the
 // number of polygons to split, so the trade off here is managing multiple goroutines vs
 // a single go routine for a small number of polygons
-func (n *Node) getPolygonSplitter(numPolys int) IPolygonSplitter {
-	var splitter IPolygonSplitter
-	if numPolys > 1000 {
-		splitter = &MultiCorePolygonSplitter{Target: &BasicPolygonSplitter{}}
-	} else {
-		splitter = &BasicPolygonSplitter{}
+func (n *Node) getPolygonSplitter(op *operation, numPolys int) IPolygonSplitter {
+	var splitter IPolygonSplitter = &BasicPolygonSplitter{Epsilon: op.epsilon}
+	if op.splitter != nil {
+		splitter = op.splitter
+	}
+	if numPolys > 1000 && op.concurrency != 1 {
+		splitter = &MultiCorePolygonSplitter{Target: splitter, Concurrency: op.concurrency}
 	}
 	return splitter
 }
 
 //Build constructs a BSP tree for the given slice of polygons
 func (n *Node) Build(polygons []*Polygon) {
-	n.build(n.getPolygonSplitter(len(polygons)), polygons)
+	n.build(&operation{}, polygons)
 }
@@ -0,0 +1,138 @@
+package csg
+
+import (
+	"bufio"
+	"fmt"
+	"image/color"
+	"io"
+	"strings"
+)
+
+// objMaterial is a material referenced by the polygons written to an OBJ
+type objMaterial struct {
+	name  string
+	color color.Color
+}
+
+// materialName returns the name of the material for the shared metadata, polygons without a
+// material name but with a color get a material named after the color
+func materialName(s *Shared) string {
+	if s == nil {
+		return ""
+	}
+	if s.Material != "" {
+		return strings.Join(strings.Fields(s.Material), "_")
+	}
+	if s.Color != nil {
+		c := color.NRGBAModel.Convert(s.Color).(color.NRGBA)
+		return fmt.Sprintf("color_%02x%02x%02x%02x", c.R, c.G, c.B, c.A)
+	}
+	return ""
+}
+
+// MarshalToOBJ writes this CSG out as a Wavefront OBJ. Polygons are written without being
+// triangulated, along with the texture coordinates of any vertices which have a UV, and the
+// materials of the polygons are written to the material library mtl which is referenced from
+// the OBJ as mtlName. If mtl is nil no materials are written. Polygons with a FeatureID are
+// placed in a group named after the feature.
+func (c *CSG) MarshalToOBJ(out io.Writer, mtl io.Writer, mtlName string) error {
+	w := bufio.NewWriter(out)
+
+	if mtl != nil {
+		fmt.Fprintf(w, "mtllib %s\n", mtlName)
+	}
+
+	positions := make(map[Vector]int)
+	normals := make(map[Vector]int)
+	uvs := make(map[Vector]int)
+	index := func(m map[Vector]int, prefix string, v *Vector) int {
+		if i, ok := m[*v]; ok {
+			return i
+		}
+		i := len(m) + 1
+		m[*v] = i
+		if prefix == "vt" {
+			fmt.Fprintf(w, "%s %g %g\n", prefix, v.X, v.Y)
+		} else {
+			fmt.Fprintf(w, "%s %g %g %g\n", prefix, v.X, v.Y, v.Z)
+		}
+		return i
+	}
+
+	materials := make([]*objMaterial, 0)
+	seen := make(map[string]bool)
+	material := ""
+	feature := 0
+	faces := make([]int, 0, 24)
+	for _, p := range c.polygons {
+		faces = faces[:0]
+		for _, v := range p.Vertices {
+			uv := 0
+			if v.UV != nil {
+				uv = index(uvs, "vt", &Vector{X: v.UV.U, Y: v.UV.V})
+			}
+			faces = append(faces, index(positions, "v", v.Position), uv, index(normals, "vn", v.Normal))
+		}
+
+		if mtl != nil {
+			name := materialName(p.Shared)
+			if name == "" && material != "" {
+				name = "default"
+			}
+			if name != material {
+				if !seen[name] {
+					seen[name] = true
+					m := &objMaterial{name: name}
+					if p.Shared != nil {
+						m.color = p.Shared.Color
+					}
+					materials = append(materials, m)
+				}
+				fmt.Fprintf(w, "usemtl %s\n", name)
+				material = name
+			}
+		}
+
+		f := 0
+		if p.Shared != nil {
+			f = p.Shared.FeatureID
+		}
+		if f != feature {
+			fmt.Fprintf(w, "g feature_%d\n", f)
+			feature = f
+		}
+
+		fmt.Fprintf(w, "f")
+		for i := 0; i < len(faces); i += 3 {
+			if faces[i+1] != 0 {
+				fmt.Fprintf(w, " %d/%d/%d", faces[i], faces[i+1], faces[i+2])
+			} else {
+				fmt.Fprintf(w, " %d//%d", faces[i], faces[i+2])
+			}
+		}
+		fmt.Fprintf(w, "\n")
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	if mtl != nil {
+		return marshalMaterialsToMTL(mtl, materials)
+	}
+	return nil
+}
+
+// marshalMaterialsToMTL writes the materials to a Wavefront material library
+func marshalMaterialsToMTL(out io.Writer, materials []*objMaterial) error {
+	w := bufio.NewWriter(out)
+	for _, m := range materials {
+		c := color.NRGBA{R: 204, G: 204, B: 204, A: 255}
+		if m.color != nil {
+			c = color.NRGBAModel.Convert(m.color).(color.NRGBA)
+		}
+		fmt.Fprintf(w, "newmtl %s\n", m.name)
+		fmt.Fprintf(w, "Kd %g %g %g\n", float64(c.R)/255, float64(c.G)/255, float64(c.B)/255)
+		fmt.Fprintf(w, "d %g\n\n", float64(c.A)/255)
+	}
+	return w.Flush()
+}
@@ -0,0 +1,69 @@
+package csg
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// WriteOFF writes the points and faces to an Object File Format (OFF) file as used by Geomview,
+// each face is the indices of it's points
+func WriteOFF(out io.Writer, points []*Vector, faces [][]int) error {
+	w := bufio.NewWriter(out)
+	fmt.Fprintf(w, "OFF\n%d %d 0\n", len(points), len(faces))
+	for _, p := range points {
+		fmt.Fprintf(w, "%g %g %g\n", p.X, p.Y, p.Z)
+	}
+	for _, f := range faces {
+		fmt.Fprintf(w, "%d", len(f))
+		for _, i := range f {
+			fmt.Fprintf(w, " %d", i)
+		}
+		fmt.Fprintf(w, "\n")
+	}
+	return w.Flush()
+}
+
+// WriteVTK writes the points and faces to a legacy ASCII VTK file as polygonal data, as used by
+// ParaView, each face is the indices of it's points. The title is written to the header of the
+// file and must fit on a single line.
+func WriteVTK(out io.Writer, title string, points []*Vector, faces [][]int) error {
+	if strings.ContainsAny(title, "\r\n") || len(title) > 255 {
+		return fmt.Errorf("vtk: the title must be a single line of at most 255 characters")
+	}
+	size := 0
+	for _, f := range faces {
+		size += len(f) + 1
+	}
+
+	w := bufio.NewWriter(out)
+	fmt.Fprintf(w, "# vtk DataFile Version 3.0\n%s\nASCII\nDATASET POLYDATA\n", title)
+	fmt.Fprintf(w, "POINTS %d double\n", len(points))
+	for _, p := range points {
+		fmt.Fprintf(w, "%g %g %g\n", p.X, p.Y, p.Z)
+	}
+	fmt.Fprintf(w, "POLYGONS %d %d\n", len(faces), size)
+	for _, f := range faces {
+		fmt.Fprintf(w, "%d", len(f))
+		for _, i := range f {
+			fmt.Fprintf(w, " %d", i)
+		}
+		fmt.Fprintf(w, "\n")
+	}
+	return w.Flush()
+}
+
+// MarshalToOFF writes this CSG out as an OFF file, polygons are written without being triangulated
+// and vertices with the same position are welded together
+func (c *CSG) MarshalToOFF(out io.Writer) error {
+	points, faces := indexedPolygons(c)
+	return WriteOFF(out, points, faces)
+}
+
+// MarshalToVTK writes this CSG out as a legacy VTK file, polygons are written without being
+// triangulated and vertices with the same position are welded together
+func (c *CSG) MarshalToVTK(out io.Writer, title string) error {
+	points, faces := indexedPolygons(c)
+	return WriteVTK(out, title, points, faces)
+}
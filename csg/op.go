@@ -0,0 +1,396 @@
+package csg
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"hash"
+	"math"
+	"sync"
+)
+
+// OpType signifies the type of operation represented by an Op
+type OpType int
+
+const (
+	// OpPrimitive is a leaf which produces a cube, sphere, cylinder or existing mesh
+	OpPrimitive OpType = iota
+	// OpUnion unions all of the children together
+	OpUnion
+	// OpSubtract subtracts all but the first child from the first child
+	OpSubtract
+	// OpIntersect intersects all of the children
+	OpIntersect
+	// OpTransform transforms the single child by the matrix
+	OpTransform
+)
+
+// String returns a string representation of the operation type
+func (t OpType) String() string {
+	switch t {
+	case OpPrimitive:
+		return "primitive"
+	case OpUnion:
+		return "union"
+	case OpSubtract:
+		return "subtract"
+	case OpIntersect:
+		return "intersect"
+	case OpTransform:
+		return "transform"
+	}
+	return fmt.Sprintf("OpType(%d)", int(t))
+}
+
+// OpHash is the content hash of an Op and all of it's children
+type OpHash [sha256.Size]byte
+
+// Op is a node in a lazily evaluated CSG expression tree. Nothing is computed when the tree is
+// constructed, instead an Evaluator evaluates the tree on demand and caches the result of each
+// subtree by it's content hash, so changing a parameter only re-evaluates the branches which
+// depend upon it.
+//
+// The parameters of an Op (such as the Cube options or the Matrix) may be modified in place
+// between evaluations.
+type Op struct {
+	// Type of this operation
+	Type OpType
+	// Children are the operands of this operation
+	Children []*Op
+	// Matrix is the transformation used by OpTransform
+	Matrix *Matrix
+	// Cube is the options used by a cube primitive
+	Cube *CubeOptions
+	// Sphere is the options used by a sphere primitive
+	Sphere *SphereOptions
+	// Cylinder is the options used by a cylinder primitive
+	Cylinder *CylinderOptions
+	// Mesh is an existing mesh used as a primitive
+	Mesh *CSG
+}
+
+// NewCubeOp returns a primitive operation which produces a cube
+func NewCubeOp(options *CubeOptions) *Op {
+	if options == nil {
+		options = &CubeOptions{}
+	}
+	return &Op{Type: OpPrimitive, Cube: options}
+}
+
+// NewSphereOp returns a primitive operation which produces a sphere
+func NewSphereOp(options *SphereOptions) *Op {
+	if options == nil {
+		options = &SphereOptions{}
+	}
+	return &Op{Type: OpPrimitive, Sphere: options}
+}
+
+// NewCylinderOp returns a primitive operation which produces a cylinder
+func NewCylinderOp(options *CylinderOptions) *Op {
+	if options == nil {
+		options = &CylinderOptions{}
+	}
+	return &Op{Type: OpPrimitive, Cylinder: options}
+}
+
+// NewMeshOp returns a primitive operation which produces an existing mesh
+func NewMeshOp(mesh *CSG) *Op {
+	return &Op{Type: OpPrimitive, Mesh: mesh}
+}
+
+// Union returns a new operation which unions this operation with the others
+func (o *Op) Union(others ...*Op) *Op {
+	return &Op{Type: OpUnion, Children: append([]*Op{o}, others...)}
+}
+
+// Subtract returns a new operation which subtracts the others from this operation
+func (o *Op) Subtract(others ...*Op) *Op {
+	return &Op{Type: OpSubtract, Children: append([]*Op{o}, others...)}
+}
+
+// Intersect returns a new operation which intersects this operation with the others
+func (o *Op) Intersect(others ...*Op) *Op {
+	return &Op{Type: OpIntersect, Children: append([]*Op{o}, others...)}
+}
+
+// Transform returns a new operation which transforms this operation by the matrix
+func (o *Op) Transform(m *Matrix) *Op {
+	return &Op{Type: OpTransform, Children: []*Op{o}, Matrix: m}
+}
+
+// Translate returns a new operation which translates this operation by the vector
+func (o *Op) Translate(v *Vector) *Op {
+	return o.Transform(NewTranslationMatrix(v))
+}
+
+// Scale returns a new operation which scales this operation by the vector
+func (o *Op) Scale(v *Vector) *Op {
+	return o.Transform(NewScalingMatrix(v))
+}
+
+// Rotate returns a new operation which rotates this operation around the axis by the angle (in degrees)
+func (o *Op) Rotate(axis *Vector, degrees float64) *Op {
+	return o.Transform(NewRotationMatrix(axis, degrees))
+}
+
+// Hash returns the content hash of this operation and all of it's children
+func (o *Op) Hash() OpHash {
+	return o.hash(make(map[*Op]OpHash))
+}
+
+func (o *Op) hash(seen map[*Op]OpHash) OpHash {
+	if h, ok := seen[o]; ok {
+		return h
+	}
+	w := &opHasher{h: sha256.New()}
+	w.int(int64(o.Type))
+	switch o.Type {
+	case OpPrimitive:
+		switch {
+		case o.Cube != nil:
+			w.string("cube")
+			w.vector(o.Cube.Center)
+			w.vector(o.Cube.Size)
+		case o.Sphere != nil:
+			w.string("sphere")
+			w.vector(o.Sphere.Center)
+			w.float(o.Sphere.Radius)
+			w.int(int64(o.Sphere.Slices))
+			w.int(int64(o.Sphere.Stacks))
+		case o.Cylinder != nil:
+			w.string("cylinder")
+			w.vector(o.Cylinder.Start)
+			w.vector(o.Cylinder.End)
+			w.float(o.Cylinder.Radius)
+			w.int(int64(o.Cylinder.Slices))
+		case o.Mesh != nil:
+			w.string("mesh")
+			w.int(int64(len(o.Mesh.polygons)))
+			for _, p := range o.Mesh.polygons {
+				w.int(int64(len(p.Vertices)))
+				for _, v := range p.Vertices {
+					w.vertex(v)
+				}
+				w.shared(p.Shared)
+			}
+		default:
+			w.string("empty")
+		}
+	case OpTransform:
+		if o.Matrix != nil {
+			for _, f := range o.Matrix {
+				w.float(f)
+			}
+		}
+	}
+	w.int(int64(len(o.Children)))
+	for _, c := range o.Children {
+		h := c.hash(seen)
+		w.h.Write(h[:])
+	}
+	var h OpHash
+	copy(h[:], w.h.Sum(nil))
+	seen[o] = h
+	return h
+}
+
+// opHasher writes the parameters of an operation into a hash
+type opHasher struct {
+	h   hash.Hash
+	buf [8]byte
+}
+
+func (w *opHasher) int(i int64) {
+	binary.LittleEndian.PutUint64(w.buf[:], uint64(i))
+	w.h.Write(w.buf[:])
+}
+
+func (w *opHasher) float(f float64) {
+	binary.LittleEndian.PutUint64(w.buf[:], math.Float64bits(f))
+	w.h.Write(w.buf[:])
+}
+
+func (w *opHasher) string(s string) {
+	w.int(int64(len(s)))
+	w.h.Write([]byte(s))
+}
+
+func (w *opHasher) vertex(v *Vertex) {
+	w.vector(v.Position)
+	w.vector(v.Normal)
+	if v.UV != nil {
+		w.int(1)
+		w.float(v.UV.U)
+		w.float(v.UV.V)
+	} else {
+		w.int(0)
+	}
+	if v.Color != nil {
+		w.int(1)
+		w.float(v.Color.R)
+		w.float(v.Color.G)
+		w.float(v.Color.B)
+		w.float(v.Color.A)
+	} else {
+		w.int(0)
+	}
+	w.int(int64(len(v.Channels)))
+	for _, c := range v.Channels {
+		w.float(c)
+	}
+}
+
+func (w *opHasher) shared(s *Shared) {
+	if s == nil {
+		w.int(0)
+		return
+	}
+	w.int(1)
+	if s.Color != nil {
+		r, g, b, a := s.Color.RGBA()
+		w.int(int64(r)<<48 | int64(g)<<32 | int64(b)<<16 | int64(a))
+	} else {
+		w.int(-1)
+	}
+	w.string(s.Material)
+	w.int(int64(s.FeatureID))
+}
+
+func (w *opHasher) vector(v *Vector) {
+	if v == nil {
+		w.int(0)
+		return
+	}
+	w.int(1)
+	w.float(v.X)
+	w.float(v.Y)
+	w.float(v.Z)
+}
+
+// Evaluator evaluates Op trees, caching the result of every subtree by it's content hash so
+// that subsequent evaluations only recompute the subtrees which have changed. An Evaluator
+// may be used from multiple goroutines.
+type Evaluator struct {
+	mutex  sync.Mutex
+	cache  map[OpHash]*CSG
+	hits   int
+	misses int
+}
+
+// NewEvaluator constructs a new evaluator with an empty cache
+func NewEvaluator() *Evaluator {
+	return &Evaluator{cache: make(map[OpHash]*CSG)}
+}
+
+// Evaluate evaluates the operation, returning a new CSG which the caller is free to modify
+func (e *Evaluator) Evaluate(o *Op) *CSG {
+	return e.evaluate(o, make(map[*Op]OpHash)).deepClone()
+}
+
+func (e *Evaluator) evaluate(o *Op, seen map[*Op]OpHash) *CSG {
+	h := o.hash(seen)
+
+	e.mutex.Lock()
+	if c, ok := e.cache[h]; ok {
+		e.hits++
+		e.mutex.Unlock()
+		return c
+	}
+	e.misses++
+	e.mutex.Unlock()
+
+	var c *CSG
+	switch o.Type {
+	case OpPrimitive:
+		switch {
+		case o.Cube != nil:
+			c = NewCube(o.Cube)
+		case o.Sphere != nil:
+			c = NewSphere(o.Sphere)
+		case o.Cylinder != nil:
+			c = NewCylinder(o.Cylinder)
+		case o.Mesh != nil:
+			c = o.Mesh.deepClone()
+		default:
+			c = &CSG{}
+		}
+	case OpTransform:
+		c = e.child(o, 0, seen)
+		if o.Matrix != nil {
+			c = c.Transform(o.Matrix)
+		}
+	case OpUnion, OpSubtract, OpIntersect:
+		c = e.child(o, 0, seen)
+		for i := 1; i < len(o.Children); i++ {
+			other := e.child(o, i, seen)
+			switch o.Type {
+			case OpUnion:
+				c = c.Union(other)
+			case OpSubtract:
+				c = c.Subtract(other)
+			case OpIntersect:
+				c = c.Intersect(other)
+			}
+		}
+	default:
+		c = &CSG{}
+	}
+
+	e.mutex.Lock()
+	e.cache[h] = c
+	e.mutex.Unlock()
+	return c
+}
+
+func (e *Evaluator) child(o *Op, i int, seen map[*Op]OpHash) *CSG {
+	if i >= len(o.Children) {
+		return &CSG{}
+	}
+	return e.evaluate(o.Children[i], seen)
+}
+
+// Stats returns the number of cache hits and misses since this evaluator was created
+func (e *Evaluator) Stats() (hits, misses int) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	return e.hits, e.misses
+}
+
+// Len returns the number of cached subtrees
+func (e *Evaluator) Len() int {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	return len(e.cache)
+}
+
+// Prune removes every cached result which isn't part of the specified operation trees, this is
+// useful to bound the size of the cache after parameters have changed
+func (e *Evaluator) Prune(ops ...*Op) {
+	seen := make(map[*Op]OpHash)
+	keep := make(map[OpHash]bool)
+	var walk func(o *Op)
+	walk = func(o *Op) {
+		keep[o.hash(seen)] = true
+		for _, c := range o.Children {
+			walk(c)
+		}
+	}
+	for _, o := range ops {
+		walk(o)
+	}
+
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	for h := range e.cache {
+		if !keep[h] {
+			delete(e.cache, h)
+		}
+	}
+}
+
+// Purge removes all cached results
+func (e *Evaluator) Purge() {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.cache = make(map[OpHash]*CSG)
+}
@@ -0,0 +1,134 @@
+package csg
+
+import (
+	"context"
+	"math"
+)
+
+// Progress reports the progress of a boolean operation
+type Progress struct {
+	// Polygons is the number of polygons which have been processed so far, polygons are
+	// counted each time they are split by a node in a BSP tree so this will exceed the
+	// number of polygons in the operands
+	Polygons int
+	// Depth is the depth of the BSP tree node currently being processed
+	Depth int
+}
+
+// ProgressFunc is called periodically to report the progress of a boolean operation, when used with
+// UnionAll, IntersectAll or SubtractAll it may be called from multiple goroutines
+type ProgressFunc func(p Progress)
+
+type progressKey struct{}
+
+// WithProgress returns a copy of the context which will report the progress of any boolean
+// operation it's passed to through the progress function
+func WithProgress(ctx context.Context, progress ProgressFunc) context.Context {
+	return context.WithValue(ctx, progressKey{}, progress)
+}
+
+// operation carries the state of a single boolean operation through the BSP tree
+type operation struct {
+	ctx      context.Context
+	progress ProgressFunc
+	selector PlaneSelector
+	splitter IPolygonSplitter
+	stats    *BSPStats
+	// epsilon is the tolerance used by the default splitter, zero uses EPSILON
+	epsilon       float64
+	autoTolerance bool
+	concurrency   int
+	polygons      int
+	err           error
+}
+
+func newOperation(ctx context.Context, options *Options) *operation {
+	op := &operation{ctx: ctx}
+	if p, ok := ctx.Value(progressKey{}).(ProgressFunc); ok {
+		op.progress = p
+	}
+	if options != nil {
+		op.selector = options.PlaneSelector
+		op.splitter = options.Splitter
+		op.epsilon = options.Tolerance
+		op.autoTolerance = options.AutoTolerance
+		op.concurrency = options.MaxConcurrency
+		op.stats = options.Stats
+		if op.stats != nil {
+			*op.stats = BSPStats{}
+		}
+	}
+	return op
+}
+
+// scale sets the tolerance of the operation from the size of the operands when automatic tolerance is enabled
+func (op *operation) scale(boxes ...*Box) {
+	if !op.autoTolerance || len(boxes) == 0 {
+		return
+	}
+	bounds := &Box{Min: boxes[0].Min, Max: boxes[0].Max}
+	for _, b := range boxes[1:] {
+		bounds.AddVector(&b.Min)
+		bounds.AddVector(&b.Max)
+	}
+	size := bounds.Size()
+	op.epsilon = EPSILON * math.Max(size.X, math.Max(size.Y, size.Z))
+}
+
+// tolerance returns the tolerance used by the operation
+func (op *operation) tolerance() float64 {
+	if op.epsilon == 0 {
+		return EPSILON
+	}
+	return op.epsilon
+}
+
+// cancelled returns true if the context for this operation has been cancelled
+func (op *operation) cancelled() bool {
+	if op.err != nil {
+		return true
+	}
+	if op.ctx == nil {
+		return false
+	}
+	select {
+	case <-op.ctx.Done():
+		op.err = op.ctx.Err()
+		return true
+	default:
+		return false
+	}
+}
+
+// selectPlane selects the plane to divide the polygons by when building a node of a BSP tree
+func (op *operation) selectPlane(polygons []*Polygon) *Plane {
+	if op.selector == nil {
+		return polygons[0].Plane.Clone()
+	}
+	return op.selector.SelectPlane(polygons)
+}
+
+// node records that a node has been added to a BSP tree at the specified depth
+func (op *operation) node(depth int) {
+	if op.stats != nil {
+		op.stats.Nodes++
+		if depth+1 > op.stats.Depth {
+			op.stats.Depth = depth + 1
+		}
+	}
+}
+
+// split records the number of polygons before and after splitting polygons by a plane
+func (op *operation) split(before, after int) {
+	if op.stats != nil && after > before {
+		op.stats.Splits += after - before
+	}
+}
+
+// processed records that the polygons have been processed at the specified depth in the BSP tree
+func (op *operation) processed(polygons int, depth int) {
+	op.polygons += polygons
+	if op.progress != nil {
+		op.progress(Progress{Polygons: op.polygons, Depth: depth})
+	}
+}
@@ -0,0 +1,113 @@
+package csg
+
+import (
+	"context"
+	"fmt"
+)
+
+// Options control how boolean operations and primitives are performed, allowing each operation
+// to be tuned individually. The tolerance is also used to build hulls, see qhull.Hull.
+type Options struct {
+	// PlaneSelector chooses the planes used to divide polygons when building BSP trees, if
+	// not specified the plane of the first polygon is used
+	PlaneSelector PlaneSelector
+	// Splitter splits polygons by the planes of the BSP trees, if not specified the
+	// BasicPolygonSplitter is used. Use the RobustPolygonSplitter for models with coincident
+	// faces, or which are very large or very small.
+	Splitter IPolygonSplitter
+	// Tolerance is the distance within which a point is considered to be on a plane by the
+	// default splitter, if not specified EPSILON is used
+	Tolerance float64
+	// AutoTolerance derives the tolerance from the size of the operands, so that models of any
+	// scale are treated as a model of unit size would be with EPSILON, this overrides Tolerance
+	AutoTolerance bool
+	// MaxConcurrency is the maximum number of goroutines used to split polygons and to combine
+	// independent branches of UnionAll, IntersectAll and SubtractAll, if not specified the
+	// number of CPUs is used. A value of 1 performs everything on the calling goroutine.
+	MaxConcurrency int
+	// Stats if specified is populated with statistics about the BSP trees built by the
+	// most recent Union, Subtract or Intersect performed with these options
+	Stats *BSPStats
+}
+
+// Union is the same as CSG.UnionCtx, but uses these options
+func (o *Options) Union(ctx context.Context, a, b *CSG) (*CSG, error) {
+	return a.union(newOperation(ctx, o), b)
+}
+
+// Subtract is the same as CSG.SubtractCtx, but uses these options
+func (o *Options) Subtract(ctx context.Context, a, b *CSG) (*CSG, error) {
+	return a.subtract(newOperation(ctx, o), b)
+}
+
+// Intersect is the same as CSG.IntersectCtx, but uses these options
+func (o *Options) Intersect(ctx context.Context, a, b *CSG) (*CSG, error) {
+	return a.intersect(newOperation(ctx, o), b)
+}
+
+// UnionAll is the same as UnionAllCtx, but uses these options
+func (o *Options) UnionAll(ctx context.Context, csgs []*CSG) (*CSG, error) {
+	return unionAll(ctx, o, csgs)
+}
+
+// IntersectAll is the same as IntersectAllCtx, but uses these options
+func (o *Options) IntersectAll(ctx context.Context, csgs []*CSG) (*CSG, error) {
+	return intersectAll(ctx, o, csgs)
+}
+
+// SubtractAll is the same as SubtractAllCtx, but uses these options
+func (o *Options) SubtractAll(ctx context.Context, base *CSG, tools []*CSG) (*CSG, error) {
+	return subtractAll(ctx, o, base, tools)
+}
+
+// Cube is the same as NewCube, but returns an error if the cube has edges no longer than the tolerance
+func (o *Options) Cube(options *CubeOptions) (*CSG, error) {
+	return o.primitive("cube", NewCube(options))
+}
+
+// Sphere is the same as NewSphere, but returns an error if the sphere has edges no longer than the tolerance
+func (o *Options) Sphere(options *SphereOptions) (*CSG, error) {
+	return o.primitive("sphere", NewSphere(options))
+}
+
+// Cylinder is the same as NewCylinder, but returns an error if the cylinder has edges no longer than the tolerance
+func (o *Options) Cylinder(options *CylinderOptions) (*CSG, error) {
+	return o.primitive("cylinder", NewCylinder(options))
+}
+
+// primitive checks that the edges of a primitive are longer than the tolerance, booleans treat
+// points within the tolerance of a plane as on it so shorter edges can't be split reliably
+func (o *Options) primitive(name string, c *CSG) (*CSG, error) {
+	op := &operation{}
+	if o != nil {
+		op.epsilon, op.autoTolerance = o.Tolerance, o.AutoTolerance
+	}
+	op.scale(c.BoundingBox())
+	tolerance := op.tolerance()
+	for _, p := range c.polygons {
+		for i, v := range p.Vertices {
+			if v.Position.Minus(p.Vertices[(i+1)%len(p.Vertices)].Position).Length() <= tolerance {
+				return nil, fmt.Errorf("csg: %s has edges no longer than the tolerance %g", name, tolerance)
+			}
+		}
+	}
+	return c, nil
+}
+
+// concurrency returns the maximum number of goroutines to use
+func (o *Options) concurrency() int {
+	if o == nil {
+		return 0
+	}
+	return o.MaxConcurrency
+}
+
+// concurrent returns options which are safe to use for operations performed concurrently
+func (o *Options) concurrent() *Options {
+	if o == nil || o.Stats == nil {
+		return o
+	}
+	c := *o
+	c.Stats = nil
+	return &c
+}
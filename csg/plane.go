@@ -23,6 +23,10 @@ type Plane struct {
 	Normal *Vector
 	// W of the plane
 	W float64
+
+	// points on the plane in the order which produces the normal, if known, these are used by
+	// the robust predicates as they define the plane exactly, unlike Normal and W
+	points [3]*Vector
 }
 
 // NewPlaneFromPoints construct a new plane from 3 vectors (as points)
@@ -34,7 +38,7 @@ func NewPlaneFromPoints(a *Vector, b *Vector, c *Vector) *Plane {
 
 // Clone the plane
 func (p *Plane) Clone() *Plane {
-	return &Plane{Normal: p.Normal.Clone(), W: p.W}
+	return &Plane{Normal: p.Normal.Clone(), W: p.W, points: p.points}
 }
 
 // SetFromPoints set the Normal and W for this plane from the specified vectors (as points)
@@ -42,12 +46,14 @@ func (p *Plane) SetFromPoints(a *Vector, b *Vector, c *Vector) {
 	n := b.Minus(a).Cross(c.Minus(a)).Unit()
 	p.Normal = n
 	p.W = n.Dot(a)
+	p.points = [3]*Vector{a, b, c}
 }
 
 // Flip flips the normal of this plane
 func (p *Plane) Flip() {
 	p.Normal = p.Normal.Negated()
 	p.W = -p.W
+	p.points[0], p.points[1] = p.points[1], p.points[0]
 }
 
 //DistancesToPlane calculates the distance of each specified vector (as a point) from the plane
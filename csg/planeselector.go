@@ -0,0 +1,146 @@
+package csg
+
+import (
+	"math"
+)
+
+// PlaneSelector chooses the plane used to divide a set of polygons when building a BSP tree, the
+// choice of plane determines how balanced the resulting tree is and how many polygons are split
+type PlaneSelector interface {
+	// SelectPlane returns the plane which should be used to divide the polygons, the returned
+	// plane will be modified so it must not be shared with any of the polygons
+	SelectPlane(polygons []*Polygon) *Plane
+}
+
+// FirstPolygonPlaneSelector selects the plane of the first polygon, which is fast but can lead to
+// unbalanced trees and many unnecessary splits
+type FirstPolygonPlaneSelector struct {
+}
+
+// SelectPlane returns the plane of the first polygon
+func (s *FirstPolygonPlaneSelector) SelectPlane(polygons []*Polygon) *Plane {
+	return polygons[0].Plane.Clone()
+}
+
+// SampledPlaneSelector scores the planes of a sample of the polygons and selects the plane with
+// the lowest score, where the score is a weighted sum of the number of polygons the plane would
+// split and the difference between the number of polygons in front of and behind the plane
+type SampledPlaneSelector struct {
+	// Candidates is the number of polygons whose planes are considered, defaults to 16
+	Candidates int
+	// Samples is the maximum number of polygons used to score each candidate, defaults to 100
+	Samples int
+	// SplitWeight is the weight given to the number of polygons split, defaults to 16
+	SplitWeight float64
+	// BalanceWeight is the weight given to the imbalance between the front and back, defaults to 1
+	BalanceWeight float64
+}
+
+// SelectPlane returns the best scoring plane from a sample of the polygons
+func (s *SampledPlaneSelector) SelectPlane(polygons []*Polygon) *Plane {
+	return s.selectFrom(polygons, polygons)
+}
+
+func (s *SampledPlaneSelector) selectFrom(candidates, polygons []*Polygon) *Plane {
+	numCandidates := s.Candidates
+	if numCandidates <= 0 {
+		numCandidates = 16
+	}
+	numSamples := s.Samples
+	if numSamples <= 0 {
+		numSamples = 100
+	}
+	splitWeight := s.SplitWeight
+	if splitWeight == 0 {
+		splitWeight = 16
+	}
+	balanceWeight := s.BalanceWeight
+	if balanceWeight == 0 {
+		balanceWeight = 1
+	}
+
+	samples := sample(polygons, numSamples, 1)
+
+	var best *Plane
+	bestScore := math.Inf(1)
+	for _, c := range sample(candidates, numCandidates, 0) {
+		front, back, spanning := countPlaneRelationships(c.Plane, samples)
+		score := splitWeight*float64(spanning) + balanceWeight*math.Abs(float64(front-back))
+		if score < bestScore {
+			bestScore = score
+			best = c.Plane
+		}
+	}
+	return best.Clone()
+}
+
+// AxisAlignedPlaneSelector prefers planes which are perpendicular to the X, Y or Z axis, which
+// typically splits very few polygons for mostly axis aligned parts. The axis aligned candidates
+// are scored in the same way as the SampledPlaneSelector, and if none of the polygons are axis
+// aligned the SampledPlaneSelector is used.
+type AxisAlignedPlaneSelector struct {
+	SampledPlaneSelector
+}
+
+// SelectPlane returns the best scoring axis aligned plane from a sample of the polygons
+func (s *AxisAlignedPlaneSelector) SelectPlane(polygons []*Polygon) *Plane {
+	aligned := make([]*Polygon, 0)
+	for _, p := range polygons {
+		n := p.Plane.Normal
+		if math.Abs(n.X) > 1-EPSILON || math.Abs(n.Y) > 1-EPSILON || math.Abs(n.Z) > 1-EPSILON {
+			aligned = append(aligned, p)
+		}
+	}
+	if len(aligned) == 0 {
+		return s.SampledPlaneSelector.SelectPlane(polygons)
+	}
+	return s.selectFrom(aligned, polygons)
+}
+
+// sample returns at most n polygons evenly spread through the slice, the offset shifts the
+// sampled polygons so that different samples can be taken from the same slice
+func sample(polygons []*Polygon, n int, offset int) []*Polygon {
+	if len(polygons) <= n {
+		return polygons
+	}
+	step := float64(len(polygons)) / float64(n)
+	r := make([]*Polygon, n)
+	for i := range r {
+		r[i] = polygons[(int(float64(i)*step)+offset)%len(polygons)]
+	}
+	return r
+}
+
+// countPlaneRelationships counts the number of polygons which are in front of, behind or spanning the plane
+func countPlaneRelationships(plane *Plane, polygons []*Polygon) (front, back, spanning int) {
+	for _, p := range polygons {
+		var polygonType PlaneRelationship
+		for _, v := range p.Vertices {
+			t := plane.Normal.Dot(v.Position) - plane.W
+			if t < -EPSILON {
+				polygonType |= BACK
+			} else if t > EPSILON {
+				polygonType |= FRONT
+			}
+		}
+		switch polygonType {
+		case FRONT:
+			front++
+		case BACK:
+			back++
+		case SPANNING:
+			spanning++
+		}
+	}
+	return front, back, spanning
+}
+
+// BSPStats are statistics about the BSP trees built during an operation
+type BSPStats struct {
+	// Depth is the maximum depth of the BSP trees
+	Depth int
+	// Nodes is the total number of nodes in the BSP trees
+	Nodes int
+	// Splits is the number of polygons which were split while building and clipping
+	Splits int
+}
@@ -0,0 +1,521 @@
+package csg
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"image/color"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// PLYFormat is the encoding used for the body of a PLY file
+type PLYFormat int
+
+const (
+	// PLY_ASCII encodes the body of the PLY as text
+	PLY_ASCII PLYFormat = iota
+	// PLY_BINARY_LITTLE_ENDIAN encodes the body of the PLY as little endian binary
+	PLY_BINARY_LITTLE_ENDIAN
+	// PLY_BINARY_BIG_ENDIAN encodes the body of the PLY as big endian binary
+	PLY_BINARY_BIG_ENDIAN
+)
+
+func (f PLYFormat) String() string {
+	switch f {
+	case PLY_ASCII:
+		return "ascii"
+	case PLY_BINARY_LITTLE_ENDIAN:
+		return "binary_little_endian"
+	case PLY_BINARY_BIG_ENDIAN:
+		return "binary_big_endian"
+	}
+	return fmt.Sprintf("PLYFormat(%d)", int(f))
+}
+
+func (f PLYFormat) byteOrder() binary.ByteOrder {
+	if f == PLY_BINARY_BIG_ENDIAN {
+		return binary.BigEndian
+	}
+	return binary.LittleEndian
+}
+
+// plyTypes maps the names of PLY scalar types to their size in bytes
+var plyTypes = map[string]int{
+	"char": 1, "int8": 1, "uchar": 1, "uint8": 1,
+	"short": 2, "int16": 2, "ushort": 2, "uint16": 2,
+	"int": 4, "int32": 4, "uint": 4, "uint32": 4,
+	"float": 4, "float32": 4, "double": 8, "float64": 8,
+}
+
+type plyProperty struct {
+	name string
+	typ  string
+	// countType is the type of the length of a list property, empty if this isn't a list
+	countType string
+}
+
+type plyElement struct {
+	name       string
+	count      int
+	properties []*plyProperty
+}
+
+// plyListCapacity is the most items allocated for a list before they've been read
+const plyListCapacity = 16
+
+// plyReader reads scalar values from the body of a PLY file
+type plyReader struct {
+	format PLYFormat
+	r      *bufio.Reader
+	words  *bufio.Scanner
+	buf    [8]byte
+}
+
+func (pr *plyReader) read(typ string) (float64, error) {
+	if pr.format == PLY_ASCII {
+		if !pr.words.Scan() {
+			if err := pr.words.Err(); err != nil {
+				return 0, err
+			}
+			return 0, io.ErrUnexpectedEOF
+		}
+		return strconv.ParseFloat(pr.words.Text(), 64)
+	}
+
+	b := pr.buf[:plyTypes[typ]]
+	if _, err := io.ReadFull(pr.r, b); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return 0, err
+	}
+	o := pr.format.byteOrder()
+	switch typ {
+	case "char", "int8":
+		return float64(int8(b[0])), nil
+	case "uchar", "uint8":
+		return float64(b[0]), nil
+	case "short", "int16":
+		return float64(int16(o.Uint16(b))), nil
+	case "ushort", "uint16":
+		return float64(o.Uint16(b)), nil
+	case "int", "int32":
+		return float64(int32(o.Uint32(b))), nil
+	case "uint", "uint32":
+		return float64(o.Uint32(b)), nil
+	case "float", "float32":
+		return float64(math.Float32frombits(o.Uint32(b))), nil
+	}
+	return math.Float64frombits(o.Uint64(b)), nil
+}
+
+// readPLYHeader reads the header of a PLY file, returning the format and the elements
+func readPLYHeader(r *bufio.Reader) (PLYFormat, []*plyElement, error) {
+	line := func() (string, error) {
+		l, err := r.ReadString('\n')
+		if err != nil && (err != io.EOF || l == "") {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return "", err
+		}
+		return strings.TrimRight(l, "\r\n"), nil
+	}
+
+	l, err := line()
+	if err != nil {
+		return 0, nil, err
+	}
+	if l != "ply" {
+		return 0, nil, fmt.Errorf("ply: not a PLY file")
+	}
+
+	format := PLYFormat(-1)
+	elements := make([]*plyElement, 0)
+	for {
+		l, err := line()
+		if err != nil {
+			return 0, nil, err
+		}
+		f := strings.Fields(l)
+		if len(f) == 0 {
+			continue
+		}
+		switch f[0] {
+		case "format":
+			if len(f) < 2 {
+				return 0, nil, fmt.Errorf("ply: invalid format %q", l)
+			}
+			for _, pf := range []PLYFormat{PLY_ASCII, PLY_BINARY_LITTLE_ENDIAN, PLY_BINARY_BIG_ENDIAN} {
+				if pf.String() == f[1] {
+					format = pf
+				}
+			}
+			if format < 0 {
+				return 0, nil, fmt.Errorf("ply: unsupported format %q", f[1])
+			}
+		case "element":
+			if len(f) != 3 {
+				return 0, nil, fmt.Errorf("ply: invalid element %q", l)
+			}
+			count, err := strconv.Atoi(f[2])
+			if err != nil || count < 0 {
+				return 0, nil, fmt.Errorf("ply: invalid element count %q", l)
+			}
+			elements = append(elements, &plyElement{name: f[1], count: count})
+		case "property":
+			if len(elements) == 0 {
+				return 0, nil, fmt.Errorf("ply: property %q before any element", l)
+			}
+			e := elements[len(elements)-1]
+			if len(f) == 5 && f[1] == "list" {
+				if plyTypes[f[2]] == 0 || plyTypes[f[3]] == 0 {
+					return 0, nil, fmt.Errorf("ply: invalid property %q", l)
+				}
+				e.properties = append(e.properties, &plyProperty{name: f[4], typ: f[3], countType: f[2]})
+			} else if len(f) == 3 && plyTypes[f[1]] != 0 {
+				e.properties = append(e.properties, &plyProperty{name: f[2], typ: f[1]})
+			} else {
+				return 0, nil, fmt.Errorf("ply: invalid property %q", l)
+			}
+		case "end_header":
+			if format < 0 {
+				return 0, nil, fmt.Errorf("ply: missing format")
+			}
+			return format, elements, nil
+		case "comment", "obj_info":
+		default:
+			return 0, nil, fmt.Errorf("ply: unexpected header line %q", l)
+		}
+	}
+}
+
+// ReadPLY reads the vertices and faces from a PLY file, each face is the indices of it's vertices.
+// Vertex positions, normals, colors and texture coordinates are read, and faces may have any number
+// of vertices. A vertex without a normal has a nil Normal. Any other elements and properties are ignored.
+func ReadPLY(in io.Reader) ([]*Vertex, [][]int, error) {
+	r := bufio.NewReader(in)
+	format, elements, err := readPLYHeader(r)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	pr := &plyReader{format: format, r: r}
+	if format == PLY_ASCII {
+		pr.words = bufio.NewScanner(r)
+		pr.words.Split(bufio.ScanWords)
+	}
+
+	vertices := make([]*Vertex, 0)
+	faces := make([][]int, 0)
+	for _, e := range elements {
+		values := make(map[string]float64)
+		types := make(map[string]string)
+		for _, p := range e.properties {
+			types[p.name] = p.typ
+		}
+		for i := 0; i < e.count; i++ {
+			var face []int
+			for _, p := range e.properties {
+				if p.countType == "" {
+					v, err := pr.read(p.typ)
+					if err != nil {
+						return nil, nil, fmt.Errorf("ply: reading %s %d: %v", e.name, i, err)
+					}
+					values[p.name] = v
+					continue
+				}
+				n, err := pr.read(p.countType)
+				if err != nil {
+					return nil, nil, fmt.Errorf("ply: reading %s %d: %v", e.name, i, err)
+				}
+				if n < 0 || n > math.MaxInt32 || n != math.Trunc(n) {
+					return nil, nil, fmt.Errorf("ply: reading %s %d: invalid list length %v", e.name, i, n)
+				}
+				// the length hasn't been checked against the size of the file, so the list only
+				// grows as items are read rather than being allocated up front
+				list := make([]int, 0, int(math.Min(n, plyListCapacity)))
+				for j := 0; j < int(n); j++ {
+					v, err := pr.read(p.typ)
+					if err != nil {
+						return nil, nil, fmt.Errorf("ply: reading %s %d: %v", e.name, i, err)
+					}
+					list = append(list, int(v))
+				}
+				if e.name == "face" && (p.name == "vertex_indices" || p.name == "vertex_index") {
+					face = list
+				}
+			}
+
+			switch e.name {
+			case "vertex":
+				vertices = append(vertices, plyVertex(types, values))
+			case "face":
+				faces = append(faces, face)
+			}
+		}
+	}
+
+	for i, f := range faces {
+		for _, v := range f {
+			if v < 0 || v >= len(vertices) {
+				return nil, nil, fmt.Errorf("ply: face %d references vertex %d of %d", i, v, len(vertices))
+			}
+		}
+	}
+	return vertices, faces, nil
+}
+
+// plyVertex creates a vertex from the values of the properties of a vertex element, has is the
+// type of each of the properties of the element
+func plyVertex(has map[string]string, values map[string]float64) *Vertex {
+	v := &Vertex{Position: &Vector{X: values["x"], Y: values["y"], Z: values["z"]}}
+	if _, ok := has["nx"]; ok {
+		v.Normal = &Vector{X: values["nx"], Y: values["ny"], Z: values["nz"]}
+	}
+	if typ, ok := has["red"]; ok {
+		// integer colors are 0 to 255, floating point colors are 0 to 1
+		scale := 255.0
+		if typ == "float" || typ == "float32" || typ == "double" || typ == "float64" {
+			scale = 1
+		}
+		v.Color = &Color{R: values["red"] / scale, G: values["green"] / scale, B: values["blue"] / scale, A: 1}
+		if _, ok := has["alpha"]; ok {
+			v.Color.A = values["alpha"] / scale
+		}
+	}
+	for _, uv := range [][2]string{{"s", "t"}, {"u", "v"}, {"texture_u", "texture_v"}} {
+		if _, ok := has[uv[0]]; ok {
+			v.UV = &UV{U: values[uv[0]], V: values[uv[1]]}
+			break
+		}
+	}
+	return v
+}
+
+// NewCSGFromPLY reads a mesh from a PLY file, faces with fewer than 3 vertices are ignored and
+// vertices without normals are given the normal of the face
+func NewCSGFromPLY(in io.Reader) (*CSG, error) {
+	vertices, faces, err := ReadPLY(in)
+	if err != nil {
+		return nil, err
+	}
+	polygons := make([]*Polygon, 0, len(faces))
+	for _, f := range faces {
+		if len(f) < 3 {
+			continue
+		}
+		vs := make([]*Vertex, len(f))
+		for i, index := range f {
+			v := vertices[index]
+			vs[i] = &Vertex{Position: v.Position.Clone(), Normal: v.Normal}
+			vs[i].copyAttributes(v)
+		}
+		p := NewPolygonFromVertices(vs)
+		for _, v := range vs {
+			if v.Normal == nil {
+				v.Normal = p.Plane.Normal.Clone()
+			} else {
+				v.Normal = v.Normal.Clone()
+			}
+		}
+		polygons = append(polygons, p)
+	}
+	return NewCSGFromPolygons(polygons), nil
+}
+
+// plyWriter writes scalar values to the body of a PLY file
+type plyWriter struct {
+	format PLYFormat
+	w      *bufio.Writer
+	buf    [8]byte
+	// first is true if no value has been written on the current line
+	first bool
+}
+
+func (pw *plyWriter) write(typ string, v float64) {
+	if pw.format == PLY_ASCII {
+		if !pw.first {
+			pw.w.WriteByte(' ')
+		}
+		pw.first = false
+		switch typ {
+		case "float":
+			pw.w.WriteString(strconv.FormatFloat(v, 'g', -1, 32))
+		case "double":
+			pw.w.WriteString(strconv.FormatFloat(v, 'g', -1, 64))
+		default:
+			pw.w.WriteString(strconv.FormatInt(int64(v), 10))
+		}
+		return
+	}
+
+	o := pw.format.byteOrder()
+	switch typ {
+	case "uchar":
+		pw.w.WriteByte(uint8(v))
+	case "int":
+		o.PutUint32(pw.buf[:], uint32(int32(v)))
+		pw.w.Write(pw.buf[:4])
+	case "float":
+		o.PutUint32(pw.buf[:], math.Float32bits(float32(v)))
+		pw.w.Write(pw.buf[:4])
+	case "double":
+		o.PutUint64(pw.buf[:], math.Float64bits(v))
+		pw.w.Write(pw.buf[:8])
+	}
+}
+
+func (pw *plyWriter) endLine() {
+	if pw.format == PLY_ASCII {
+		pw.w.WriteByte('\n')
+	}
+	pw.first = true
+}
+
+// WritePLY writes the vertices and faces to a PLY file, where each face is the indices of it's vertices.
+// Normals are written if every vertex has a normal, and colors and texture coordinates are written if
+// any vertex has them. If faces is nil only the vertices are written, as a point cloud.
+func WritePLY(out io.Writer, format PLYFormat, vertices []*Vertex, faces [][]int) error {
+	type property struct {
+		name  string
+		typ   string
+		value func(v *Vertex) float64
+	}
+	properties := []property{
+		{"x", "double", func(v *Vertex) float64 { return v.Position.X }},
+		{"y", "double", func(v *Vertex) float64 { return v.Position.Y }},
+		{"z", "double", func(v *Vertex) float64 { return v.Position.Z }},
+	}
+
+	normals, colors, uvs := len(vertices) > 0, false, false
+	for _, v := range vertices {
+		normals = normals && v.Normal != nil
+		colors = colors || v.Color != nil
+		uvs = uvs || v.UV != nil
+	}
+	if normals {
+		properties = append(properties,
+			property{"nx", "float", func(v *Vertex) float64 { return v.Normal.X }},
+			property{"ny", "float", func(v *Vertex) float64 { return v.Normal.Y }},
+			property{"nz", "float", func(v *Vertex) float64 { return v.Normal.Z }},
+		)
+	}
+	if colors {
+		component := func(i int) func(v *Vertex) float64 {
+			return func(v *Vertex) float64 {
+				if v.Color == nil {
+					return 255
+				}
+				c := color.NRGBAModel.Convert(v.Color).(color.NRGBA)
+				return float64([]uint8{c.R, c.G, c.B, c.A}[i])
+			}
+		}
+		properties = append(properties,
+			property{"red", "uchar", component(0)},
+			property{"green", "uchar", component(1)},
+			property{"blue", "uchar", component(2)},
+			property{"alpha", "uchar", component(3)},
+		)
+	}
+	if uvs {
+		uv := func(u bool) func(v *Vertex) float64 {
+			return func(v *Vertex) float64 {
+				if v.UV == nil {
+					return 0
+				} else if u {
+					return v.UV.U
+				}
+				return v.UV.V
+			}
+		}
+		properties = append(properties,
+			property{"s", "float", uv(true)},
+			property{"t", "float", uv(false)},
+		)
+	}
+
+	w := bufio.NewWriter(out)
+	fmt.Fprintf(w, "ply\nformat %s 1.0\n", format)
+	fmt.Fprintf(w, "element vertex %d\n", len(vertices))
+	for _, p := range properties {
+		fmt.Fprintf(w, "property %s %s\n", p.typ, p.name)
+	}
+	if faces != nil {
+		fmt.Fprintf(w, "element face %d\n", len(faces))
+		fmt.Fprintf(w, "property list uchar int vertex_indices\n")
+	}
+	fmt.Fprintf(w, "end_header\n")
+
+	pw := &plyWriter{format: format, w: w, first: true}
+	for _, v := range vertices {
+		for _, p := range properties {
+			pw.write(p.typ, p.value(v))
+		}
+		pw.endLine()
+	}
+	for i, f := range faces {
+		if len(f) > 255 {
+			return fmt.Errorf("ply: face %d has %d vertices, the maximum is 255", i, len(f))
+		}
+		pw.write("uchar", float64(len(f)))
+		for _, index := range f {
+			pw.write("int", float64(index))
+		}
+		pw.endLine()
+	}
+	return w.Flush()
+}
+
+// MarshalToPLY writes this CSG out as a PLY file, vertices which are shared by polygons are only
+// written once. Polygons are written without being triangulated, and the color of a vertex is its
+// Color or if it has none the color of the polygon from its Shared metadata.
+func (c *CSG) MarshalToPLY(out io.Writer, format PLYFormat) error {
+	type key struct {
+		position Vector
+		normal   Vector
+		color    Color
+		colored  bool
+		uv       UV
+		mapped   bool
+	}
+	indices := make(map[key]int)
+	vertices := make([]*Vertex, 0)
+	faces := make([][]int, 0, len(c.polygons))
+	for _, p := range c.polygons {
+		face := make([]int, len(p.Vertices))
+		for i, v := range p.Vertices {
+			k := key{position: *v.Position, normal: *v.Normal}
+			if v.Color != nil {
+				k.color, k.colored = *v.Color, true
+			} else if p.Shared != nil && p.Shared.Color != nil {
+				c := color.NRGBAModel.Convert(p.Shared.Color).(color.NRGBA)
+				k.color = Color{R: float64(c.R) / 255, G: float64(c.G) / 255, B: float64(c.B) / 255, A: float64(c.A) / 255}
+				k.colored = true
+			}
+			if v.UV != nil {
+				k.uv, k.mapped = *v.UV, true
+			}
+
+			index, ok := indices[k]
+			if !ok {
+				index = len(vertices)
+				indices[k] = index
+				nv := &Vertex{Position: v.Position, Normal: v.Normal}
+				if k.colored {
+					nv.Color = &k.color
+				}
+				if k.mapped {
+					nv.UV = &k.uv
+				}
+				vertices = append(vertices, nv)
+			}
+			face[i] = index
+		}
+		faces = append(faces, face)
+	}
+	return WritePLY(out, format, vertices, faces)
+}
@@ -2,6 +2,7 @@ package csg
 
 import (
 	"fmt"
+	"image/color"
 	"io"
 )
 
@@ -10,10 +11,24 @@ func NewTriangle(a *Vertex, b *Vertex, c *Vertex, plane *Plane) *Polygon {
 	return &Polygon{Vertices: []*Vertex{a, b, c}, Plane: plane}
 }
 
+// Shared is metadata which is shared by polygons, such as all of the polygons of one body. It's
+// carried through booleans to every fragment of a polygon so that the origin of each face of the
+// result can be determined.
+type Shared struct {
+	// Color of the polygons, if nil the polygons have no color
+	Color color.Color
+	// Material is the name of the material of the polygons
+	Material string
+	// FeatureID identifies the feature which created the polygons
+	FeatureID int
+}
+
 // Polygon is a 3 dimensional polygon with 3 or more vertices
 type Polygon struct {
 	Vertices []*Vertex
 	Plane    *Plane
+	// Shared is metadata shared with other polygons, which may be nil
+	Shared *Shared
 }
 
 // NewPolygonFromVertices creates a new polygon from a set of vertices
@@ -49,7 +64,11 @@ func triangulate(vertices []*Vertex, plane *Plane) []*Polygon {
 
 // Triangles returns a triangulation of this polygon
 func (p *Polygon) Triangles() []*Polygon {
-	return triangulate(p.Vertices, p.Plane)
+	t := triangulate(p.Vertices, p.Plane)
+	for _, tp := range t {
+		tp.Shared = p.Shared
+	}
+	return t
 }
 
 // IsTriangle returns true if this polygon is a triangle
@@ -63,7 +82,24 @@ func (p *Polygon) Clone() *Polygon {
 	for _, cp := range p.Vertices {
 		vs = append(vs, cp.Clone())
 	}
-	return NewPolygonFromVertices(vs)
+	return p.fragment(vs)
+}
+
+// fragment returns a new polygon from part of this polygon, which shares the same metadata
+func (p *Polygon) fragment(vertices []*Vertex) *Polygon {
+	f := NewPolygonFromVertices(vertices)
+	f.Shared = p.Shared
+	return f
+}
+
+// clone copies this polygon, it's vertices and it's plane, unlike Clone the plane is copied rather than
+// being recalculated from the vertices
+func (p *Polygon) clone() *Polygon {
+	vs := make([]*Vertex, len(p.Vertices))
+	for i, v := range p.Vertices {
+		vs[i] = v.Clone()
+	}
+	return &Polygon{Vertices: vs, Plane: p.Plane.Clone(), Shared: p.Shared}
 }
 
 // Flip flips the normal of this polygon by reversing the ordering of points and flipping the normal on the associated plane
@@ -75,6 +111,31 @@ func (p *Polygon) Flip() {
 	p.Plane.Flip()
 }
 
+// Transform returns a new polygon transformed by the matrix, if the matrix mirrors the geometry
+// the order of the vertices is reversed so the polygon continues to face outwards
+func (p *Polygon) Transform(m *Matrix) *Polygon {
+	vs := make([]*Vertex, len(p.Vertices))
+	for i, v := range p.Vertices {
+		vs[i] = v.Transform(m)
+	}
+	if m.IsMirroring() {
+		for i, j := 0, len(vs)-1; i < j; i, j = i+1, j-1 {
+			vs[i], vs[j] = vs[j], vs[i]
+		}
+	}
+	n := m.TransformNormal(p.Plane.Normal)
+	plane := &Plane{Normal: n, W: n.Dot(vs[0].Position)}
+	if p.Plane.points[0] != nil {
+		for i, v := range p.Plane.points {
+			plane.points[i] = m.TransformPoint(v)
+		}
+		if m.IsMirroring() {
+			plane.points[0], plane.points[1] = plane.points[1], plane.points[0]
+		}
+	}
+	return &Polygon{Vertices: vs, Plane: plane, Shared: p.Shared}
+}
+
 // MarshalToASCIISTL will write this polygon out as ASCII STL
 func (p *Polygon) MarshalToASCIISTL(out io.Writer) {
 	fmt.Fprintf(out, "facet normal %f %f %f\n", p.Plane.Normal.X, p.Plane.Normal.Y, p.Plane.Normal.Z)
@@ -13,11 +13,18 @@ type IPolygonSplitter interface {
 
 // BasicPolygonSplitter is a basic implemenation of a polygon splitter
 type BasicPolygonSplitter struct {
+	// Epsilon is the distance within which a point is considered to be on a plane, defaults to EPSILON
+	Epsilon float64
 }
 
 //SplitPolygons splits the polygons into various slices based upon their orientation to the specified plane
 func (ps *BasicPolygonSplitter) SplitPolygons(plane *Plane, polygons []*Polygon, coplanarFront, coplanarBack, front, back *[]*Polygon) {
 
+	epsilon := ps.Epsilon
+	if epsilon == 0 {
+		epsilon = EPSILON
+	}
+
 	types := make([]PlaneRelationship, 0, 20)
 	for _, polygon := range polygons {
 
@@ -27,9 +34,9 @@ func (ps *BasicPolygonSplitter) SplitPolygons(plane *Plane, polygons []*Polygon,
 		for _, v := range polygon.Vertices {
 			t := plane.Normal.Dot(v.Position) - plane.W
 			var pType PlaneRelationship
-			if t < (-EPSILON) {
+			if t < (-epsilon) {
 				pType = BACK
-			} else if t > EPSILON {
+			} else if t > epsilon {
 				pType = FRONT
 			} else {
 				pType = COPLANAR
@@ -82,10 +89,10 @@ func (ps *BasicPolygonSplitter) SplitPolygons(plane *Plane, polygons []*Polygon,
 				}
 			}
 			if len(f) >= 3 {
-				*front = append(*front, NewPolygonFromVertices(f))
+				*front = append(*front, polygon.fragment(f))
 			}
 			if len(b) >= 3 {
-				*back = append(*back, NewPolygonFromVertices(b))
+				*back = append(*back, polygon.fragment(b))
 			}
 			break
 		}
@@ -96,6 +103,8 @@ func (ps *BasicPolygonSplitter) SplitPolygons(plane *Plane, polygons []*Polygon,
 type MultiCorePolygonSplitter struct {
 	// This is the target splitter to use - which should normally use the BasicPolygonSplitter
 	Target IPolygonSplitter
+	// Concurrency is the maximum number of goroutines to use, defaults to the number of CPUs
+	Concurrency int
 }
 
 //SplitPolygons splits the polygons into various slices based upon their orientation to the specified plane
@@ -105,7 +114,10 @@ func (ps *MultiCorePolygonSplitter) SplitPolygons(plane *Plane, polygons []*Poly
 		var wg sync.WaitGroup
 		var lock sync.Mutex
 
-		cpus := runtime.NumCPU()
+		cpus := ps.Concurrency
+		if cpus <= 0 {
+			cpus = runtime.NumCPU()
+		}
 
 		batchSize := 500
 		start := 0
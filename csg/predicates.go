@@ -0,0 +1,150 @@
+package csg
+
+import (
+	"math"
+)
+
+// The predicates in this file follow Jonathan Shewchuk's "Adaptive Precision Floating-Point
+// Arithmetic and Fast Robust Geometric Predicates", a fast floating point approximation is
+// used whenever it's error bound proves the sign is correct, otherwise the result is computed
+// exactly using floating point expansions.
+//
+// See: https://www.cs.cmu.edu/~quake/robust.html
+
+// machineEpsilon is half of the distance between 1 and the next float64
+const machineEpsilon = 1.0 / (1 << 53)
+
+// orient3dErrorBound bounds the error of the floating point approximation in orientation
+const orient3dErrorBound = (7.0 + 56.0*machineEpsilon) * machineEpsilon
+
+// orientation returns a positive value if d is in front of the plane through a, b and c, a negative
+// value if it's behind and zero if it's exactly on the plane, where the front of the plane is the
+// direction of the normal (b-a)x(c-a). The sign of the result is always exact.
+func orientation(a, b, c, d *Vector) float64 {
+	adx, bdx, cdx := a.X-d.X, b.X-d.X, c.X-d.X
+	ady, bdy, cdy := a.Y-d.Y, b.Y-d.Y, c.Y-d.Y
+	adz, bdz, cdz := a.Z-d.Z, b.Z-d.Z, c.Z-d.Z
+
+	bdxcdy, cdxbdy := bdx*cdy, cdx*bdy
+	cdxady, adxcdy := cdx*ady, adx*cdy
+	adxbdy, bdxady := adx*bdy, bdx*ady
+
+	det := adz*(bdxcdy-cdxbdy) + bdz*(cdxady-adxcdy) + cdz*(adxbdy-bdxady)
+	permanent := (math.Abs(bdxcdy)+math.Abs(cdxbdy))*math.Abs(adz) +
+		(math.Abs(cdxady)+math.Abs(adxcdy))*math.Abs(bdz) +
+		(math.Abs(adxbdy)+math.Abs(bdxady))*math.Abs(cdz)
+	errBound := orient3dErrorBound * permanent
+	if det > errBound || -det > errBound {
+		return -det
+	}
+	return -orientationExact(a, b, c, d)
+}
+
+// orientationExact computes the determinant used by orientation exactly, it's slow and should only
+// be used when the floating point approximation can't be trusted
+func orientationExact(a, b, c, d *Vector) float64 {
+	adx, bdx, cdx := diffExpansion(a.X, d.X), diffExpansion(b.X, d.X), diffExpansion(c.X, d.X)
+	ady, bdy, cdy := diffExpansion(a.Y, d.Y), diffExpansion(b.Y, d.Y), diffExpansion(c.Y, d.Y)
+	adz, bdz, cdz := diffExpansion(a.Z, d.Z), diffExpansion(b.Z, d.Z), diffExpansion(c.Z, d.Z)
+
+	bc := sumExpansions(productExpansions(bdx, cdy), negateExpansion(productExpansions(cdx, bdy)))
+	ca := sumExpansions(productExpansions(cdx, ady), negateExpansion(productExpansions(adx, cdy)))
+	ab := sumExpansions(productExpansions(adx, bdy), negateExpansion(productExpansions(bdx, ady)))
+
+	det := sumExpansions(sumExpansions(productExpansions(adz, bc), productExpansions(bdz, ca)), productExpansions(cdz, ab))
+	if len(det) == 0 {
+		return 0
+	}
+	// the components are non-overlapping and in increasing order of magnitude, so the last
+	// component has the same sign as the sum
+	return det[len(det)-1]
+}
+
+// An expansion is a sum of float64 components which don't overlap, ordered by increasing
+// magnitude and without any zero components, which is able to represent sums and products
+// of float64 values exactly
+
+// twoSum returns the sum of a and b along with the roundoff error of that sum
+func twoSum(a, b float64) (x, y float64) {
+	x = a + b
+	bv := x - a
+	av := x - bv
+	y = (a - av) + (b - bv)
+	return x, y
+}
+
+// twoProduct returns the product of a and b along with the roundoff error of that product
+func twoProduct(a, b float64) (x, y float64) {
+	x = a * b
+	y = math.FMA(a, b, -x)
+	return x, y
+}
+
+// diffExpansion returns a-b as an expansion
+func diffExpansion(a, b float64) []float64 {
+	x, y := twoSum(a, -b)
+	return appendNonZero(appendNonZero(make([]float64, 0, 2), y), x)
+}
+
+// growExpansion adds b to the expansion
+func growExpansion(e []float64, b float64) []float64 {
+	h := make([]float64, 0, len(e)+1)
+	q := b
+	for _, c := range e {
+		var r float64
+		q, r = twoSum(q, c)
+		h = appendNonZero(h, r)
+	}
+	return appendNonZero(h, q)
+}
+
+// sumExpansions returns the sum of two expansions
+func sumExpansions(e, f []float64) []float64 {
+	for _, c := range f {
+		e = growExpansion(e, c)
+	}
+	return e
+}
+
+// scaleExpansion multiplies the expansion by b
+func scaleExpansion(e []float64, b float64) []float64 {
+	if len(e) == 0 {
+		return e
+	}
+	h := make([]float64, 0, 2*len(e))
+	q, r := twoProduct(e[0], b)
+	h = appendNonZero(h, r)
+	for _, c := range e[1:] {
+		p1, p0 := twoProduct(c, b)
+		s, r := twoSum(q, p0)
+		h = appendNonZero(h, r)
+		q, r = twoSum(p1, s)
+		h = appendNonZero(h, r)
+	}
+	return appendNonZero(h, q)
+}
+
+// productExpansions returns the product of two expansions
+func productExpansions(e, f []float64) []float64 {
+	var r []float64
+	for _, c := range f {
+		r = sumExpansions(r, scaleExpansion(e, c))
+	}
+	return r
+}
+
+// negateExpansion negates every component of the expansion
+func negateExpansion(e []float64) []float64 {
+	n := make([]float64, len(e))
+	for i, c := range e {
+		n[i] = -c
+	}
+	return n
+}
+
+func appendNonZero(e []float64, c float64) []float64 {
+	if c != 0 {
+		return append(e, c)
+	}
+	return e
+}
@@ -0,0 +1,268 @@
+package csg
+
+import (
+	"context"
+	"runtime"
+	"sort"
+	"sync"
+)
+
+// part is a CSG along with it's bounding box, used when combining many CSGs at once
+type part struct {
+	csg *CSG
+	box *Box
+}
+
+func newPart(c *CSG) *part {
+	return &part{csg: c, box: c.BoundingBox()}
+}
+
+// reducer combines parts in a balanced tree, running independent branches in their own
+// goroutines up to the number of available CPUs
+type reducer struct {
+	slots chan struct{}
+	// combine combines two parts whose bounding boxes overlap
+	combine func(a, b *CSG) (*CSG, error)
+	// disjoint combines two parts whose bounding boxes don't overlap
+	disjoint func(a, b *part) *part
+}
+
+func newReducer(concurrency int, combine func(a, b *CSG) (*CSG, error), disjoint func(a, b *part) *part) *reducer {
+	if concurrency <= 0 {
+		concurrency = runtime.NumCPU()
+	}
+	return &reducer{
+		slots:    make(chan struct{}, concurrency-1),
+		combine:  combine,
+		disjoint: disjoint,
+	}
+}
+
+func (r *reducer) reduce(parts []*part) (*part, error) {
+	if len(parts) == 1 {
+		return parts[0], nil
+	}
+
+	parts = splitSpatially(parts)
+	mid := len(parts) / 2
+
+	var a *part
+	var aErr error
+	var wg sync.WaitGroup
+	select {
+	case r.slots <- struct{}{}:
+		wg.Add(1)
+		go func() {
+			a, aErr = r.reduce(parts[:mid])
+			<-r.slots
+			wg.Done()
+		}()
+	default:
+		a, aErr = r.reduce(parts[:mid])
+	}
+	b, err := r.reduce(parts[mid:])
+	wg.Wait()
+	if aErr != nil {
+		return nil, aErr
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if len(a.csg.polygons) == 0 || len(b.csg.polygons) == 0 || !a.box.Intersects(b.box) {
+		return r.disjoint(a, b), nil
+	}
+	c, err := r.combine(a.csg, b.csg)
+	if err != nil {
+		return nil, err
+	}
+	return newPart(c), nil
+}
+
+// splitSpatially orders the parts along the longest axis of their centers, so that when the
+// slice is halved each half is as compact as possible, which makes it more likely that the
+// halves don't overlap at all
+func splitSpatially(parts []*part) []*part {
+	bounds := &Box{}
+	bounds.Min.CopyFrom(parts[0].box.Center())
+	bounds.Max.CopyFrom(parts[0].box.Center())
+	for _, p := range parts {
+		bounds.AddVector(p.box.Center())
+	}
+	size := bounds.Size()
+	axis := 0
+	if size.Y > size.Get(axis) {
+		axis = 1
+	}
+	if size.Z > size.Get(axis) {
+		axis = 2
+	}
+
+	sorted := make([]*part, len(parts))
+	copy(sorted, parts)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].box.Center().Get(axis) < sorted[j].box.Center().Get(axis)
+	})
+	return sorted
+}
+
+// isolate separates the parts whose bounding boxes don't overlap any other part from those that do
+func isolate(parts []*part) (isolated, overlapping []*part) {
+	byMin := make([]*part, len(parts))
+	copy(byMin, parts)
+	sort.SliceStable(byMin, func(i, j int) bool {
+		return byMin[i].box.Min.X < byMin[j].box.Min.X
+	})
+
+	overlaps := make(map[*part]bool)
+	for i, a := range byMin {
+		for _, b := range byMin[i+1:] {
+			if b.box.Min.X > a.box.Max.X+EPSILON {
+				break
+			}
+			if a.box.Intersects(b.box) {
+				overlaps[a] = true
+				overlaps[b] = true
+			}
+		}
+	}
+
+	for _, p := range parts {
+		if overlaps[p] {
+			overlapping = append(overlapping, p)
+		} else {
+			isolated = append(isolated, p)
+		}
+	}
+	return isolated, overlapping
+}
+
+// concat combines two parts which don't overlap by simply joining their polygons
+func concat(a, b *part) *part {
+	polygons := make([]*Polygon, 0, len(a.csg.polygons)+len(b.csg.polygons))
+	polygons = append(polygons, a.csg.polygons...)
+	polygons = append(polygons, b.csg.polygons...)
+	box := &Box{Min: a.box.Min, Max: a.box.Max}
+	box.AddVector(&b.box.Min)
+	box.AddVector(&b.box.Max)
+	return &part{csg: NewCSGFromPolygons(polygons), box: box}
+}
+
+// newParts prepares the CSGs for reduction, skipping any without polygons
+func newParts(csgs []*CSG) []*part {
+	parts := make([]*part, 0, len(csgs))
+	for _, c := range csgs {
+		if c != nil && len(c.polygons) > 0 {
+			parts = append(parts, newPart(c))
+		}
+	}
+	return parts
+}
+
+// UnionAll unions all of the CSGs together. The union is performed as a balanced tree, with
+// independent branches evaluated concurrently, and parts whose bounding boxes don't overlap
+// are combined without building any BSP trees.
+func UnionAll(csgs []*CSG) *CSG {
+	r, _ := UnionAllCtx(context.Background(), csgs)
+	return r
+}
+
+// UnionAllCtx is the same as UnionAll, but will stop and return the context's error if the context is cancelled
+func UnionAllCtx(ctx context.Context, csgs []*CSG) (*CSG, error) {
+	return unionAll(ctx, nil, csgs)
+}
+
+func unionAll(ctx context.Context, options *Options, csgs []*CSG) (*CSG, error) {
+	parts := newParts(csgs)
+	if len(parts) == 0 {
+		return &CSG{}, nil
+	}
+
+	isolated, overlapping := isolate(parts)
+
+	result := &part{csg: &CSG{}, box: &Box{}}
+	if len(overlapping) > 0 {
+		r := newReducer(options.concurrency(), func(a, b *CSG) (*CSG, error) {
+			return a.union(newOperation(ctx, options.concurrent()), b)
+		}, concat)
+		var err error
+		result, err = r.reduce(overlapping)
+		if err != nil {
+			return nil, err
+		}
+	}
+	polygons := result.csg.polygons
+	for _, p := range isolated {
+		polygons = append(polygons, p.csg.polygons...)
+	}
+	return NewCSGFromPolygons(polygons), nil
+}
+
+// IntersectAll returns the intersection of all of the CSGs. The intersection is performed as a
+// balanced tree with independent branches evaluated concurrently, and if the bounding boxes of
+// the CSGs have no common overlap an empty CSG is returned immediately.
+func IntersectAll(csgs []*CSG) *CSG {
+	r, _ := IntersectAllCtx(context.Background(), csgs)
+	return r
+}
+
+// IntersectAllCtx is the same as IntersectAll, but will stop and return the context's error if the context is cancelled
+func IntersectAllCtx(ctx context.Context, csgs []*CSG) (*CSG, error) {
+	return intersectAll(ctx, nil, csgs)
+}
+
+func intersectAll(ctx context.Context, options *Options, csgs []*CSG) (*CSG, error) {
+	parts := newParts(csgs)
+	if len(parts) != len(csgs) || len(parts) == 0 {
+		return &CSG{}, nil
+	}
+
+	common := &Box{Min: parts[0].box.Min, Max: parts[0].box.Max}
+	for _, p := range parts[1:] {
+		if !common.Intersects(p.box) {
+			return &CSG{}, nil
+		}
+		common = common.Intersection(p.box)
+	}
+
+	r := newReducer(options.concurrency(), func(a, b *CSG) (*CSG, error) {
+		return a.intersect(newOperation(ctx, options.concurrent()), b)
+	}, func(a, b *part) *part {
+		return &part{csg: &CSG{}, box: &Box{}}
+	})
+	result, err := r.reduce(parts)
+	if err != nil {
+		return nil, err
+	}
+	return result.csg, nil
+}
+
+// SubtractAll subtracts all of the tools from the base. Tools which don't overlap the base are
+// ignored, and the remaining tools are unioned together using UnionAll before being subtracted.
+func SubtractAll(base *CSG, tools []*CSG) *CSG {
+	r, _ := SubtractAllCtx(context.Background(), base, tools)
+	return r
+}
+
+// SubtractAllCtx is the same as SubtractAll, but will stop and return the context's error if the context is cancelled
+func SubtractAllCtx(ctx context.Context, base *CSG, tools []*CSG) (*CSG, error) {
+	return subtractAll(ctx, nil, base, tools)
+}
+
+func subtractAll(ctx context.Context, options *Options, base *CSG, tools []*CSG) (*CSG, error) {
+	box := base.BoundingBox()
+	overlapping := make([]*CSG, 0, len(tools))
+	for _, t := range tools {
+		if t != nil && len(t.polygons) > 0 && box.Intersects(t.BoundingBox()) {
+			overlapping = append(overlapping, t)
+		}
+	}
+	if len(base.polygons) == 0 || len(overlapping) == 0 {
+		return base.Clone(), nil
+	}
+	union, err := unionAll(ctx, options, overlapping)
+	if err != nil {
+		return nil, err
+	}
+	return base.subtract(newOperation(ctx, options), union)
+}
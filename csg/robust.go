@@ -0,0 +1,137 @@
+package csg
+
+// maxOriginDepth limits how far back the origin of a vertex is followed when classifying it
+const maxOriginDepth = 8
+
+// vertexOrigin records that a vertex was created by splitting the edge between a and b with the plane
+type vertexOrigin struct {
+	a     *Vertex
+	b     *Vertex
+	plane *Plane
+}
+
+// RobustPolygonSplitter is a polygon splitter which classifies vertices using exact orientation
+// predicates rather than a fixed EPSILON, so the result doesn't depend upon the scale of the
+// model and faces which are exactly coincident are always treated as coplanar.
+//
+// Vertices created by splitting an edge can't be represented exactly, so the splitter remembers
+// the edge and plane each one was created from and classifies it by where it should be rather than
+// where it was rounded to, this prevents slivers when the same plane is encountered again.
+//
+// Planes which weren't created from points, such as a plane constructed directly from a normal
+// and W, are split using the BasicPolygonSplitter.
+type RobustPolygonSplitter struct {
+}
+
+// SplitPolygons splits the polygons into various slices based upon their orientation to the specified plane
+func (ps *RobustPolygonSplitter) SplitPolygons(plane *Plane, polygons []*Polygon, coplanarFront, coplanarBack, front, back *[]*Polygon) {
+	if plane.points[0] == nil {
+		(&BasicPolygonSplitter{}).SplitPolygons(plane, polygons, coplanarFront, coplanarBack, front, back)
+		return
+	}
+
+	types := make([]PlaneRelationship, 0, 20)
+	for _, polygon := range polygons {
+
+		var polygonType PlaneRelationship
+
+		types = types[:0]
+		for _, v := range polygon.Vertices {
+			pType := classifyVertex(plane, v, 0)
+			polygonType |= pType
+			types = append(types, pType)
+		}
+
+		switch polygonType {
+		case COPLANAR:
+			if plane.Normal.Dot(polygon.Plane.Normal) > 0 {
+				*coplanarFront = append(*coplanarFront, polygon)
+			} else {
+				*coplanarBack = append(*coplanarBack, polygon)
+			}
+		case FRONT:
+			*front = append(*front, polygon)
+		case BACK:
+			*back = append(*back, polygon)
+		case SPANNING:
+			f := make([]*Vertex, 0)
+			b := make([]*Vertex, 0)
+
+			for i := range polygon.Vertices {
+				j := (i + 1) % len(polygon.Vertices)
+				ti := types[i]
+				tj := types[j]
+
+				vi := polygon.Vertices[i]
+				vj := polygon.Vertices[j]
+
+				if ti != BACK {
+					f = append(f, vi)
+				}
+				if ti != FRONT {
+					if ti != BACK {
+						b = append(b, vi.Clone())
+					} else {
+						b = append(b, vi)
+					}
+				}
+				if (ti | tj) == SPANNING {
+					t := (plane.W - plane.Normal.Dot(vi.Position)) / plane.Normal.Dot(vj.Position.Minus(vi.Position))
+					if t < 0 {
+						t = 0
+					} else if t > 1 {
+						t = 1
+					}
+					v := vi.Interpolate(vj, t)
+					v.origin = &vertexOrigin{a: vi, b: vj, plane: plane}
+					f = append(f, v)
+					b = append(b, v.Clone())
+				}
+			}
+			// the fragments lie exactly on the plane of the original polygon, which is kept
+			// rather than being recalculated from the rounded vertices
+			if len(f) >= 3 {
+				*front = append(*front, &Polygon{Vertices: f, Plane: polygon.Plane.Clone(), Shared: polygon.Shared})
+			}
+			if len(b) >= 3 {
+				*back = append(*back, &Polygon{Vertices: b, Plane: polygon.Plane.Clone(), Shared: polygon.Shared})
+			}
+		}
+	}
+}
+
+// classifyVertex returns the relationship of the vertex to the plane, which must have been created from points
+func classifyVertex(plane *Plane, v *Vertex, depth int) PlaneRelationship {
+	o := v.origin
+	if o != nil && depth < maxOriginDepth {
+		// the vertex lies between a and b, so if they're on the same side of the plane so is the vertex
+		t := classifyVertex(plane, o.a, depth+1) | classifyVertex(plane, o.b, depth+1)
+		if t != SPANNING {
+			return t
+		}
+		if o.plane == plane || coincident(plane, o.plane) {
+			return COPLANAR
+		}
+	}
+	d := orientation(plane.points[0], plane.points[1], plane.points[2], v.Position)
+	if d > 0 {
+		return FRONT
+	} else if d < 0 {
+		return BACK
+	}
+	return COPLANAR
+}
+
+// coincident returns true if both planes were created from points and are exactly the same plane,
+// ignoring which way they face
+func coincident(a, b *Plane) bool {
+	if a.points[0] == nil || b.points[0] == nil {
+		return false
+	}
+	for _, p := range b.points {
+		if orientation(a.points[0], a.points[1], a.points[2], p) != 0 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,196 @@
+package csg
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+)
+
+// scadWriter writes an operation tree as OpenSCAD source
+type scadWriter struct {
+	w *bufio.Writer
+	// modules are the names of the modules for operations which are used more than once
+	modules map[*Op]string
+}
+
+// MarshalToSCAD writes the operation tree out as OpenSCAD source which constructs the same solid.
+// Primitives, transforms and booleans are written as their OpenSCAD equivalents, and existing meshes
+// are written as polyhedrons. Operations which are used more than once in the tree are written as
+// modules. Spheres and cylinders use the same number of slices, but OpenSCAD tessellates them
+// slightly differently.
+func (o *Op) MarshalToSCAD(out io.Writer) error {
+	s := &scadWriter{w: bufio.NewWriter(out), modules: make(map[*Op]string)}
+
+	// find the operations which are used more than once, in the order they are first used
+	uses := make(map[*Op]int)
+	order := make([]*Op, 0)
+	var walk func(op *Op)
+	walk = func(op *Op) {
+		uses[op]++
+		if uses[op] > 1 {
+			return
+		}
+		for _, c := range op.Children {
+			walk(c)
+		}
+		order = append(order, op)
+	}
+	walk(o)
+
+	for _, op := range order {
+		if uses[op] > 1 {
+			name := fmt.Sprintf("part%d", len(s.modules)+1)
+			fmt.Fprintf(s.w, "module %s() {\n", name)
+			s.op(op, 1)
+			fmt.Fprintf(s.w, "}\n\n")
+			s.modules[op] = name
+		}
+	}
+	s.op(o, 0)
+	return s.w.Flush()
+}
+
+func (s *scadWriter) line(depth int, format string, args ...interface{}) {
+	s.w.WriteString(strings.Repeat("  ", depth))
+	fmt.Fprintf(s.w, format, args...)
+	s.w.WriteByte('\n')
+}
+
+func (s *scadWriter) op(o *Op, depth int) {
+	if name, ok := s.modules[o]; ok {
+		s.line(depth, "%s();", name)
+		return
+	}
+
+	switch o.Type {
+	case OpPrimitive:
+		s.primitive(o, depth)
+	case OpTransform:
+		if o.Matrix == nil {
+			s.children(o, depth)
+			return
+		}
+		s.line(depth, "multmatrix(%s) {", scadMatrix(o.Matrix))
+		s.children(o, depth+1)
+		s.line(depth, "}")
+	case OpUnion, OpSubtract, OpIntersect:
+		name := map[OpType]string{OpUnion: "union", OpSubtract: "difference", OpIntersect: "intersection"}[o.Type]
+		s.line(depth, "%s() {", name)
+		s.children(o, depth+1)
+		s.line(depth, "}")
+	default:
+		s.line(depth, "union() {}")
+	}
+}
+
+func (s *scadWriter) children(o *Op, depth int) {
+	for _, c := range o.Children {
+		s.op(c, depth)
+	}
+}
+
+func (s *scadWriter) primitive(o *Op, depth int) {
+	switch {
+	case o.Cube != nil:
+		center := &Vector{}
+		size := &Vector{X: 1, Y: 1, Z: 1}
+		if o.Cube.Center != nil {
+			center = o.Cube.Center
+		}
+		if o.Cube.Size != nil {
+			size = o.Cube.Size
+		}
+		s.line(depth, "translate(%s) cube(%s, center=true);", scadVector(center), scadVector(size))
+	case o.Sphere != nil:
+		center := &Vector{}
+		radius := 1.0
+		slices := 16
+		if o.Sphere.Center != nil {
+			center = o.Sphere.Center
+		}
+		if o.Sphere.Radius != 0 {
+			radius = o.Sphere.Radius
+		}
+		if o.Sphere.Slices != 0 {
+			slices = o.Sphere.Slices
+		}
+		s.line(depth, "translate(%s) sphere(r=%g, $fn=%d);", scadVector(center), radius, slices)
+	case o.Cylinder != nil:
+		start := &Vector{X: 0, Y: -1, Z: 0}
+		end := &Vector{X: 0, Y: 1, Z: 0}
+		radius := 1.0
+		slices := 16
+		if o.Cylinder.Start != nil {
+			start = o.Cylinder.Start
+		}
+		if o.Cylinder.End != nil {
+			end = o.Cylinder.End
+		}
+		if o.Cylinder.Radius != 0 {
+			radius = o.Cylinder.Radius
+		}
+		if o.Cylinder.Slices != 0 {
+			slices = o.Cylinder.Slices
+		}
+		// OpenSCAD cylinders start at the origin and extend along Z, so they're moved into place
+		ray := end.Minus(start)
+		s.line(depth, "multmatrix(%s) cylinder(h=%g, r=%g, $fn=%d);", scadMatrix(alignZ(start, ray)), ray.Length(), radius, slices)
+	case o.Mesh != nil:
+		s.polyhedron(o.Mesh, depth)
+	default:
+		s.line(depth, "union() {}")
+	}
+}
+
+// polyhedron writes the mesh as a polyhedron, OpenSCAD expects the points of each face to be
+// clockwise when viewed from outside, so the order of the vertices is reversed
+func (s *scadWriter) polyhedron(c *CSG, depth int) {
+	points, faces := indexedPolygons(c)
+	p := make([]string, len(points))
+	for i, v := range points {
+		p[i] = scadVector(v)
+	}
+	f := make([]string, len(faces))
+	for i, face := range faces {
+		indices := make([]string, len(face))
+		for j, index := range face {
+			indices[len(face)-1-j] = fmt.Sprint(index)
+		}
+		f[i] = "[" + strings.Join(indices, ",") + "]"
+	}
+	indent := strings.Repeat("  ", depth+1)
+	s.line(depth, "polyhedron(")
+	s.line(depth+1, "points=[\n%s%s],", indent+"  ", strings.Join(p, ",\n"+indent+"  "))
+	s.line(depth+1, "faces=[\n%s%s]);", indent+"  ", strings.Join(f, ",\n"+indent+"  "))
+}
+
+func scadVector(v *Vector) string {
+	return fmt.Sprintf("[%g, %g, %g]", v.X, v.Y, v.Z)
+}
+
+func scadMatrix(m *Matrix) string {
+	rows := make([]string, 4)
+	for i := range rows {
+		rows[i] = fmt.Sprintf("[%g, %g, %g, %g]", m[i*4], m[i*4+1], m[i*4+2], m[i*4+3])
+	}
+	return "[" + strings.Join(rows, ", ") + "]"
+}
+
+// alignZ returns a matrix which moves the origin to the point and rotates the Z axis to the direction of the ray
+func alignZ(origin, ray *Vector) *Matrix {
+	z := ray.Unit()
+	other := &Vector{X: 1}
+	if math.Abs(z.X) > 0.9 {
+		other = &Vector{Y: 1}
+	}
+	x := other.Cross(z).Unit()
+	y := z.Cross(x)
+	return &Matrix{
+		x.X, y.X, z.X, origin.X,
+		x.Y, y.Y, z.Y, origin.Y,
+		x.Z, y.Z, z.Z, origin.Z,
+		0, 0, 0, 1,
+	}
+}
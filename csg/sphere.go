@@ -18,6 +18,7 @@ type SphereOptions struct {
 
 // NewSphere constructs a new sphere given the specified options, if no
 // options are specified a default center of 0,0,0, radius of 1, and slice of 16 and stack of 8 is used.
+// The sphere is mapped to UV space using an equirectangular projection.
 func NewSphere(options *SphereOptions) *CSG {
 	center := &Vector{X: 0.0, Y: 0.0, Z: 0.0}
 	radius := 1.0
@@ -43,6 +44,7 @@ func NewSphere(options *SphereOptions) *CSG {
 	vertices := make([]*Vertex, 0, 4)
 
 	vertex := func(theta, phi float64) {
+		uv := &UV{U: theta, V: 1 - phi}
 		theta *= math.Pi * 2.0
 		phi *= math.Pi
 
@@ -51,7 +53,7 @@ func NewSphere(options *SphereOptions) *CSG {
 			Y: math.Cos(phi),
 			Z: math.Sin(theta) * math.Sin(phi),
 		}
-		vertices = append(vertices, &Vertex{center.Plus(dir.Times(radius)), dir})
+		vertices = append(vertices, &Vertex{Position: center.Plus(dir.Times(radius)), Normal: dir, UV: uv})
 	}
 
 	for i := 0.0; i < slices; i++ {
@@ -0,0 +1,148 @@
+package csg
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"strconv"
+)
+
+// ReadSTL reads the facets of an ASCII or binary STL file as polygons. The normals stored in the
+// file are ignored, each vertex is given the normal of its facet, and facets with no area are
+// discarded.
+func ReadSTL(in io.Reader) ([]*Polygon, error) {
+	data, err := ioutil.ReadAll(in)
+	if err != nil {
+		return nil, err
+	}
+
+	var facets [][]*Vector
+	// binary files often start with "solid" as well, so the size is checked first
+	if len(data) >= 84 && 84+50*int(binary.LittleEndian.Uint32(data[80:84])) == len(data) {
+		facets = readBinarySTL(data)
+	} else if bytes.HasPrefix(bytes.TrimSpace(data), []byte("solid")) {
+		if facets, err = readASCIISTL(data); err != nil {
+			return nil, err
+		}
+	} else if len(data) >= 84 {
+		return nil, fmt.Errorf("stl: binary file has %d bytes, expected %d", len(data), 84+50*int(binary.LittleEndian.Uint32(data[80:84])))
+	} else {
+		return nil, fmt.Errorf("stl: not an STL file")
+	}
+
+	polygons := make([]*Polygon, 0, len(facets))
+	for _, f := range facets {
+		normal := &Vector{}
+		for i := 2; i < len(f); i++ {
+			normal = normal.Plus(f[i-1].Minus(f[0]).Cross(f[i].Minus(f[0])))
+		}
+		if normal.Length() == 0 {
+			continue
+		}
+		normal = normal.Unit()
+		vertices := make([]*Vertex, len(f))
+		for i, p := range f {
+			vertices[i] = &Vertex{Position: p, Normal: normal.Clone()}
+		}
+		polygons = append(polygons, NewPolygonFromVertices(vertices))
+	}
+	return polygons, nil
+}
+
+// NewCSGFromSTL reads a mesh from an ASCII or binary STL file
+func NewCSGFromSTL(in io.Reader) (*CSG, error) {
+	polygons, err := ReadSTL(in)
+	if err != nil {
+		return nil, err
+	}
+	return NewCSGFromPolygons(polygons), nil
+}
+
+func readBinarySTL(data []byte) [][]*Vector {
+	count := int(binary.LittleEndian.Uint32(data[80:84]))
+	facets := make([][]*Vector, count)
+	for i := range facets {
+		// each facet is a normal and three vertices followed by a 2 byte attribute
+		offset := 84 + 50*i + 12
+		facet := make([]*Vector, 3)
+		for j := range facet {
+			value := func(k int) float64 {
+				return float64(math.Float32frombits(binary.LittleEndian.Uint32(data[offset+12*j+4*k:])))
+			}
+			facet[j] = &Vector{X: value(0), Y: value(1), Z: value(2)}
+		}
+		facets[i] = facet
+	}
+	return facets
+}
+
+func readASCIISTL(data []byte) ([][]*Vector, error) {
+	words := bufio.NewScanner(bytes.NewReader(data))
+	words.Split(bufio.ScanWords)
+
+	facets := make([][]*Vector, 0)
+	var facet []*Vector
+	for words.Scan() {
+		switch words.Text() {
+		case "outer":
+			facet = make([]*Vector, 0, 3)
+		case "vertex":
+			if facet == nil {
+				return nil, fmt.Errorf("stl: vertex outside of a loop in facet %d", len(facets)+1)
+			}
+			var xyz [3]float64
+			for i := range xyz {
+				if !words.Scan() {
+					return nil, fmt.Errorf("stl: unexpected end of file in facet %d", len(facets)+1)
+				}
+				v, err := strconv.ParseFloat(words.Text(), 64)
+				if err != nil {
+					return nil, fmt.Errorf("stl: invalid coordinate %q in facet %d", words.Text(), len(facets)+1)
+				}
+				xyz[i] = v
+			}
+			facet = append(facet, &Vector{X: xyz[0], Y: xyz[1], Z: xyz[2]})
+		case "endloop":
+			if len(facet) < 3 {
+				return nil, fmt.Errorf("stl: facet %d has %d vertices", len(facets)+1, len(facet))
+			}
+			facets = append(facets, facet)
+			facet = nil
+		}
+	}
+	if facet != nil {
+		return nil, fmt.Errorf("stl: unexpected end of file in facet %d", len(facets)+1)
+	}
+	return facets, words.Err()
+}
+
+// MarshalToBinarySTL writes out this CSG object to a binary STL representation, which stores
+// coordinates with single precision
+func (c *CSG) MarshalToBinarySTL(out io.Writer) error {
+	w := bufio.NewWriter(out)
+	triangles := make([]*Polygon, 0, len(c.polygons))
+	for _, p := range c.polygons {
+		triangles = append(triangles, p.Triangles()...)
+	}
+
+	var header [84]byte
+	copy(header[:], "binary STL written by github.com/celer/csg")
+	binary.LittleEndian.PutUint32(header[80:], uint32(len(triangles)))
+	w.Write(header[:])
+
+	var facet [50]byte
+	for _, t := range triangles {
+		vectors := []*Vector{t.Plane.Normal, t.Vertices[0].Position, t.Vertices[1].Position, t.Vertices[2].Position}
+		for i, v := range vectors {
+			binary.LittleEndian.PutUint32(facet[12*i:], math.Float32bits(float32(v.X)))
+			binary.LittleEndian.PutUint32(facet[12*i+4:], math.Float32bits(float32(v.Y)))
+			binary.LittleEndian.PutUint32(facet[12*i+8:], math.Float32bits(float32(v.Z)))
+		}
+		w.Write(facet[:])
+	}
+	return w.Flush()
+}
@@ -0,0 +1,218 @@
+package csg
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"fmt"
+	"image/color"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// ThreeMFUnit is the unit of the coordinates in a 3MF package
+type ThreeMFUnit string
+
+const (
+	// THREEMF_MICRON coordinates are in microns
+	THREEMF_MICRON ThreeMFUnit = "micron"
+	// THREEMF_MILLIMETER coordinates are in millimeters, which is the default
+	THREEMF_MILLIMETER ThreeMFUnit = "millimeter"
+	// THREEMF_CENTIMETER coordinates are in centimeters
+	THREEMF_CENTIMETER ThreeMFUnit = "centimeter"
+	// THREEMF_INCH coordinates are in inches
+	THREEMF_INCH ThreeMFUnit = "inch"
+	// THREEMF_FOOT coordinates are in feet
+	THREEMF_FOOT ThreeMFUnit = "foot"
+	// THREEMF_METER coordinates are in meters
+	THREEMF_METER ThreeMFUnit = "meter"
+)
+
+// ThreeMFObject is an object to be placed on the build plate of a 3MF package
+type ThreeMFObject struct {
+	// Name of the object, which is optional
+	Name string
+	// Mesh of the object
+	Mesh *CSG
+	// Transform places the object on the build plate, which is optional
+	Transform *Matrix
+}
+
+const threeMFContentTypes = `<?xml version="1.0" encoding="UTF-8"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">
+ <Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>
+ <Default Extension="model" ContentType="application/vnd.ms-package.3dmanufacturing-3dmodel+xml"/>
+</Types>
+`
+
+const threeMFRelationships = `<?xml version="1.0" encoding="UTF-8"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+ <Relationship Target="/3D/3dmodel.model" Id="rel0" Type="http://schemas.microsoft.com/3dmanufacturing/2013/01/3dmodel"/>
+</Relationships>
+`
+
+type threeMFModel struct {
+	XMLName   xml.Name              `xml:"model"`
+	Unit      ThreeMFUnit           `xml:"unit,attr"`
+	Lang      string                `xml:"xml:lang,attr"`
+	Namespace string                `xml:"xmlns,attr"`
+	Materials *threeMFBaseMaterials `xml:"resources>basematerials,omitempty"`
+	Objects   []*threeMFMeshObject  `xml:"resources>object"`
+	Items     []*threeMFItem        `xml:"build>item"`
+}
+
+type threeMFBaseMaterials struct {
+	ID    int                `xml:"id,attr"`
+	Bases []*threeMFMaterial `xml:"base"`
+}
+
+type threeMFMaterial struct {
+	Name         string `xml:"name,attr"`
+	DisplayColor string `xml:"displaycolor,attr"`
+}
+
+type threeMFMeshObject struct {
+	ID        int                `xml:"id,attr"`
+	Type      string             `xml:"type,attr"`
+	Name      string             `xml:"name,attr,omitempty"`
+	PID       int                `xml:"pid,attr,omitempty"`
+	PIndex    *int               `xml:"pindex,attr"`
+	Vertices  []*threeMFVertex   `xml:"mesh>vertices>vertex"`
+	Triangles []*threeMFTriangle `xml:"mesh>triangles>triangle"`
+}
+
+type threeMFVertex struct {
+	X float64 `xml:"x,attr"`
+	Y float64 `xml:"y,attr"`
+	Z float64 `xml:"z,attr"`
+}
+
+type threeMFTriangle struct {
+	V1  int  `xml:"v1,attr"`
+	V2  int  `xml:"v2,attr"`
+	V3  int  `xml:"v3,attr"`
+	PID int  `xml:"pid,attr,omitempty"`
+	P1  *int `xml:"p1,attr"`
+}
+
+type threeMFItem struct {
+	ObjectID  int    `xml:"objectid,attr"`
+	Transform string `xml:"transform,attr,omitempty"`
+}
+
+// threeMFTransform formats the matrix as a 3MF transform, which has the translation in the last row
+func threeMFTransform(m *Matrix) string {
+	values := []float64{m[0], m[4], m[8], m[1], m[5], m[9], m[2], m[6], m[10], m[3], m[7], m[11]}
+	s := make([]string, len(values))
+	for i, v := range values {
+		s[i] = strconv.FormatFloat(v, 'g', -1, 64)
+	}
+	return strings.Join(s, " ")
+}
+
+// WriteThreeMF writes the objects to a 3MF package, with each object becoming a build item. The
+// meshes are triangulated and vertices shared by triangles are welded together. Polygons with a
+// color in their Shared metadata are given a material of that color. If unit is empty millimeters
+// are used.
+func WriteThreeMF(out io.Writer, unit ThreeMFUnit, objects []*ThreeMFObject) error {
+	if unit == "" {
+		unit = THREEMF_MILLIMETER
+	}
+	model := &threeMFModel{
+		Unit:      unit,
+		Lang:      "en-US",
+		Namespace: "http://schemas.microsoft.com/3dmanufacturing/core/2015/02",
+	}
+
+	// materials are shared by all of the objects, the first material is used by triangles without a color
+	materials := &threeMFBaseMaterials{Bases: []*threeMFMaterial{{Name: "default", DisplayColor: "#CCCCCCFF"}}}
+	materialIndices := make(map[string]int)
+	material := func(s *Shared) *int {
+		if s == nil || s.Color == nil {
+			return nil
+		}
+		c := color.NRGBAModel.Convert(s.Color).(color.NRGBA)
+		displayColor := fmt.Sprintf("#%02X%02X%02X%02X", c.R, c.G, c.B, c.A)
+		key := s.Material + displayColor
+		index, ok := materialIndices[key]
+		if !ok {
+			name := s.Material
+			if name == "" {
+				name = displayColor
+			}
+			index = len(materials.Bases)
+			materialIndices[key] = index
+			materials.Bases = append(materials.Bases, &threeMFMaterial{Name: name, DisplayColor: displayColor})
+		}
+		return &index
+	}
+
+	// the base materials are resource 1, so the objects start at 2
+	for i, o := range objects {
+		m := newIndexedMesh(o.Mesh, false)
+		if len(m.triangles) == 0 {
+			return fmt.Errorf("3mf: object %d has no triangles", i)
+		}
+
+		object := &threeMFMeshObject{ID: i + 2, Type: "model", Name: o.Name}
+		for _, p := range m.positions {
+			object.Vertices = append(object.Vertices, &threeMFVertex{X: p.X, Y: p.Y, Z: p.Z})
+		}
+		colored := false
+		for j, t := range m.triangles {
+			tri := &threeMFTriangle{V1: t[0], V2: t[1], V3: t[2]}
+			if p1 := material(m.shared[j]); p1 != nil {
+				tri.PID = 1
+				tri.P1 = p1
+				colored = true
+			}
+			object.Triangles = append(object.Triangles, tri)
+		}
+		if colored {
+			object.PID = 1
+			object.PIndex = new(int)
+		}
+		model.Objects = append(model.Objects, object)
+
+		item := &threeMFItem{ObjectID: object.ID}
+		if o.Transform != nil {
+			item.Transform = threeMFTransform(o.Transform)
+		}
+		model.Items = append(model.Items, item)
+	}
+	if len(materials.Bases) > 1 {
+		materials.ID = 1
+		model.Materials = materials
+	}
+
+	z := zip.NewWriter(out)
+	for _, f := range []struct{ name, content string }{
+		{"[Content_Types].xml", threeMFContentTypes},
+		{"_rels/.rels", threeMFRelationships},
+	} {
+		w, err := z.Create(f.name)
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(w, f.content); err != nil {
+			return err
+		}
+	}
+
+	w, err := z.Create("3D/3dmodel.model")
+	if err != nil {
+		return err
+	}
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	if err := xml.NewEncoder(w).Encode(model); err != nil {
+		return err
+	}
+	return z.Close()
+}
+
+// MarshalToThreeMF writes this CSG out as a 3MF package containing a single object
+func (c *CSG) MarshalToThreeMF(out io.Writer, unit ThreeMFUnit) error {
+	return WriteThreeMF(out, unit, []*ThreeMFObject{{Mesh: c}})
+}
@@ -0,0 +1,237 @@
+package csg
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// ValidationError describes the problems found in a mesh by Validate
+type ValidationError struct {
+	// NonFiniteVertices is the number of vertices with a coordinate which is NaN or infinite
+	NonFiniteVertices int
+	// DegeneratePolygons is the number of polygons with fewer than 3 distinct vertices or no area
+	DegeneratePolygons int
+	// OpenEdges is the number of edges which aren't matched by an edge running the other way,
+	// which is caused by holes in the mesh or by polygons facing the wrong way
+	OpenEdges int
+	// NonManifoldEdges is the number of edges shared by more than two polygons
+	NonManifoldEdges int
+	// Volume enclosed by the mesh, which is negative if the mesh is inside out
+	Volume float64
+}
+
+func (e *ValidationError) Error() string {
+	problems := make([]string, 0)
+	for _, p := range []struct {
+		count int
+		name  string
+	}{
+		{e.NonFiniteVertices, "non-finite vertices"},
+		{e.DegeneratePolygons, "degenerate polygons"},
+		{e.OpenEdges, "open edges"},
+		{e.NonManifoldEdges, "non-manifold edges"},
+	} {
+		if p.count > 0 {
+			problems = append(problems, fmt.Sprintf("%d %s", p.count, p.name))
+		}
+	}
+	if e.Volume <= 0 {
+		problems = append(problems, fmt.Sprintf("volume of %g", e.Volume))
+	}
+	return "csg: invalid mesh: " + strings.Join(problems, ", ")
+}
+
+// Validate checks that the CSG is a closed manifold mesh enclosing a positive volume, returning a
+// *ValidationError describing the problems if it isn't. Edges which are split by a vertex of a
+// neighbouring polygon, as produced by boolean operations, are matched to the pieces of the edge.
+func (c *CSG) Validate() error {
+	e := &ValidationError{}
+
+	size := c.BoundingBox().Size()
+	extent := math.Max(size.X, math.Max(size.Y, size.Z))
+	tolerance := EPSILON * math.Max(1, extent)
+	vertices := 0
+	for _, p := range c.polygons {
+		vertices += len(p.Vertices)
+	}
+	grid := newVertexGrid(extent, tolerance, vertices)
+
+	// weld vertices within the tolerance of each other
+	faces := make([][]int, 0, len(c.polygons))
+	for _, p := range c.polygons {
+		face := make([]int, 0, len(p.Vertices))
+		for _, v := range p.Vertices {
+			if !isFinite(v.Position) {
+				e.NonFiniteVertices++
+				continue
+			}
+			index := grid.add(v.Position)
+			// consecutive duplicates don't form an edge
+			if len(face) > 0 && face[len(face)-1] == index {
+				continue
+			}
+			face = append(face, index)
+		}
+		if len(face) > 1 && face[0] == face[len(face)-1] {
+			face = face[:len(face)-1]
+		}
+		faces = append(faces, face)
+	}
+	if e.NonFiniteVertices > 0 {
+		e.Volume = math.NaN()
+		return e
+	}
+	positions := grid.positions
+
+	// count the uses of each edge in each direction, keyed by the lower index first
+	edges := make(map[[2]int]*[2]int)
+	for _, face := range faces {
+		if len(face) < 3 || polygonArea(positions, face) <= tolerance*tolerance {
+			e.DegeneratePolygons++
+		}
+		// the edges of degenerate polygons are still counted, as they may fill a gap between other polygons
+		if len(face) < 2 {
+			continue
+		}
+		for j, a := range face {
+			b := face[(j+1)%len(face)]
+			points := append([]int{a}, grid.onSegment(a, b)...)
+			points = append(points, b)
+			for k := 1; k < len(points); k++ {
+				from, to := points[k-1], points[k]
+				key, direction := [2]int{from, to}, 0
+				if from > to {
+					key, direction = [2]int{to, from}, 1
+				}
+				count, ok := edges[key]
+				if !ok {
+					count = &[2]int{}
+					edges[key] = count
+				}
+				count[direction]++
+			}
+		}
+	}
+	for _, count := range edges {
+		if count[0] != count[1] {
+			e.OpenEdges++
+		} else if count[0] > 1 {
+			e.NonManifoldEdges++
+		}
+	}
+
+	e.Volume = c.Volume()
+	if e.DegeneratePolygons > 0 || e.OpenEdges > 0 || e.NonManifoldEdges > 0 || e.Volume <= 0 {
+		return e
+	}
+	return nil
+}
+
+func isFinite(v *Vector) bool {
+	for _, f := range []float64{v.X, v.Y, v.Z} {
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			return false
+		}
+	}
+	return true
+}
+
+// polygonArea returns the area of the face
+func polygonArea(positions []*Vector, face []int) float64 {
+	normal := &Vector{}
+	origin := positions[face[0]]
+	for i := 2; i < len(face); i++ {
+		normal = normal.Plus(positions[face[i-1]].Minus(origin).Cross(positions[face[i]].Minus(origin)))
+	}
+	return normal.Length() / 2
+}
+
+// vertexGrid buckets vertices into cubic cells so that the vertices near an edge can be found quickly
+type vertexGrid struct {
+	positions []*Vector
+	cells     map[[3]int][]int
+	size      float64
+	tolerance float64
+}
+
+// newVertexGrid returns an empty grid sized for the number of vertices spread over the extent
+func newVertexGrid(extent, tolerance float64, vertices int) *vertexGrid {
+	g := &vertexGrid{cells: make(map[[3]int][]int), tolerance: tolerance}
+	// aim for around one vertex per cell
+	g.size = math.Max(extent/math.Cbrt(float64(vertices)+1), 2*tolerance)
+	return g
+}
+
+// add returns the index of a vertex within the tolerance of the position, adding the position as a
+// new vertex if there isn't one
+func (g *vertexGrid) add(p *Vector) int {
+	if near := g.within(p, p); len(near) > 0 {
+		return near[0]
+	}
+	index := len(g.positions)
+	g.positions = append(g.positions, p)
+	cell := g.cell(p)
+	g.cells[cell] = append(g.cells[cell], index)
+	return index
+}
+
+// within returns the vertices in the cells overlapping the box around a and b expanded by the
+// tolerance, which are within the tolerance of the box
+func (g *vertexGrid) within(a, b *Vector) []int {
+	min := &Vector{X: math.Min(a.X, b.X) - g.tolerance, Y: math.Min(a.Y, b.Y) - g.tolerance, Z: math.Min(a.Z, b.Z) - g.tolerance}
+	max := &Vector{X: math.Max(a.X, b.X) + g.tolerance, Y: math.Max(a.Y, b.Y) + g.tolerance, Z: math.Max(a.Z, b.Z) + g.tolerance}
+	lo, hi := g.cell(min), g.cell(max)
+	indices := make([]int, 0)
+	for x := lo[0]; x <= hi[0]; x++ {
+		for y := lo[1]; y <= hi[1]; y++ {
+			for z := lo[2]; z <= hi[2]; z++ {
+				for _, i := range g.cells[[3]int{x, y, z}] {
+					p := g.positions[i]
+					if p.X >= min.X && p.X <= max.X && p.Y >= min.Y && p.Y <= max.Y && p.Z >= min.Z && p.Z <= max.Z {
+						indices = append(indices, i)
+					}
+				}
+			}
+		}
+	}
+	return indices
+}
+
+func (g *vertexGrid) cell(p *Vector) [3]int {
+	return [3]int{int(math.Floor(p.X / g.size)), int(math.Floor(p.Y / g.size)), int(math.Floor(p.Z / g.size))}
+}
+
+// onSegment returns the vertices lying within the tolerance of the segment between the vertices a
+// and b, excluding the ends, in order from a to b
+func (g *vertexGrid) onSegment(a, b int) []int {
+	pa, pb := g.positions[a], g.positions[b]
+	ray := pb.Minus(pa)
+	length2 := ray.Dot(ray)
+
+	type point struct {
+		index int
+		t     float64
+	}
+	points := make([]point, 0)
+	for _, i := range g.within(pa, pb) {
+		if i == a || i == b {
+			continue
+		}
+		d := g.positions[i].Minus(pa)
+		t := d.Dot(ray) / length2
+		if t <= 0 || t >= 1 {
+			continue
+		}
+		if d.Minus(ray.Times(t)).Length() <= g.tolerance {
+			points = append(points, point{i, t})
+		}
+	}
+	sort.Slice(points, func(i, j int) bool { return points[i].t < points[j].t })
+	indices := make([]int, len(points))
+	for i, p := range points {
+		indices[i] = p.index
+	}
+	return indices
+}
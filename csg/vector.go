@@ -7,13 +7,9 @@ import (
 	"math/rand"
 )
 
-// F64Epsilon is the epsilon utilized for AlmostEqual
-var F64Epsilon float64
-
-func init() {
-	// Calculate the epsilon
-	F64Epsilon = math.Nextafter(1, 2) - 1
-}
+// F64Epsilon is the epsilon utilized for AlmostEqual, which is the difference between 1 and the next
+// largest float64
+const F64Epsilon = 2.220446049250313e-16
 
 // Vector representation of a vector point in 3 dimensional space
 type Vector struct {
@@ -1,11 +1,49 @@
 package csg
 
+// UV is a texture coordinate
+type UV struct {
+	U float64
+	V float64
+}
+
+// Color is a color with components from 0 to 1, which isn't premultiplied by alpha
+type Color struct {
+	R float64
+	G float64
+	B float64
+	A float64
+}
+
+// RGBA returns the alpha premultiplied components of the color, which allows it to be used as a color.Color
+func (c *Color) RGBA() (r, g, b, a uint32) {
+	clamp := func(f float64) float64 {
+		if f < 0 {
+			return 0
+		} else if f > 1 {
+			return 1
+		}
+		return f
+	}
+	alpha := clamp(c.A)
+	return uint32(clamp(c.R)*alpha*0xffff + 0.5), uint32(clamp(c.G)*alpha*0xffff + 0.5), uint32(clamp(c.B)*alpha*0xffff + 0.5), uint32(alpha*0xffff + 0.5)
+}
+
 //Vertex is a point with an associated normal
 type Vertex struct {
 	//Position of this vertex
 	Position *Vector
 	// Normal of this vertex
 	Normal *Vector
+	// UV is the texture coordinate of this vertex, which may be nil
+	UV *UV
+	// Color of this vertex, which may be nil
+	Color *Color
+	// Channels are arbitrary values associated with this vertex, which are interpolated
+	// along with the position when the vertex is split
+	Channels []float64
+
+	// origin records how this vertex was created when it was created by splitting an edge
+	origin *vertexOrigin
 }
 
 //NewVertexFromVectors constructs a new vertex from vectors and points
@@ -20,7 +58,24 @@ func NewVertex() *Vertex {
 
 //Clone copies this vertex
 func (v *Vertex) Clone() *Vertex {
-	return &Vertex{Position: v.Position.Clone(), Normal: v.Normal.Clone()}
+	c := &Vertex{Position: v.Position.Clone(), Normal: v.Normal.Clone(), origin: v.origin}
+	c.copyAttributes(v)
+	return c
+}
+
+// copyAttributes copies the UV, color and channels from another vertex
+func (v *Vertex) copyAttributes(o *Vertex) {
+	if o.UV != nil {
+		uv := *o.UV
+		v.UV = &uv
+	}
+	if o.Color != nil {
+		c := *o.Color
+		v.Color = &c
+	}
+	if o.Channels != nil {
+		v.Channels = append([]float64(nil), o.Channels...)
+	}
 }
 
 //Flip flips the normal of this vertex
@@ -28,10 +83,45 @@ func (v *Vertex) Flip() {
 	v.Normal = v.Normal.Negated()
 }
 
-//Interpolate lerp's this vertex and it's normal
+//Interpolate lerp's this vertex and it's normal, along with the UV, color and channels if both vertices have them
 func (v *Vertex) Interpolate(other *Vertex, t float64) *Vertex {
-	return &Vertex{
+	lerp := func(a, b float64) float64 {
+		return a + (b-a)*t
+	}
+	r := &Vertex{
 		Position: v.Position.Lerp(other.Position, t),
 		Normal:   v.Normal.Lerp(other.Normal, t),
 	}
+	if v.UV != nil && other.UV != nil {
+		r.UV = &UV{U: lerp(v.UV.U, other.UV.U), V: lerp(v.UV.V, other.UV.V)}
+	}
+	if v.Color != nil && other.Color != nil {
+		r.Color = &Color{
+			R: lerp(v.Color.R, other.Color.R),
+			G: lerp(v.Color.G, other.Color.G),
+			B: lerp(v.Color.B, other.Color.B),
+			A: lerp(v.Color.A, other.Color.A),
+		}
+	}
+	if v.Channels != nil && other.Channels != nil {
+		n := len(v.Channels)
+		if len(other.Channels) < n {
+			n = len(other.Channels)
+		}
+		r.Channels = make([]float64, n)
+		for i := range r.Channels {
+			r.Channels[i] = lerp(v.Channels[i], other.Channels[i])
+		}
+	}
+	return r
+}
+
+// Transform returns a new vertex with the position and normal transformed by the matrix
+func (v *Vertex) Transform(m *Matrix) *Vertex {
+	r := &Vertex{
+		Position: m.TransformPoint(v.Position),
+		Normal:   m.TransformNormal(v.Normal),
+	}
+	r.copyAttributes(v)
+	return r
 }
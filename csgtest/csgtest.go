@@ -0,0 +1,236 @@
+// Package csgtest provides helpers for regression tests which compare meshes and rendered images
+// against golden files checked in to the testdata directory of the package being tested.
+//
+// Running the tests with the -update flag writes the goldens from the current results instead of
+// comparing against them:
+//
+//	go test ./csg -run TestSubtraction -update
+//
+// When a comparison fails the actual result is written to the output directory so it can be
+// inspected.
+package csgtest
+
+import (
+	"bytes"
+	"flag"
+	"image"
+	"image/color"
+	"image/png"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/celer/csg/csg"
+	"github.com/celer/csg/render"
+)
+
+var update = flag.Bool("update", false, "write the golden files in testdata from the test results")
+
+// MeshOptions are the tolerances allowed when comparing a mesh to its golden
+type MeshOptions struct {
+	// Volume is the allowed difference in volume relative to the golden, if not specified 1e-6
+	// is used
+	Volume float64
+	// Area is the allowed difference in surface area relative to the golden, if not specified
+	// 1e-6 is used
+	Area float64
+	// Distance is the allowed Hausdorff distance between the surfaces relative to the diagonal of
+	// the golden's bounding box, if not specified 1e-6 is used
+	Distance float64
+	// IgnoreTopology skips comparing the vertex, edge and triangle counts, for meshes where only
+	// the shape matters and not how it's tessellated
+	IgnoreTopology bool
+}
+
+// ImageOptions control how a CSG is rendered and compared to its golden image
+type ImageOptions struct {
+	// Render are the options the image is rendered with, if not specified the render defaults are
+	// used
+	Render *render.Options
+	// Threshold is the difference in any channel above which a pixel is counted as different,
+	// if not specified 16 is used
+	Threshold int
+	// Pixels is the fraction of pixels allowed to differ, if not specified 0.005 is used
+	Pixels float64
+}
+
+func goldenPath(name, ext string) string {
+	return filepath.Join("testdata", filepath.FromSlash(name)+ext)
+}
+
+// writeFile writes data to path creating any missing directories
+func writeFile(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, data, 0644)
+}
+
+// readGolden reads the golden, writing data to it first if -update was given. It returns nil if
+// the test has already failed.
+func readGolden(t testing.TB, path string, data []byte) []byte {
+	t.Helper()
+	if *update {
+		if err := writeFile(path, data); err != nil {
+			t.Fatal(err)
+		}
+		t.Logf("updated %s", path)
+		return data
+	}
+	golden, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		t.Errorf("csgtest: %s doesn't exist, run the test with -update to create it", path)
+		return nil
+	} else if err != nil {
+		t.Fatal(err)
+	}
+	return golden
+}
+
+// saveActual writes the result of a failed comparison to the output directory
+func saveActual(t testing.TB, name, ext string, data []byte) {
+	t.Helper()
+	path := filepath.Join("output", filepath.FromSlash(name)+ext)
+	if err := writeFile(path, data); err != nil {
+		t.Errorf("csgtest: unable to save the actual result: %v", err)
+		return
+	}
+	t.Logf("the actual result was written to %s", path)
+}
+
+// differs checks if a and b differ by more than tolerance relative to the larger of them
+func differs(a, b, tolerance float64) bool {
+	return math.Abs(a-b) > tolerance*math.Max(math.Abs(a), math.Abs(b))
+}
+
+func orDefault(v, d float64) float64 {
+	if v <= 0 {
+		return d
+	}
+	return v
+}
+
+// AssertMesh compares the CSG against the golden mesh testdata/name.stl, failing the test if
+// the volume, area, topology or shape differ by more than the options allow. The options may be
+// nil. The mesh is compared as it's stored in the golden, with single precision coordinates, so
+// an unchanged result matches exactly.
+func AssertMesh(t testing.TB, name string, c *csg.CSG, options *MeshOptions) {
+	t.Helper()
+	if options == nil {
+		options = &MeshOptions{}
+	}
+	var buf bytes.Buffer
+	if err := c.MarshalToBinarySTL(&buf); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+	path := goldenPath(name, ".stl")
+	golden := readGolden(t, path, data)
+	if golden == nil {
+		saveActual(t, name, ".stl", data)
+		return
+	}
+
+	actual, err := csg.NewCSGFromSTL(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected, err := csg.NewCSGFromSTL(bytes.NewReader(golden))
+	if err != nil {
+		t.Fatalf("csgtest: %s: %v", path, err)
+	}
+
+	failed := false
+	fail := func(format string, args ...interface{}) {
+		t.Helper()
+		t.Errorf("csgtest: %s: "+format, append([]interface{}{name}, args...)...)
+		failed = true
+	}
+	a, e := Measure(actual), Measure(expected)
+	if differs(a.Volume, e.Volume, orDefault(options.Volume, 1e-6)) {
+		fail("expected a volume of %g got %g", e.Volume, a.Volume)
+	}
+	if differs(a.Area, e.Area, orDefault(options.Area, 1e-6)) {
+		fail("expected an area of %g got %g", e.Area, a.Area)
+	}
+	if a.OpenEdges != e.OpenEdges || a.NonManifoldEdges != e.NonManifoldEdges {
+		fail("expected %d open and %d non-manifold edges got %d and %d",
+			e.OpenEdges, e.NonManifoldEdges, a.OpenEdges, a.NonManifoldEdges)
+	}
+	if !options.IgnoreTopology && (a.Vertices != e.Vertices || a.Edges != e.Edges || a.Triangles != e.Triangles) {
+		fail("expected %d vertices, %d edges and %d triangles got %d, %d and %d",
+			e.Vertices, e.Edges, e.Triangles, a.Vertices, a.Edges, a.Triangles)
+	}
+	diagonal := expected.BoundingBox().Size().Length()
+	allowed := orDefault(options.Distance, 1e-6) * diagonal
+	// sparse samples are enough to find a change in shape
+	deviation := csg.SurfaceDeviation(actual, expected, &csg.DeviationOptions{Spacing: diagonal / 20})
+	if d := deviation.Max; d > allowed {
+		fail("expected a Hausdorff distance of at most %g got %g", allowed, d)
+	}
+	if failed {
+		saveActual(t, name, ".stl", data)
+	}
+}
+
+// AssertImage renders the CSG and compares it against the golden image testdata/name.png,
+// failing the test if more pixels differ than the options allow. The options may be nil.
+func AssertImage(t testing.TB, name string, c *csg.CSG, options *ImageOptions) {
+	t.Helper()
+	if options == nil {
+		options = &ImageOptions{}
+	}
+	img := render.Render(c, options.Render)
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+	path := goldenPath(name, ".png")
+	golden := readGolden(t, path, data)
+	if golden == nil {
+		saveActual(t, name, ".png", data)
+		return
+	}
+
+	expected, err := png.Decode(bytes.NewReader(golden))
+	if err != nil {
+		t.Fatalf("csgtest: %s: %v", path, err)
+	}
+	if expected.Bounds().Size() != img.Bounds().Size() {
+		t.Errorf("csgtest: %s: expected an image of %v got %v", name, expected.Bounds().Size(), img.Bounds().Size())
+		saveActual(t, name, ".png", data)
+		return
+	}
+	threshold := options.Threshold
+	if threshold <= 0 {
+		threshold = 16
+	}
+	different := countDifferent(img, expected, threshold)
+	total := img.Bounds().Dx() * img.Bounds().Dy()
+	if float64(different) > orDefault(options.Pixels, 0.005)*float64(total) {
+		t.Errorf("csgtest: %s: %d of %d pixels differ from the golden", name, different, total)
+		saveActual(t, name, ".png", data)
+	}
+}
+
+// countDifferent counts the pixels where any channel differs by more than threshold
+func countDifferent(a *image.NRGBA, b image.Image, threshold int) int {
+	different := 0
+	ab, bb := a.Bounds(), b.Bounds()
+	for y := 0; y < ab.Dy(); y++ {
+		for x := 0; x < ab.Dx(); x++ {
+			p := a.NRGBAAt(ab.Min.X+x, ab.Min.Y+y)
+			q := color.NRGBAModel.Convert(b.At(bb.Min.X+x, bb.Min.Y+y)).(color.NRGBA)
+			for _, v := range []int{int(p.R) - int(q.R), int(p.G) - int(q.G), int(p.B) - int(q.B), int(p.A) - int(q.A)} {
+				if v > threshold || -v > threshold {
+					different++
+					break
+				}
+			}
+		}
+	}
+	return different
+}
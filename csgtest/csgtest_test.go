@@ -0,0 +1,83 @@
+package csgtest
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/celer/csg/csg"
+)
+
+// recorder captures the errors reported by the helpers
+type recorder struct {
+	testing.TB
+	errors []string
+}
+
+func (r *recorder) Helper() {}
+
+func (r *recorder) Logf(format string, args ...interface{}) {}
+
+func (r *recorder) Errorf(format string, args ...interface{}) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+func cube(size float64) *csg.CSG {
+	return csg.NewCube(&csg.CubeOptions{Size: &csg.Vector{X: size, Y: size, Z: size}})
+}
+
+func TestMeasure(t *testing.T) {
+	m := Measure(cube(2))
+	expected := &Metrics{Volume: 8, Area: 24, Vertices: 8, Edges: 18, Triangles: 12}
+	if m.String() != expected.String() {
+		t.Errorf("Expected %v got %v", expected, m)
+	}
+
+	open := csg.NewCSGFromPolygons(cube(2).ToPolygons()[1:])
+	if m := Measure(open); m.OpenEdges != 4 {
+		t.Errorf("Expected 4 open edges got %v", m)
+	}
+}
+
+func TestAssertMesh(t *testing.T) {
+	AssertMesh(t, "cube", cube(2), nil)
+	if *update {
+		return
+	}
+
+	for _, test := range []struct {
+		c       *csg.CSG
+		options *MeshOptions
+		errors  int
+	}{
+		{cube(2).Rotate(&csg.Vector{Y: 1}, 90), nil, 0},
+		{cube(2.002), nil, 3},
+		{cube(2.002), &MeshOptions{Volume: 0.01, Area: 0.01, Distance: 0.001}, 0},
+		{cube(2).Subtract(cube(1)), &MeshOptions{Volume: 1, Area: 1, Distance: 1, IgnoreTopology: true}, 0},
+		{cube(2).Subtract(cube(1)), &MeshOptions{Volume: 1, Area: 1, Distance: 1}, 1},
+	} {
+		r := &recorder{TB: t}
+		AssertMesh(r, "cube", test.c, test.options)
+		if len(r.errors) != test.errors {
+			t.Errorf("Expected %d errors got %q", test.errors, r.errors)
+		}
+	}
+}
+
+func TestAssertImage(t *testing.T) {
+	AssertImage(t, "cube", cube(2), nil)
+	if *update {
+		return
+	}
+
+	r := &recorder{TB: t}
+	AssertImage(r, "cube", cube(2).Rotate(&csg.Vector{Y: 1}, 30), nil)
+	if len(r.errors) != 1 {
+		t.Errorf("Expected the rotated cube to differ got %q", r.errors)
+	}
+
+	r = &recorder{TB: t}
+	AssertImage(r, "missing", cube(2), nil)
+	if len(r.errors) != 1 || r.errors[0] != "csgtest: testdata/missing.png doesn't exist, run the test with -update to create it" {
+		t.Errorf("Expected a missing golden error got %q", r.errors)
+	}
+}
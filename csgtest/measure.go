@@ -0,0 +1,65 @@
+package csgtest
+
+import (
+	"fmt"
+
+	"github.com/celer/csg/csg"
+)
+
+// Metrics summarizes a mesh for comparison with a golden
+type Metrics struct {
+	// Volume enclosed by the mesh
+	Volume float64
+	// Area of the surface of the mesh
+	Area float64
+	// Vertices is the number of distinct vertex positions
+	Vertices int
+	// Edges is the number of distinct edges between triangles
+	Edges int
+	// Triangles is the number of triangles in the mesh
+	Triangles int
+	// OpenEdges and NonManifoldEdges are the problems found by csg.CSG.Validate
+	OpenEdges, NonManifoldEdges int
+}
+
+func (m *Metrics) String() string {
+	return fmt.Sprintf("volume %g, area %g, %d vertices, %d edges, %d triangles, %d open edges, %d non-manifold edges",
+		m.Volume, m.Area, m.Vertices, m.Edges, m.Triangles, m.OpenEdges, m.NonManifoldEdges)
+}
+
+// Measure computes the metrics of the CSG
+func Measure(c *csg.CSG) *Metrics {
+	m := &Metrics{Volume: c.Volume()}
+	vertices := make(map[csg.Vector]int)
+	edges := make(map[[2]int]bool)
+	for _, p := range c.ToPolygons() {
+		for _, t := range p.Triangles() {
+			m.Triangles++
+			v0, v1, v2 := t.Vertices[0].Position, t.Vertices[1].Position, t.Vertices[2].Position
+			m.Area += v1.Minus(v0).Cross(v2.Minus(v0)).Length() / 2
+			var indices [3]int
+			for i, v := range []*csg.Vector{v0, v1, v2} {
+				index, ok := vertices[*v]
+				if !ok {
+					index = len(vertices)
+					vertices[*v] = index
+				}
+				indices[i] = index
+			}
+			for i := range indices {
+				a, b := indices[i], indices[(i+1)%3]
+				if a > b {
+					a, b = b, a
+				}
+				edges[[2]int{a, b}] = true
+			}
+		}
+	}
+	m.Vertices = len(vertices)
+	m.Edges = len(edges)
+	if err, ok := c.Validate().(*csg.ValidationError); ok {
+		m.OpenEdges = err.OpenEdges
+		m.NonManifoldEdges = err.NonManifoldEdges
+	}
+	return m
+}
@@ -0,0 +1,418 @@
+package qhull
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/celer/csg/csg"
+)
+
+// Contact describes how close two hulls are, or how deeply they overlap
+type Contact struct {
+	// Distance between the surfaces of the hulls, which is negative if they overlap in which
+	// case it's the depth they penetrate each other by
+	Distance float64
+	// A and B are the closest points on each hull, or if the hulls overlap the points of each
+	// which are deepest inside the other
+	A, B *csg.Vector
+	// Normal is the unit vector pointing from A towards B, when the hulls overlap moving b by
+	// the penetration depth along it separates them
+	Normal *csg.Vector
+}
+
+// gjkTolerance is the relative error in distances at which GJK and EPA stop
+const gjkTolerance = 1e-10
+
+// gjkMaxIterations bounds the number of steps taken by GJK and EPA
+const gjkMaxIterations = 1000
+
+// buildNeighbors records the vertices joined to each vertex of the hull by an edge, which lets
+// support queries climb towards the furthest vertex rather than visiting every vertex
+func (q *Hull) buildNeighbors() {
+	q.hullPoints = q.Vertices()
+	q.neighbors = make([][]int, q.numVertices)
+	for _, face := range q.faces {
+		he := face.edge
+		for {
+			// each edge is in two faces running opposite ways, so each end is recorded once
+			tail := he.Tail().index
+			q.neighbors[tail] = append(q.neighbors[tail], he.Head().index)
+			he = he.next
+			if he == face.edge {
+				break
+			}
+		}
+	}
+}
+
+// support returns the index of the hull vertex furthest along the direction, starting the climb
+// from the vertex start
+func (q *Hull) support(direction *csg.Vector, start int) int {
+	best := start
+	bestDot := q.hullPoints[best].Dot(direction)
+	for {
+		next := best
+		for _, n := range q.neighbors[best] {
+			if dot := q.hullPoints[n].Dot(direction); dot > bestDot {
+				next, bestDot = n, dot
+			}
+		}
+		if next == best {
+			return best
+		}
+		best = next
+	}
+}
+
+// minkowskiPoint is a point of the Minkowski difference a-b along with the hull vertices which
+// produced it
+type minkowskiPoint struct {
+	w      *csg.Vector
+	ia, ib int
+}
+
+// minkowski finds the point of a-b furthest along the direction
+type minkowski struct {
+	a, b   *Hull
+	ia, ib int
+}
+
+func (m *minkowski) support(direction *csg.Vector) minkowskiPoint {
+	m.ia = m.a.support(direction, m.ia)
+	m.ib = m.b.support(direction.Negated(), m.ib)
+	return m.point(m.ia, m.ib)
+}
+
+func (m *minkowski) point(ia, ib int) minkowskiPoint {
+	return minkowskiPoint{w: m.a.hullPoints[ia].Minus(m.b.hullPoints[ib]), ia: ia, ib: ib}
+}
+
+// witnesses returns the points on each hull which are combined by the weights into a point of
+// the Minkowski difference
+func (m *minkowski) witnesses(points []minkowskiPoint, weights []float64) (*csg.Vector, *csg.Vector) {
+	a, b := &csg.Vector{}, &csg.Vector{}
+	for i, p := range points {
+		a = a.Plus(m.a.hullPoints[p.ia].Times(weights[i]))
+		b = b.Plus(m.b.hullPoints[p.ib].Times(weights[i]))
+	}
+	return a, b
+}
+
+// Distance finds the distance between two hulls and the closest points on each using the GJK
+// algorithm, if the hulls overlap the penetration depth and the deepest points are found using
+// the expanding polytope algorithm (EPA) instead. Both hulls must have been built.
+func Distance(a, b *Hull) (*Contact, error) {
+	if !a.built() || !b.built() {
+		return nil, fmt.Errorf("Hull has not been built")
+	}
+	m := &minkowski{a: a, b: b}
+	simplex, weights, overlap := gjk(m)
+	if !overlap {
+		pa, pb := m.witnesses(simplex, weights)
+		d := pb.Minus(pa)
+		return &Contact{Distance: d.Length(), A: pa, B: pb, Normal: d.Unit()}, nil
+	}
+	return epa(m, simplex), nil
+}
+
+// gjk finds the simplex of the Minkowski difference with the point closest to the origin, along
+// with the weights of its points which give the closest point, or reports that the difference
+// contains the origin
+func gjk(m *minkowski) ([]minkowskiPoint, []float64, bool) {
+	simplex := []minkowskiPoint{m.point(0, 0)}
+	weights := []float64{1}
+	v := simplex[0].w
+	for i := 0; i < gjkMaxIterations; i++ {
+		vv := v.LengthSquared()
+		if vv == 0 {
+			// the simplex touches the origin, so there's no direction to search in
+			return simplex, weights, true
+		}
+		p := m.support(v.Negated())
+		if vv-v.Dot(p.w) <= gjkTolerance*vv {
+			break
+		}
+		repeated := false
+		for _, s := range simplex {
+			repeated = repeated || (s.ia == p.ia && s.ib == p.ib)
+		}
+		if repeated {
+			break
+		}
+		reduced, w := closestOnSimplex(append(simplex, p))
+		if len(reduced) == 4 {
+			return reduced, w, true
+		}
+		next := combine(reduced, w)
+		// rounding can stop the distance decreasing once it's converged
+		if next.LengthSquared() >= vv {
+			break
+		}
+		simplex, weights, v = reduced, w, next
+		scale := 0.0
+		for _, s := range simplex {
+			scale = math.Max(scale, s.w.LengthSquared())
+		}
+		if v.LengthSquared() <= gjkTolerance*gjkTolerance*scale {
+			return simplex, weights, true
+		}
+	}
+	return simplex, weights, false
+}
+
+// combine returns the weighted sum of the points
+func combine(points []minkowskiPoint, weights []float64) *csg.Vector {
+	v := &csg.Vector{}
+	for i, p := range points {
+		v = v.Plus(p.w.Times(weights[i]))
+	}
+	return v
+}
+
+// closestOnSimplex returns the smallest part of the simplex containing the point closest to the
+// origin and the weights of its points giving that point, the whole tetrahedron is returned if
+// it contains the origin
+func closestOnSimplex(s []minkowskiPoint) ([]minkowskiPoint, []float64) {
+	switch len(s) {
+	case 1:
+		return s, []float64{1}
+	case 2:
+		return closestOnSegment(s[0], s[1])
+	case 3:
+		return closestOnTriangle(s[0], s[1], s[2])
+	}
+	var best []minkowskiPoint
+	var bestWeights []float64
+	bestDistance := math.Inf(1)
+	for _, f := range [4][4]int{{0, 1, 2, 3}, {0, 3, 1, 2}, {0, 2, 3, 1}, {1, 3, 2, 0}} {
+		a, b, c, d := s[f[0]].w, s[f[1]].w, s[f[2]].w, s[f[3]].w
+		n := b.Minus(a).Cross(c.Minus(a))
+		// only faces with the origin on the other side from the opposite vertex can be closest,
+		// a flat tetrahedron has no inside so all its faces are considered
+		if inner := d.Minus(a).Dot(n); inner != 0 && math.Signbit(inner) == math.Signbit(a.Negated().Dot(n)) {
+			continue
+		}
+		points, weights := closestOnTriangle(s[f[0]], s[f[1]], s[f[2]])
+		if distance := combine(points, weights).LengthSquared(); distance < bestDistance {
+			best, bestWeights, bestDistance = points, weights, distance
+		}
+	}
+	if best == nil {
+		return s, tetrahedronWeights(s)
+	}
+	return best, bestWeights
+}
+
+func closestOnSegment(a, b minkowskiPoint) ([]minkowskiPoint, []float64) {
+	ab := b.w.Minus(a.w)
+	t := -a.w.Dot(ab)
+	if t <= 0 {
+		return []minkowskiPoint{a}, []float64{1}
+	}
+	l := ab.LengthSquared()
+	if t >= l {
+		return []minkowskiPoint{b}, []float64{1}
+	}
+	return []minkowskiPoint{a, b}, []float64{1 - t/l, t / l}
+}
+
+// closestOnTriangle finds the closest point to the origin by checking which region of the
+// triangle's vertices, edges and face it falls in
+func closestOnTriangle(a, b, c minkowskiPoint) ([]minkowskiPoint, []float64) {
+	ab, ac, ao := b.w.Minus(a.w), c.w.Minus(a.w), a.w.Negated()
+	d1, d2 := ab.Dot(ao), ac.Dot(ao)
+	if d1 <= 0 && d2 <= 0 {
+		return []minkowskiPoint{a}, []float64{1}
+	}
+	bo := b.w.Negated()
+	d3, d4 := ab.Dot(bo), ac.Dot(bo)
+	if d3 >= 0 && d4 <= d3 {
+		return []minkowskiPoint{b}, []float64{1}
+	}
+	vc := d1*d4 - d3*d2
+	if vc <= 0 && d1 >= 0 && d3 <= 0 {
+		t := d1 / (d1 - d3)
+		return []minkowskiPoint{a, b}, []float64{1 - t, t}
+	}
+	co := c.w.Negated()
+	d5, d6 := ab.Dot(co), ac.Dot(co)
+	if d6 >= 0 && d5 <= d6 {
+		return []minkowskiPoint{c}, []float64{1}
+	}
+	vb := d5*d2 - d1*d6
+	if vb <= 0 && d2 >= 0 && d6 <= 0 {
+		t := d2 / (d2 - d6)
+		return []minkowskiPoint{a, c}, []float64{1 - t, t}
+	}
+	va := d3*d6 - d5*d4
+	if va <= 0 && d4-d3 >= 0 && d5-d6 >= 0 {
+		t := (d4 - d3) / ((d4 - d3) + (d5 - d6))
+		return []minkowskiPoint{b, c}, []float64{1 - t, t}
+	}
+	denom := 1 / (va + vb + vc)
+	v, w := vb*denom, vc*denom
+	return []minkowskiPoint{a, b, c}, []float64{1 - v - w, v, w}
+}
+
+// tetrahedronWeights returns the barycentric coordinates of the origin in the tetrahedron
+func tetrahedronWeights(s []minkowskiPoint) []float64 {
+	volume := func(a, b, c, d *csg.Vector) float64 {
+		return b.Minus(a).Cross(c.Minus(a)).Dot(d.Minus(a))
+	}
+	o := &csg.Vector{}
+	total := volume(s[0].w, s[1].w, s[2].w, s[3].w)
+	if total == 0 {
+		return []float64{0.25, 0.25, 0.25, 0.25}
+	}
+	return []float64{
+		volume(o, s[1].w, s[2].w, s[3].w) / total,
+		volume(s[0].w, o, s[2].w, s[3].w) / total,
+		volume(s[0].w, s[1].w, o, s[3].w) / total,
+		volume(s[0].w, s[1].w, s[2].w, o) / total,
+	}
+}
+
+// epaFace is a triangle of the polytope expanded by EPA, with its normal facing outwards
+type epaFace struct {
+	v        [3]int
+	normal   *csg.Vector
+	distance float64
+}
+
+// epa expands the simplex containing the origin into a polytope until the face of the Minkowski
+// difference closest to the origin is found, its distance is the penetration depth
+func epa(m *minkowski, simplex []minkowskiPoint) *Contact {
+	points := append([]minkowskiPoint{}, simplex...)
+	scale := 0.0
+	for _, p := range points {
+		scale = math.Max(scale, p.w.Length())
+	}
+	points = fillSimplex(m, points, scale)
+	if len(points) < 4 {
+		// the difference is flat, which only happens if both hulls are
+		pa, pb := m.witnesses(points[:1], []float64{1})
+		return &Contact{A: pa, B: pb, Normal: &csg.Vector{Z: 1}}
+	}
+
+	center := combine(points, []float64{0.25, 0.25, 0.25, 0.25})
+	newFace := func(i, j, k int) *epaFace {
+		f := &epaFace{v: [3]int{i, j, k}}
+		a, b, c := points[i].w, points[j].w, points[k].w
+		n := b.Minus(a).Cross(c.Minus(a))
+		if n.Dot(a.Minus(center)) < 0 {
+			f.v[1], f.v[2] = f.v[2], f.v[1]
+			n = n.Negated()
+		}
+		if l := n.Length(); l > 0 {
+			f.normal = n.DividedBy(l)
+			f.distance = f.normal.Dot(a)
+		} else {
+			f.normal = n
+			f.distance = math.Inf(1)
+		}
+		return f
+	}
+	faces := []*epaFace{newFace(0, 1, 2), newFace(0, 3, 1), newFace(0, 2, 3), newFace(1, 3, 2)}
+
+	var closest *epaFace
+	for i := 0; i < gjkMaxIterations; i++ {
+		closest = faces[0]
+		for _, f := range faces[1:] {
+			if f.distance < closest.distance {
+				closest = f
+			}
+		}
+		p := m.support(closest.normal)
+		if p.w.Dot(closest.normal)-closest.distance <= gjkTolerance*math.Max(scale, 1) {
+			break
+		}
+
+		// remove the faces the new point can see, leaving a hole bounded by the horizon
+		edges := make(map[[2]int]bool)
+		kept := faces[:0]
+		for _, f := range faces {
+			if f.normal.Dot(p.w.Minus(points[f.v[0]].w)) <= 0 {
+				kept = append(kept, f)
+				continue
+			}
+			for e := 0; e < 3; e++ {
+				edge := [2]int{f.v[e], f.v[(e+1)%3]}
+				if reverse := [2]int{edge[1], edge[0]}; edges[reverse] {
+					delete(edges, reverse)
+				} else {
+					edges[edge] = true
+				}
+			}
+		}
+		if len(edges) == 0 {
+			break
+		}
+		faces = kept
+		points = append(points, p)
+		for edge := range edges {
+			faces = append(faces, newFace(edge[0], edge[1], len(points)-1))
+		}
+	}
+
+	// the deepest points are where the origin projects on to the closest face
+	face := []minkowskiPoint{points[closest.v[0]], points[closest.v[1]], points[closest.v[2]]}
+	pa, pb := m.witnesses(face, triangleWeights(face, closest.normal.Times(closest.distance)))
+	return &Contact{Distance: -closest.distance, A: pa, B: pb, Normal: closest.normal}
+}
+
+// triangleWeights returns the barycentric coordinates of p, which lies in the plane of the
+// triangle, clamped on to the triangle
+func triangleWeights(t []minkowskiPoint, p *csg.Vector) []float64 {
+	shifted := make([]minkowskiPoint, 3)
+	for i := range t {
+		shifted[i] = minkowskiPoint{w: t[i].w.Minus(p), ia: i}
+	}
+	points, w := closestOnTriangle(shifted[0], shifted[1], shifted[2])
+	weights := make([]float64, 3)
+	for i, q := range points {
+		weights[q.ia] = w[i]
+	}
+	return weights
+}
+
+// fillSimplex adds support points to a simplex with fewer than four points until it's a
+// tetrahedron which isn't flat, the origin stays on or inside the simplex
+func fillSimplex(m *minkowski, points []minkowskiPoint, scale float64) []minkowskiPoint {
+	tiny := gjkTolerance * math.Max(scale, 1)
+	axes := []*csg.Vector{{X: 1}, {Y: 1}, {Z: 1}, {X: -1}, {Y: -1}, {Z: -1}}
+	try := func(directions []*csg.Vector, accept func(p minkowskiPoint) bool) bool {
+		for _, d := range directions {
+			if p := m.support(d); accept(p) {
+				points = append(points, p)
+				return true
+			}
+		}
+		return false
+	}
+	if len(points) == 1 {
+		if !try(axes, func(p minkowskiPoint) bool { return p.w.Minus(points[0].w).Length() > tiny }) {
+			return points
+		}
+	}
+	if len(points) == 2 {
+		ab := points[1].w.Minus(points[0].w)
+		directions := make([]*csg.Vector, 0, 6)
+		for _, axis := range axes {
+			if n := ab.Cross(axis); n.Length() > tiny {
+				directions = append(directions, n)
+			}
+		}
+		if !try(directions, func(p minkowskiPoint) bool {
+			return ab.Cross(p.w.Minus(points[0].w)).Length() > tiny*ab.Length()
+		}) {
+			return points
+		}
+	}
+	if len(points) == 3 {
+		n := points[1].w.Minus(points[0].w).Cross(points[2].w.Minus(points[0].w)).Unit()
+		try([]*csg.Vector{n, n.Negated()}, func(p minkowskiPoint) bool {
+			return math.Abs(n.Dot(p.w.Minus(points[0].w))) > tiny
+		})
+	}
+	return points
+}
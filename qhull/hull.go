@@ -1,6 +1,7 @@
 package qhull
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"math"
@@ -13,9 +14,17 @@ const DOUBLE_PREC = 2.2204460492503131e-16
 
 //Hull creates a hull between two 3d meshes
 type Hull struct {
-	findIndex          int
-	charLength         float64
-	Debug              bool
+	findIndex  int
+	charLength float64
+	Debug      bool
+	// Progress if specified is called after each point is added to the hull, with the number
+	// of points added so far and the number of faces created
+	Progress func(added int, faces int)
+	// Options if specified set the distance within which points are considered to be on the faces
+	// of the hull to their Tolerance. By default, or with AutoTolerance, the tolerance is derived
+	// from the magnitude of the coordinates of the points when the hull is built.
+	Options *csg.Options
+
 	points             []*Vertex
 	vertexPointIndices []int
 
@@ -35,6 +44,20 @@ type Hull struct {
 
 	explicitTolerance float64
 	tolerance         float64
+
+	// hullPoints and neighbors are the vertices of the built hull and the vertices joined to each
+	// by an edge, which are used for support queries
+	hullPoints []*csg.Vector
+	neighbors  [][]int
+}
+
+// Tolerance returns the tolerance used to build the hull, which is only known once the hull has been built
+// when using an automatic tolerance
+func (q *Hull) Tolerance() float64 {
+	if q.explicitTolerance != AUTOMATIC_TOLERANCE {
+		return q.explicitTolerance
+	}
+	return q.tolerance
 }
 
 func (q *Hull) markFaceVertices(face *Face, mark int) {
@@ -91,10 +114,17 @@ func (q *Hull) initBuffers(nump int) {
 	q.newFaces = &FaceList{}
 	q.numFaces = 0
 	q.numPoints = nump
+	q.hullPoints = nil
+	q.neighbors = nil
 }
 
 //Build a hull given a set of vectors (as points)
 func (q *Hull) Build(points []*csg.Vector, nump int) error {
+	return q.BuildCtx(context.Background(), points, nump)
+}
+
+// BuildCtx is the same as Build, but will stop and return the context's error if the context is cancelled
+func (q *Hull) BuildCtx(ctx context.Context, points []*csg.Vector, nump int) error {
 
 	if nump < 4 {
 		return fmt.Errorf("Less than four input points specified")
@@ -103,11 +133,68 @@ func (q *Hull) Build(points []*csg.Vector, nump int) error {
 		return fmt.Errorf("Point array too small for specified number of points")
 	}
 
+	q.explicitTolerance = AUTOMATIC_TOLERANCE
+	if q.Options != nil && !q.Options.AutoTolerance {
+		q.explicitTolerance = q.Options.Tolerance
+	}
 	q.initBuffers(nump)
 	q.setPoints(points, nump)
-	q.buildHull()
+	return q.buildHull(ctx)
+}
 
-	return nil
+// built checks if the hull has been built successfully
+func (q *Hull) built() bool {
+	return q.neighbors != nil
+}
+
+// AddPoint extends a hull which has already been built to include the point, see AddPoints
+func (q *Hull) AddPoint(point *csg.Vector) error {
+	return q.AddPoints([]*csg.Vector{point})
+}
+
+// AddPoints extends a hull which has already been built to include the points, updating the
+// existing faces rather than rebuilding the hull. Points inside the hull leave it unchanged. The
+// points are numbered after those the hull was built from, for faces which are POINT_RELATIVE.
+func (q *Hull) AddPoints(points []*csg.Vector) error {
+	if !q.built() {
+		return fmt.Errorf("Hull has not been built")
+	}
+
+	if q.explicitTolerance == AUTOMATIC_TOLERANCE {
+		// widen the tolerance if the points extend the range of the coordinates
+		var extent csg.Vector
+		for _, p := range append(q.Vertices(), points...) {
+			extent.X = math.Max(extent.X, math.Abs(p.X))
+			extent.Y = math.Max(extent.Y, math.Abs(p.Y))
+			extent.Z = math.Max(extent.Z, math.Abs(p.Z))
+		}
+		q.tolerance = math.Max(q.tolerance, 3*DOUBLE_PREC*(extent.X+extent.Y+extent.Z))
+	}
+
+	for _, face := range q.faces {
+		face.outside = nil
+	}
+	q.claimed.Clear()
+	for _, p := range points {
+		vtx := NewVertex(p, q.numPoints)
+		q.points = append(q.points, vtx)
+		q.vertexPointIndices = append(q.vertexPointIndices, 0)
+		q.numPoints++
+
+		// claim the point for the face it's furthest outside of
+		maxDist := q.tolerance
+		var maxFace *Face
+		for _, face := range q.faces {
+			if dist := face.DistanceToPlane(p); dist > maxDist {
+				maxFace = face
+				maxDist = dist
+			}
+		}
+		if maxFace != nil {
+			q.addPointToFace(vtx, maxFace)
+		}
+	}
+	return q.addClaimedPoints(context.Background())
 }
 
 func (q *Hull) setPoints(points []*csg.Vector, nump int) {
@@ -614,7 +701,12 @@ func (q *Hull) ToCSG() *csg.CSG {
 
 //Faces returns the faces which constitude this hull
 func (q *Hull) Faces() [][]int {
-	indexFlags := 0
+	return q.FacesWithFlags(0)
+}
+
+// FacesWithFlags returns the faces which constitute this hull, with the ordering and indexing of the
+// vertices of each face controlled by the flags
+func (q *Hull) FacesWithFlags(indexFlags int) [][]int {
 	allFaces := make([][]int, len(q.faces))
 	k := 0
 	for _, face := range q.faces {
@@ -622,12 +714,18 @@ func (q *Hull) Faces() [][]int {
 		k++
 	}
 	return allFaces
-
 }
 
+// CLOCKWISE orders the vertices of each face clockwise when viewed from outside of the hull
 const CLOCKWISE = 0x1
+
+// INDEXED_FROM_ONE numbers the vertices from one rather than zero
 const INDEXED_FROM_ONE = 0x2
+
+// INDEXED_FROM_ZERO numbers the vertices from zero, which is the default
 const INDEXED_FROM_ZERO = 0x4
+
+// POINT_RELATIVE uses the indices of the input points rather than the indices of the hull vertices
 const POINT_RELATIVE = 0x8
 
 func (q *Hull) getFaceIndices(face *Face, flags int) []int {
@@ -661,17 +759,28 @@ func (q *Hull) getFaceIndices(face *Face, flags int) []int {
 	return indices
 }
 
-func (q *Hull) buildHull() error {
-	cnt := 0
-	eyeVtx := &Vertex{}
-
+func (q *Hull) buildHull(ctx context.Context) error {
 	q.computeMinAndMax()
 	err := q.createInitialSimplex()
 	if err != nil {
 		return err
 	}
+	return q.addClaimedPoints(ctx)
+}
+
+// addClaimedPoints adds the points which are outside of the faces of the hull to it, and then
+// reindexes the faces and vertices
+func (q *Hull) addClaimedPoints(ctx context.Context) error {
+	cnt := 0
+	eyeVtx := &Vertex{}
 
 	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+
 		eyeVtx = q.nextPointToAdd()
 		if eyeVtx == nil {
 			break
@@ -681,8 +790,12 @@ func (q *Hull) buildHull() error {
 		if q.Debug {
 			log.Printf("iteration %d done", cnt)
 		}
+		if q.Progress != nil {
+			q.Progress(cnt, len(q.faces))
+		}
 	}
 	q.reindexFacesAndVertices()
+	q.buildNeighbors()
 	if q.Debug {
 		log.Printf("hull done")
 	}
@@ -1,12 +1,19 @@
 package qhull
 
 import (
+	"bytes"
+	"context"
+	"fmt"
+	"math"
+	"math/rand"
 	"os"
 	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/celer/csg/csg"
+	"github.com/celer/csg/csgtest"
 )
 
 func init() {
@@ -35,7 +42,7 @@ func TestHotDog(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	SaveCSG(h, "HotDog.stl")
+	csgtest.AssertMesh(t, "HotDog", h.ToCSG(), nil)
 }
 
 func TestV(t *testing.T) {
@@ -145,3 +152,316 @@ func TestSimpleHull(t *testing.T) {
 	SaveCSG(h, "SimpleHull.stl")
 
 }
+
+func TestBuildCtx(t *testing.T) {
+	s1 := csg.NewSphere(&csg.SphereOptions{Center: &csg.Vector{0, 0, 0}, Slices: 50, Stacks: 50})
+	points := make([]*csg.Vector, 0)
+	for _, p := range s1.ToPolygons() {
+		for _, v := range p.Vertices {
+			points = append(points, v.Position)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	h := &Hull{}
+	h.Progress = func(added, faces int) {
+		if added == 10 {
+			cancel()
+		}
+	}
+	err := h.BuildCtx(ctx, points, len(points))
+	if err != context.Canceled {
+		t.Fatalf("Expected build to be cancelled, got %v", err)
+	}
+
+	err = (&Hull{}).Build(points[:3], 3)
+	if err == nil {
+		t.Fatal("Expected an error for too few points")
+	}
+
+	err = (&Hull{}).Build([]*csg.Vector{{0, 0, 0}, {1, 0, 0}, {2, 0, 0}, {3, 0, 0}}, 4)
+	if err == nil {
+		t.Fatal("Expected an error for colinear points")
+	}
+}
+
+func TestTolerance(t *testing.T) {
+	// a cube with a point just above the center of the top face
+	points := make([]*csg.Vector, 0)
+	for _, p := range csg.NewCube(nil).ToPolygons() {
+		for _, v := range p.Vertices {
+			points = append(points, v.Position)
+		}
+	}
+	points = append(points, &csg.Vector{0, 0.5001, 0})
+
+	h := &Hull{}
+	if err := h.Build(points, len(points)); err != nil {
+		t.Fatal(err)
+	}
+	if len(h.Vertices()) != 9 || h.Tolerance() <= 0 || h.Tolerance() > 1e-10 {
+		t.Fatalf("Expected 9 vertices with an automatic tolerance, got %d and %g", len(h.Vertices()), h.Tolerance())
+	}
+
+	h = &Hull{Options: &csg.Options{Tolerance: 0.001}}
+	if err := h.Build(points, len(points)); err != nil {
+		t.Fatal(err)
+	}
+	if len(h.Vertices()) != 8 || h.Tolerance() != 0.001 {
+		t.Fatalf("Expected 8 vertices with a tolerance of 0.001, got %d and %g", len(h.Vertices()), h.Tolerance())
+	}
+}
+
+func TestPLY(t *testing.T) {
+	s1 := csg.NewSphere(&csg.SphereOptions{Slices: 20, Stacks: 10})
+	points := make([]*csg.Vector, 0)
+	for _, p := range s1.ToPolygons() {
+		for _, v := range p.Vertices {
+			points = append(points, v.Position)
+		}
+	}
+
+	for _, format := range []csg.PLYFormat{csg.PLY_ASCII, csg.PLY_BINARY_LITTLE_ENDIAN, csg.PLY_BINARY_BIG_ENDIAN} {
+		b := &bytes.Buffer{}
+		if err := WritePLYPoints(b, format, points); err != nil {
+			t.Fatal(err)
+		}
+		read, err := ReadPLYPoints(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(read) != len(points) || !read[7].Equals(points[7]) {
+			t.Fatalf("Expected %d points to be read in %s, got %d", len(points), format, len(read))
+		}
+
+		h := &Hull{}
+		if err := h.Build(read, len(read)); err != nil {
+			t.Fatal(err)
+		}
+		b.Reset()
+		if err := h.MarshalToPLY(b, format); err != nil {
+			t.Fatal(err)
+		}
+		c, err := csg.NewCSGFromPLY(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(c.ToPolygons()) != len(h.Faces()) {
+			t.Fatalf("Expected %d faces to be read in %s, got %d", len(h.Faces()), format, len(c.ToPolygons()))
+		}
+	}
+}
+
+func TestOFFAndVTK(t *testing.T) {
+	points := []*csg.Vector{{0.1, 0.2, 0.3}}
+	for _, p := range csg.NewCube(nil).ToPolygons() {
+		for _, v := range p.Vertices {
+			points = append(points, v.Position)
+		}
+	}
+	h := &Hull{}
+	if err := h.Build(points, len(points)); err != nil {
+		t.Fatal(err)
+	}
+
+	b := &bytes.Buffer{}
+	if err := h.MarshalToOFF(b, 0); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(b.String(), "OFF\n8 6 0\n") {
+		t.Fatalf("Expected an OFF file with 8 vertices and 6 faces, got %q", b.String())
+	}
+
+	// the indices in the file match the indices of the input points
+	flags := POINT_RELATIVE | INDEXED_FROM_ONE | CLOCKWISE
+	b.Reset()
+	if err := h.MarshalToOFF(b, flags); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(b.String()), "\n")
+	if lines[1] != fmt.Sprintf("%d 6 0", len(points)+1) {
+		t.Fatalf("Expected every point to be written, got %q", lines[1])
+	}
+	faces := h.FacesWithFlags(flags)
+	if lines[2+len(points)+1] != fmt.Sprintf("4 %d %d %d %d", faces[0][0], faces[0][1], faces[0][2], faces[0][3]) {
+		t.Fatalf("Expected the first face to be %v, got %q", faces[0], lines[2+len(points)+1])
+	}
+	for _, f := range faces {
+		for _, i := range f {
+			if i < 2 || i > len(points) {
+				t.Fatalf("Expected the interior point not to be used and indices to start at one, got %v", f)
+			}
+		}
+	}
+	ccw := h.Faces()[0]
+	if !points[faces[0][0]-1].Equals(h.Vertices()[ccw[0]]) || !points[faces[0][1]-1].Equals(h.Vertices()[ccw[3]]) {
+		t.Fatalf("Expected the clockwise face to be the reverse of the counter clockwise face")
+	}
+
+	b.Reset()
+	if err := h.MarshalToVTK(b, "cube", 0); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(b.String(), "\nPOINTS 8 double\n") || !strings.Contains(b.String(), "\nPOLYGONS 6 30\n") {
+		t.Fatalf("Expected a VTK file with 8 points and 6 polygons, got %q", b.String())
+	}
+	if err := h.MarshalToVTK(b, "two\nlines", 0); err == nil {
+		t.Fatal("Expected an error for a multiline title")
+	}
+}
+
+func TestDistance(t *testing.T) {
+	hull := func(c *csg.CSG) *Hull {
+		h := &Hull{}
+		if err := h.BuildFromCSG([]*csg.CSG{c}); err != nil {
+			t.Fatal(err)
+		}
+		return h
+	}
+	cube := func(center *csg.Vector) *Hull {
+		return hull(csg.NewCube(&csg.CubeOptions{Center: center, Size: &csg.Vector{2, 2, 2}}))
+	}
+	near := func(a, b float64) bool {
+		return math.Abs(a-b) < 1e-9
+	}
+
+	a := cube(&csg.Vector{})
+	for _, test := range []struct {
+		center   *csg.Vector
+		distance float64
+		normal   *csg.Vector
+	}{
+		{&csg.Vector{5, 0, 0}, 3, &csg.Vector{1, 0, 0}},
+		{&csg.Vector{0, 0, -3}, 1, &csg.Vector{0, 0, -1}},
+		{&csg.Vector{4, 4, 0.5}, 2 * math.Sqrt2, &csg.Vector{math.Sqrt2 / 2, math.Sqrt2 / 2, 0}},
+		{&csg.Vector{1.5, 0.2, 0.1}, -0.5, &csg.Vector{1, 0, 0}},
+		{&csg.Vector{0, -1.75, 0}, -0.25, &csg.Vector{0, -1, 0}},
+		{&csg.Vector{2, 0.5, 0}, 0, &csg.Vector{1, 0, 0}},
+	} {
+		c, err := Distance(a, cube(test.center))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !near(c.Distance, test.distance) || c.Normal.Minus(test.normal).Length() > 1e-9 ||
+			!near(c.B.Minus(c.A).Dot(c.Normal), math.Abs(test.distance)*math.Copysign(1, test.distance)) {
+			t.Errorf("Expected a distance of %f along %v for a cube at %v got %f along %v between %v and %v",
+				test.distance, test.normal, test.center, c.Distance, c.Normal, c.A, c.B)
+		}
+	}
+
+	// the distance is the gap between the hulls along the normal, and no direction separates
+	// overlapping hulls with a smaller movement
+	extent := func(h *Hull, d *csg.Vector) (float64, float64) {
+		min, max := math.Inf(1), math.Inf(-1)
+		for _, v := range h.Vertices() {
+			min, max = math.Min(min, v.Dot(d)), math.Max(max, v.Dot(d))
+		}
+		return min, max
+	}
+	s := hull(csg.NewSphere(&csg.SphereOptions{Radius: 1, Slices: 16, Stacks: 8}))
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 100; i++ {
+		offset := &csg.Vector{r.Float64()*6 - 3, r.Float64()*6 - 3, r.Float64()*6 - 3}
+		b := hull(csg.NewSphere(&csg.SphereOptions{Radius: 0.5, Slices: 12, Stacks: 6}).
+			Rotate(&csg.Vector{r.Float64(), r.Float64(), r.Float64()}, r.Float64()*360).Translate(offset))
+		c, err := Distance(s, b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, maxA := extent(s, c.Normal)
+		minB, _ := extent(b, c.Normal)
+		if !near(minB-maxA, c.Distance) || !near(c.A.Dot(c.Normal), maxA) || !near(c.B.Dot(c.Normal), minB) {
+			t.Errorf("Expected a distance of %f along %v got %f", minB-maxA, c.Normal, c.Distance)
+		}
+		if c.Distance < 0 {
+			for j := 0; j < 50; j++ {
+				d := (&csg.Vector{r.NormFloat64(), r.NormFloat64(), r.NormFloat64()}).Unit()
+				_, maxA := extent(s, d)
+				minB, _ := extent(b, d)
+				if maxA-minB < -c.Distance-1e-9 {
+					t.Errorf("Expected no direction to separate the hulls by less than %f got %f along %v", -c.Distance, maxA-minB, d)
+				}
+			}
+		}
+	}
+
+	// the first points of identical hulls coincide
+	for _, b := range []*Hull{a, cube(&csg.Vector{})} {
+		c, err := Distance(a, b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !near(c.Distance, -2) || !near(c.Normal.Length(), 1) {
+			t.Errorf("Expected a distance of -2 for identical cubes got %f along %v", c.Distance, c.Normal)
+		}
+	}
+
+	if _, err := Distance(a, &Hull{}); err == nil {
+		t.Errorf("Expected an error for a hull which hasn't been built")
+	}
+}
+
+func TestAddPoints(t *testing.T) {
+	points := make([]*csg.Vector, 0)
+	for _, p := range csg.NewCube(&csg.CubeOptions{Size: &csg.Vector{2, 2, 2}}).ToPolygons() {
+		for _, v := range p.Vertices {
+			points = append(points, v.Position)
+		}
+	}
+	h := &Hull{}
+	if err := h.AddPoint(&csg.Vector{}); err == nil {
+		t.Errorf("Expected an error adding to a hull which hasn't been built")
+	}
+	if err := h.Build(points, len(points)); err != nil {
+		t.Fatal(err)
+	}
+
+	// a point inside changes nothing, one above the top face makes a pyramid on it
+	added := []*csg.Vector{{0.5, 0.5, 0.5}, {0, 2, 0}}
+	if err := h.AddPoints(added); err != nil {
+		t.Fatal(err)
+	}
+	c := h.ToCSG()
+	if len(h.Vertices()) != 9 || len(h.Faces()) != 9 || math.Abs(c.Volume()-8-4.0/3) > 1e-9 || c.Validate() != nil {
+		t.Fatalf("Expected a cube with a pyramid on top got %d vertices, %d faces and a volume of %f",
+			len(h.Vertices()), len(h.Faces()), c.Volume())
+	}
+
+	// adding points in batches matches building from all of them
+	r := rand.New(rand.NewSource(1))
+	all := append(append([]*csg.Vector{}, points...), added...)
+	for i := 0; i < 20; i++ {
+		batch := make([]*csg.Vector, 1+r.Intn(20))
+		for j := range batch {
+			batch[j] = (&csg.Vector{r.NormFloat64(), r.NormFloat64(), r.NormFloat64()}).Times(2)
+		}
+		if err := h.AddPoints(batch); err != nil {
+			t.Fatal(err)
+		}
+		all = append(all, batch...)
+	}
+	expected := &Hull{}
+	if err := expected.Build(all, len(all)); err != nil {
+		t.Fatal(err)
+	}
+	vertices := func(h *Hull) map[csg.Vector]bool {
+		m := make(map[csg.Vector]bool)
+		for _, v := range h.Vertices() {
+			m[*v] = true
+		}
+		return m
+	}
+	if !reflect.DeepEqual(vertices(h), vertices(expected)) || math.Abs(h.ToCSG().Volume()-expected.ToCSG().Volume()) > 1e-9 {
+		t.Errorf("Expected the same hull as building from every point got %d vertices and %d", len(h.Vertices()), len(expected.Vertices()))
+	}
+	if err := h.ToCSG().Validate(); err != nil {
+		t.Error(err)
+	}
+	for _, face := range h.FacesWithFlags(POINT_RELATIVE) {
+		for _, i := range face {
+			if all[i] != h.points[i].point {
+				t.Fatalf("Expected point %d to be numbered in the order it was added", i)
+			}
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package qhull
+
+import (
+	"io"
+
+	"github.com/celer/csg/csg"
+)
+
+// indexedPoints returns the points referenced by the faces returned by FacesWithFlags, when the
+// faces are indexed from one an unused point is placed at the start so the indices still match
+func (q *Hull) indexedPoints(flags int) []*csg.Vector {
+	points := make([]*csg.Vector, 0, q.numPoints+1)
+	if (flags & INDEXED_FROM_ONE) != 0 {
+		points = append(points, &csg.Vector{})
+	}
+	if (flags & POINT_RELATIVE) != 0 {
+		for i := 0; i < q.numPoints; i++ {
+			points = append(points, q.points[i].point)
+		}
+		return points
+	}
+	return append(points, q.Vertices()...)
+}
+
+// MarshalToOFF writes the hull out as an OFF file using the same indices as FacesWithFlags. When the
+// flags include POINT_RELATIVE all of the input points are written, and when they include
+// INDEXED_FROM_ONE an unused point is written first so that the indices in the file match.
+func (q *Hull) MarshalToOFF(out io.Writer, flags int) error {
+	return csg.WriteOFF(out, q.indexedPoints(flags), q.FacesWithFlags(flags))
+}
+
+// MarshalToVTK writes the hull out as a legacy VTK file using the same indices as FacesWithFlags, the
+// flags are handled as they are by MarshalToOFF
+func (q *Hull) MarshalToVTK(out io.Writer, title string, flags int) error {
+	return csg.WriteVTK(out, title, q.indexedPoints(flags), q.FacesWithFlags(flags))
+}
@@ -0,0 +1,40 @@
+package qhull
+
+import (
+	"io"
+
+	"github.com/celer/csg/csg"
+)
+
+// ReadPLYPoints reads the positions of the vertices in a PLY file, such as a point cloud from a
+// scanner, which can then be passed to Build
+func ReadPLYPoints(in io.Reader) ([]*csg.Vector, error) {
+	vertices, _, err := csg.ReadPLY(in)
+	if err != nil {
+		return nil, err
+	}
+	points := make([]*csg.Vector, len(vertices))
+	for i, v := range vertices {
+		points[i] = v.Position
+	}
+	return points, nil
+}
+
+// WritePLYPoints writes the points to a PLY file as a point cloud
+func WritePLYPoints(out io.Writer, format csg.PLYFormat, points []*csg.Vector) error {
+	vertices := make([]*csg.Vertex, len(points))
+	for i, p := range points {
+		vertices[i] = &csg.Vertex{Position: p}
+	}
+	return csg.WritePLY(out, format, vertices, nil)
+}
+
+// MarshalToPLY writes the hull out as a PLY mesh, with the vertices and faces of the hull
+func (q *Hull) MarshalToPLY(out io.Writer, format csg.PLYFormat) error {
+	points := q.Vertices()
+	vertices := make([]*csg.Vertex, len(points))
+	for i, p := range points {
+		vertices[i] = &csg.Vertex{Position: p}
+	}
+	return csg.WritePLY(out, format, vertices, q.Faces())
+}
@@ -0,0 +1,445 @@
+// Package render draws CSGs to images with a CPU z-buffer rasterizer, so that thumbnails can be
+// generated without a GPU.
+package render
+
+import (
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"math"
+
+	"github.com/celer/csg/csg"
+)
+
+// Projection is the projection used by a camera
+type Projection int
+
+const (
+	// ORTHOGRAPHIC projects parallel lines as parallel lines, so the size of an object doesn't
+	// depend on its distance from the camera
+	ORTHOGRAPHIC Projection = iota
+	// PERSPECTIVE makes objects further from the camera smaller
+	PERSPECTIVE
+)
+
+// Camera is the viewpoint an image is rendered from
+type Camera struct {
+	// Projection of the camera
+	Projection Projection
+	// Position of the camera
+	Position *csg.Vector
+	// Target is the point the camera looks at
+	Target *csg.Vector
+	// Up is the direction which is up in the image, if not specified the Y axis is used
+	Up *csg.Vector
+	// FieldOfView of a perspective camera in degrees across the shorter side of the image, if
+	// not specified 40 degrees is used
+	FieldOfView float64
+	// Extent of the view of an orthographic camera in world units across the shorter side of the
+	// image, if not specified the distance from the position to the target is used
+	Extent float64
+}
+
+// NewCamera returns a camera looking at the center of the box from the direction, far enough
+// away that all of the box is in view
+func NewCamera(projection Projection, box *csg.Box, direction, up *csg.Vector) *Camera {
+	center := box.Center()
+	radius := box.Size().Length() / 2
+	if radius == 0 {
+		radius = 1
+	}
+	c := &Camera{Projection: projection, Target: center, Up: up, FieldOfView: 40}
+	distance := 4 * radius
+	if projection == PERSPECTIVE {
+		distance = 1.05 * radius / math.Sin(c.FieldOfView*math.Pi/360)
+	} else {
+		c.Extent = 2.1 * radius
+	}
+	c.Position = center.Plus(direction.Unit().Times(distance))
+	return c
+}
+
+// Options control how an image is rendered
+type Options struct {
+	// Width and Height of the image in pixels, if not specified 256 is used
+	Width, Height int
+	// Camera to render from, if not specified an orthographic camera looking at the whole of
+	// the CSG from the direction 1,1,1 is used
+	Camera *Camera
+	// Light is the direction towards a directional light, if not specified the light is above
+	// and to the left of the camera
+	Light *csg.Vector
+	// Ambient is the fraction of light which reaches surfaces facing away from the light, if
+	// not specified 0.2 is used
+	Ambient float64
+	// Color of polygons which have no color of their own, if not specified light gray is used
+	Color color.Color
+	// Background of the image, if not specified the background is transparent
+	Background color.Color
+	// Wireframe if specified is the color the visible edges of the polygons are drawn in
+	Wireframe color.Color
+	// Supersample renders the image this many times larger in each direction and scales it
+	// down to smooth edges, if not specified 1 is used
+	Supersample int
+}
+
+// view projects points from world space onto the image
+type view struct {
+	camera                    *Camera
+	right, up, forward        *csg.Vector
+	width, height             float64
+	scale, focal, near        float64
+	perspective               bool
+	light, toCamera           *csg.Vector
+	ambient                   float64
+	depth                     []float64
+	color                     [][3]float64
+	covered                   []bool
+	widthPixels, heightPixels int
+}
+
+func newView(c *Camera, width, height int) *view {
+	v := &view{camera: c, width: float64(width), height: float64(height), widthPixels: width, heightPixels: height}
+	v.forward = c.Target.Minus(c.Position)
+	distance := v.forward.Length()
+	if distance == 0 {
+		v.forward = &csg.Vector{Z: -1}
+		distance = 1
+	}
+	v.forward = v.forward.Unit()
+	up := c.Up
+	if up == nil {
+		up = &csg.Vector{Y: 1}
+	}
+	v.right = v.forward.Cross(up)
+	if v.right.Length() < 1e-9 {
+		// the camera is looking along the up direction, so any perpendicular direction will do
+		v.right = v.forward.Cross(&csg.Vector{X: 1})
+		if v.right.Length() < 1e-9 {
+			v.right = v.forward.Cross(&csg.Vector{Y: 1})
+		}
+	}
+	v.right = v.right.Unit()
+	v.up = v.right.Cross(v.forward)
+	v.toCamera = v.forward.Negated()
+
+	shorter := math.Min(v.width, v.height)
+	if c.Projection == PERSPECTIVE {
+		fov := c.FieldOfView
+		if fov <= 0 {
+			fov = 40
+		}
+		v.perspective = true
+		v.focal = shorter / 2 / math.Tan(fov*math.Pi/360)
+		v.near = distance * 1e-3
+	} else {
+		extent := c.Extent
+		if extent <= 0 {
+			extent = distance
+		}
+		v.scale = shorter / extent
+	}
+
+	v.depth = make([]float64, width*height)
+	for i := range v.depth {
+		v.depth[i] = math.Inf(1)
+	}
+	v.color = make([][3]float64, width*height)
+	v.covered = make([]bool, width*height)
+	return v
+}
+
+// point is a vertex projected onto the image
+type point struct {
+	x, y float64
+	// d increases with the distance from the camera and is linear across the image
+	d float64
+	// q is the reciprocal of the homogeneous w, used to interpolate attributes with perspective
+	q      float64
+	normal csg.Vector
+	color  [3]float64
+}
+
+// viewDepth returns the distance of the position in front of the camera
+func (v *view) viewDepth(p *csg.Vector) float64 {
+	return p.Minus(v.camera.Position).Dot(v.forward)
+}
+
+func (v *view) project(p *csg.Vector, normal csg.Vector, c [3]float64) point {
+	d := p.Minus(v.camera.Position)
+	depth := d.Dot(v.forward)
+	x, y := d.Dot(v.right), d.Dot(v.up)
+	if v.perspective {
+		return point{
+			x: v.width/2 + x*v.focal/depth, y: v.height/2 - y*v.focal/depth,
+			d: -1 / depth, q: 1 / depth, normal: normal, color: c,
+		}
+	}
+	return point{x: v.width/2 + x*v.scale, y: v.height/2 - y*v.scale, d: depth, q: 1, normal: normal, color: c}
+}
+
+// vertex is a vertex of a polygon being rendered, before it is projected
+type vertex struct {
+	position *csg.Vector
+	normal   csg.Vector
+	color    [3]float64
+}
+
+func lerp(a, b vertex, t float64) vertex {
+	r := vertex{position: a.position.Lerp(b.position, t)}
+	r.normal = *a.normal.Lerp(&b.normal, t)
+	for i := range r.color {
+		r.color[i] = a.color[i] + (b.color[i]-a.color[i])*t
+	}
+	return r
+}
+
+// clip removes the part of the polygon behind the near plane of a perspective camera
+func (v *view) clip(vertices []vertex) []vertex {
+	if !v.perspective {
+		return vertices
+	}
+	clipped := make([]vertex, 0, len(vertices)+1)
+	for i, a := range vertices {
+		b := vertices[(i+1)%len(vertices)]
+		da, db := v.viewDepth(a.position)-v.near, v.viewDepth(b.position)-v.near
+		if da >= 0 {
+			clipped = append(clipped, a)
+		}
+		if (da >= 0) != (db >= 0) {
+			clipped = append(clipped, lerp(a, b, da/(da-db)))
+		}
+	}
+	return clipped
+}
+
+func (v *view) triangle(a, b, c point) {
+	area := (b.x-a.x)*(c.y-a.y) - (b.y-a.y)*(c.x-a.x)
+	if area == 0 || math.IsNaN(area) || math.IsInf(area, 0) {
+		return
+	}
+	minX, maxX := math.Min(a.x, math.Min(b.x, c.x)), math.Max(a.x, math.Max(b.x, c.x))
+	minY, maxY := math.Min(a.y, math.Min(b.y, c.y)), math.Max(a.y, math.Max(b.y, c.y))
+	// skip triangles off the image before the bounds are converted to pixels, the comparisons are
+	// false for NaN
+	if !(minX < v.width && maxX >= 0 && minY < v.height && maxY >= 0) || math.IsInf(minX, 0) ||
+		math.IsInf(maxX, 0) || math.IsInf(minY, 0) || math.IsInf(maxY, 0) {
+		return
+	}
+	x0, x1 := int(math.Max(0, math.Floor(minX))), int(math.Min(v.width-1, math.Ceil(maxX)))
+	y0, y1 := int(math.Max(0, math.Floor(minY))), int(math.Min(v.height-1, math.Ceil(maxY)))
+
+	for py := y0; py <= y1; py++ {
+		y := float64(py) + 0.5
+		for px := x0; px <= x1; px++ {
+			x := float64(px) + 0.5
+			w0 := ((b.x-x)*(c.y-y) - (b.y-y)*(c.x-x)) / area
+			w1 := ((c.x-x)*(a.y-y) - (c.y-y)*(a.x-x)) / area
+			w2 := 1 - w0 - w1
+			if w0 < 0 || w1 < 0 || w2 < 0 {
+				continue
+			}
+			index := py*v.widthPixels + px
+			d := w0*a.d + w1*b.d + w2*c.d
+			if d >= v.depth[index] {
+				continue
+			}
+			v.depth[index] = d
+
+			// interpolate the attributes with perspective correction
+			p0, p1, p2 := w0*a.q, w1*b.q, w2*c.q
+			sum := p0 + p1 + p2
+			p0, p1, p2 = p0/sum, p1/sum, p2/sum
+			n := &csg.Vector{
+				X: p0*a.normal.X + p1*b.normal.X + p2*c.normal.X,
+				Y: p0*a.normal.Y + p1*b.normal.Y + p2*c.normal.Y,
+				Z: p0*a.normal.Z + p1*b.normal.Z + p2*c.normal.Z,
+			}
+			if l := n.Length(); l > 0 {
+				n = n.DividedBy(l)
+			}
+			// surfaces are lit from both sides
+			if n.Dot(v.toCamera) < 0 {
+				n = n.Negated()
+			}
+			intensity := v.ambient + (1-v.ambient)*math.Max(0, n.Dot(v.light))
+			for i := range v.color[index] {
+				v.color[index][i] = (p0*a.color[i] + p1*b.color[i] + p2*c.color[i]) * intensity
+			}
+			v.covered[index] = true
+		}
+	}
+}
+
+// line draws the parts of the line between the points which aren't hidden by polygons
+func (v *view) line(a, b point, c [3]float64) {
+	for _, f := range []float64{a.x, a.y, b.x, b.y} {
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			return
+		}
+	}
+	// clip the line to the image so the number of steps is bounded by the size of the image
+	dx, dy := b.x-a.x, b.y-a.y
+	t0, t1 := 0.0, 1.0
+	for _, e := range [][2]float64{{-dx, a.x}, {dx, v.width - a.x}, {-dy, a.y}, {dy, v.height - a.y}} {
+		if e[0] == 0 {
+			if e[1] < 0 {
+				return
+			}
+		} else if t := e[1] / e[0]; e[0] < 0 {
+			t0 = math.Max(t0, t)
+		} else {
+			t1 = math.Min(t1, t)
+		}
+	}
+	if t0 > t1 {
+		return
+	}
+
+	steps := int(math.Ceil(math.Max(math.Abs(dx), math.Abs(dy)) * (t1 - t0)))
+	if steps == 0 {
+		steps = 1
+	}
+	for i := 0; i <= steps; i++ {
+		t := t0 + (t1-t0)*float64(i)/float64(steps)
+		px := int(math.Floor(a.x + dx*t))
+		py := int(math.Floor(a.y + dy*t))
+		if px < 0 || py < 0 || px >= v.widthPixels || py >= v.heightPixels {
+			continue
+		}
+		index := py*v.widthPixels + px
+		d := a.d + (b.d-a.d)*t
+		// allow the line to be slightly behind the polygon it is an edge of
+		if d <= v.depth[index]+1e-3*math.Abs(d) {
+			v.color[index] = c
+			v.covered[index] = true
+		}
+	}
+}
+
+// components returns the unpremultiplied components of the color from 0 to 1
+func components(c color.Color) [4]float64 {
+	n := color.NRGBAModel.Convert(c).(color.NRGBA)
+	return [4]float64{float64(n.R) / 255, float64(n.G) / 255, float64(n.B) / 255, float64(n.A) / 255}
+}
+
+// Render draws the CSG to an image
+func Render(c *csg.CSG, options *Options) *image.NRGBA {
+	if options == nil {
+		options = &Options{}
+	}
+	width, height := options.Width, options.Height
+	if width <= 0 {
+		width = 256
+	}
+	if height <= 0 {
+		height = 256
+	}
+	samples := options.Supersample
+	if samples <= 0 {
+		samples = 1
+	}
+	camera := options.Camera
+	if camera == nil {
+		camera = NewCamera(ORTHOGRAPHIC, c.BoundingBox(), &csg.Vector{X: 1, Y: 1, Z: 1}, &csg.Vector{Y: 1})
+	}
+	v := newView(camera, width*samples, height*samples)
+	v.ambient = options.Ambient
+	if v.ambient <= 0 {
+		v.ambient = 0.2
+	}
+	if options.Light != nil {
+		v.light = options.Light.Unit()
+	} else {
+		v.light = v.toCamera.Plus(v.up.Times(0.6)).Minus(v.right.Times(0.4)).Unit()
+	}
+	base := [4]float64{0.8, 0.8, 0.8, 1}
+	if options.Color != nil {
+		base = components(options.Color)
+	}
+
+	polygons := c.ToPolygons()
+	for _, p := range polygons {
+		polygonColor := base
+		if p.Shared != nil && p.Shared.Color != nil {
+			polygonColor = components(p.Shared.Color)
+		}
+		for _, t := range p.Triangles() {
+			vertices := make([]vertex, len(t.Vertices))
+			for i, tv := range t.Vertices {
+				vertices[i] = vertex{position: tv.Position, normal: *p.Plane.Normal}
+				if tv.Normal != nil {
+					vertices[i].normal = *tv.Normal
+				}
+				rgba := polygonColor
+				if tv.Color != nil {
+					rgba = components(tv.Color)
+				}
+				copy(vertices[i].color[:], rgba[:3])
+			}
+			vertices = v.clip(vertices)
+			points := make([]point, len(vertices))
+			for i, vx := range vertices {
+				points[i] = v.project(vx.position, vx.normal, vx.color)
+			}
+			for i := 2; i < len(points); i++ {
+				v.triangle(points[0], points[i-1], points[i])
+			}
+		}
+	}
+
+	if options.Wireframe != nil {
+		wire := components(options.Wireframe)
+		c := [3]float64{wire[0], wire[1], wire[2]}
+		for _, p := range polygons {
+			for i, a := range p.Vertices {
+				b := p.Vertices[(i+1)%len(p.Vertices)]
+				edge := v.clip([]vertex{{position: a.Position}, {position: b.Position}})
+				if len(edge) < 2 {
+					continue
+				}
+				v.line(v.project(edge[0].position, csg.Vector{}, c), v.project(edge[1].position, csg.Vector{}, c), c)
+			}
+		}
+	}
+
+	background := [4]float64{}
+	if options.Background != nil {
+		background = components(options.Background)
+	}
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			// average the samples, premultiplied by alpha
+			var sum [4]float64
+			for sy := 0; sy < samples; sy++ {
+				for sx := 0; sx < samples; sx++ {
+					index := (y*samples+sy)*v.widthPixels + x*samples + sx
+					if v.covered[index] {
+						c := v.color[index]
+						sum[0], sum[1], sum[2], sum[3] = sum[0]+c[0], sum[1]+c[1], sum[2]+c[2], sum[3]+1
+					} else {
+						a := background[3]
+						sum[0], sum[1], sum[2], sum[3] = sum[0]+background[0]*a, sum[1]+background[1]*a, sum[2]+background[2]*a, sum[3]+a
+					}
+				}
+			}
+			n := float64(samples * samples)
+			alpha := sum[3] / n
+			if alpha == 0 {
+				continue
+			}
+			channel := func(f float64) uint8 {
+				return uint8(math.Round(math.Max(0, math.Min(1, f/n/alpha)) * 255))
+			}
+			img.SetNRGBA(x, y, color.NRGBA{channel(sum[0]), channel(sum[1]), channel(sum[2]), uint8(math.Round(alpha * 255))})
+		}
+	}
+	return img
+}
+
+// WritePNG draws the CSG to a PNG image
+func WritePNG(out io.Writer, c *csg.CSG, options *Options) error {
+	return png.Encode(out, Render(c, options))
+}
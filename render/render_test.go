@@ -0,0 +1,75 @@
+package render
+
+import (
+	"bytes"
+	"image/color"
+	"image/png"
+	"testing"
+
+	"github.com/celer/csg/csg"
+)
+
+func TestRender(t *testing.T) {
+	cube := csg.NewCube(nil)
+	img := Render(cube, &Options{Width: 64, Height: 48})
+	if b := img.Bounds(); b.Dx() != 64 || b.Dy() != 48 {
+		t.Fatalf("Expected a 64x48 image got %v", b)
+	}
+	if c := img.NRGBAAt(32, 24); c.A != 255 {
+		t.Errorf("Expected the cube to cover the center got %v", c)
+	}
+	if c := img.NRGBAAt(0, 0); c.A != 0 {
+		t.Errorf("Expected a transparent background got %v", c)
+	}
+	// the top face is lit more than the faces on the sides
+	top, side := img.NRGBAAt(32, 12), img.NRGBAAt(24, 32)
+	if top.R <= side.R {
+		t.Errorf("Expected the top %v to be brighter than the side %v", top, side)
+	}
+
+	// the nearer cube hides the further one, with the default camera looking from 1,1,1
+	near := csg.NewCube(&csg.CubeOptions{Center: &csg.Vector{X: 1, Y: 1, Z: 1}})
+	near.SetShared(&csg.Shared{Color: color.NRGBA{255, 0, 0, 255}})
+	far := csg.NewCube(&csg.CubeOptions{Center: &csg.Vector{X: -1, Y: -1, Z: -1}})
+	far.SetShared(&csg.Shared{Color: color.NRGBA{0, 0, 255, 255}})
+	both := near.Union(far)
+	for _, projection := range []Projection{ORTHOGRAPHIC, PERSPECTIVE} {
+		camera := NewCamera(projection, both.BoundingBox(), &csg.Vector{X: 1, Y: 1, Z: 1}, nil)
+		img = Render(both, &Options{Width: 32, Height: 32, Camera: camera, Background: color.White})
+		if c := img.NRGBAAt(16, 16); c.R == 0 || c.B != 0 {
+			t.Errorf("Expected the red cube in front for projection %d got %v", projection, c)
+		}
+		if c := img.NRGBAAt(0, 0); c != (color.NRGBA{255, 255, 255, 255}) {
+			t.Errorf("Expected a white background got %v", c)
+		}
+	}
+
+	// the camera is inside a huge cube, so its edges project far outside of the image
+	huge := csg.NewCube(&csg.CubeOptions{Size: &csg.Vector{X: 1e9, Y: 1e9, Z: 1e9}})
+	camera := NewCamera(PERSPECTIVE, cube.BoundingBox(), &csg.Vector{X: 1, Y: 1, Z: 1}, nil)
+	img = Render(huge, &Options{Width: 32, Height: 32, Camera: camera, Wireframe: color.Black})
+	if c := img.NRGBAAt(16, 16); c.A != 255 {
+		t.Errorf("Expected the inside of the huge cube to cover the image got %v", c)
+	}
+
+	var out bytes.Buffer
+	wire := color.NRGBA{0, 255, 0, 255}
+	if err := WritePNG(&out, cube, &Options{Width: 64, Height: 64, Wireframe: wire, Supersample: 2}); err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := png.Decode(&out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	green := 0
+	for y := 0; y < 64; y++ {
+		for x := 0; x < 64; x++ {
+			if r, g, b, _ := decoded.At(x, y).RGBA(); g > 2*r && g > 2*b {
+				green++
+			}
+		}
+	}
+	if green == 0 {
+		t.Errorf("Expected the wireframe to be drawn")
+	}
+}
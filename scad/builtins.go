@@ -0,0 +1,710 @@
+package scad
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/celer/csg/csg"
+	"github.com/celer/csg/qhull"
+)
+
+// builtinModule instantiates a builtin module, children evaluates the children of the instantiation
+type builtinModule func(e *evaluator, s *scope, a *arguments, children func() ([]*csg.CSG, error)) (*csg.CSG, error)
+
+// builtinModules are registered in init as they refer back to the evaluator
+var builtinModules map[string]builtinModule
+
+func init() {
+	builtinModules = map[string]builtinModule{
+		"cube":         cube,
+		"sphere":       sphere,
+		"cylinder":     cylinder,
+		"polyhedron":   polyhedron,
+		"translate":    transform(translate),
+		"rotate":       transform(rotate),
+		"scale":        transform(scale),
+		"mirror":       transform(mirror),
+		"multmatrix":   transform(multmatrix),
+		"color":        colorModule,
+		"union":        group,
+		"group":        group,
+		"render":       group,
+		"difference":   difference,
+		"intersection": intersection,
+		"hull":         hull,
+		"children":     childrenModule,
+		"echo":         ignore,
+	}
+}
+
+// number returns the named or positional argument as a number, or the default if it isn't given
+func (e *evaluator) number(a *arguments, name string, index int, def float64) (float64, error) {
+	v, ok := a.get(name, index)
+	if !ok || v == nil {
+		return def, nil
+	}
+	n, ok := v.(float64)
+	if !ok {
+		return 0, e.errorf(a.at, "%s must be a number, not %s", name, describe(v))
+	}
+	return n, nil
+}
+
+// vector converts a vector of up to 3 numbers to a Vector, missing components are 0
+func vector(v value) (*csg.Vector, bool) {
+	elements, ok := v.([]value)
+	if !ok || len(elements) > 3 {
+		return nil, false
+	}
+	var xyz [3]float64
+	for i, element := range elements {
+		if xyz[i], ok = element.(float64); !ok {
+			return nil, false
+		}
+	}
+	return &csg.Vector{X: xyz[0], Y: xyz[1], Z: xyz[2]}, true
+}
+
+// vectorArgument returns the argument as a Vector, a number is used for all three components if
+// scalar is true
+func (e *evaluator) vectorArgument(a *arguments, name string, index int, scalar bool) (*csg.Vector, bool, error) {
+	v, ok := a.get(name, index)
+	if !ok || v == nil {
+		return nil, false, nil
+	}
+	if n, ok := v.(float64); ok && scalar {
+		return &csg.Vector{X: n, Y: n, Z: n}, true, nil
+	}
+	vec, ok := vector(v)
+	if !ok {
+		return nil, false, e.errorf(a.at, "%s must be a vector of numbers, not %s", name, format(v))
+	}
+	return vec, true, nil
+}
+
+// maxFragments is the maximum number of segments in a circle
+const maxFragments = 10000
+
+// maxPolygons is the maximum number of polygons in a primitive
+const maxPolygons = 1000000
+
+// fragments returns the number of segments in a circle of the radius, following OpenSCAD's use of
+// $fn, $fa and $fs
+func (e *evaluator) fragments(s *scope, a *arguments, r float64) (int, error) {
+	special := func(name string, def float64) float64 {
+		if v, ok := s.lookup(name); ok {
+			if n, ok := v.(float64); ok {
+				return n
+			}
+		}
+		return def
+	}
+	// like OpenSCAD $fa and $fs are no smaller than 0.01
+	fn, fa, fs := special("$fn", 0), math.Max(special("$fa", 12), 0.01), math.Max(special("$fs", 2), 0.01)
+	if r < 1e-6 {
+		return 3, nil
+	}
+	n := math.Ceil(math.Max(math.Min(360/fa, r*2*math.Pi/fs), 5))
+	if fn > 0 {
+		n = math.Max(math.Floor(fn), 3)
+	}
+	if !(n <= maxFragments) {
+		return 0, e.errorf(a.at, "circles have more than %d fragments", maxFragments)
+	}
+	return int(n), nil
+}
+
+// polygon returns a polygon through the points, with each vertex given the normal of the polygon
+func polygon(points []*csg.Vector) *csg.Polygon {
+	vertices := make([]*csg.Vertex, len(points))
+	for i, p := range points {
+		vertices[i] = &csg.Vertex{Position: p}
+	}
+	p := csg.NewPolygonFromVertices(vertices)
+	for _, v := range vertices {
+		v.Normal = p.Plane.Normal.Clone()
+	}
+	return p
+}
+
+func cube(e *evaluator, s *scope, a *arguments, children func() ([]*csg.CSG, error)) (*csg.CSG, error) {
+	size, ok, err := e.vectorArgument(a, "size", 0, true)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		size = &csg.Vector{X: 1, Y: 1, Z: 1}
+	}
+	center, _ := a.get("center", 1)
+	if size.X <= 0 || size.Y <= 0 || size.Z <= 0 {
+		return nil, nil
+	}
+	c := &csg.Vector{}
+	if !truthy(center) {
+		c = size.DividedBy(2)
+	}
+	return csg.NewCube(&csg.CubeOptions{Center: c, Size: size}), nil
+}
+
+// radius returns the radius from the r or d argument
+func (e *evaluator) radius(a *arguments, r string, d string, index int, def float64) (float64, error) {
+	if _, ok := a.named[d]; ok {
+		diameter, err := e.number(a, d, -1, 2*def)
+		return diameter / 2, err
+	}
+	return e.number(a, r, index, def)
+}
+
+func sphere(e *evaluator, s *scope, a *arguments, children func() ([]*csg.CSG, error)) (*csg.CSG, error) {
+	r, err := e.radius(a, "r", "d", 0, 1)
+	if err != nil || r <= 0 {
+		return nil, err
+	}
+	n, err := e.fragments(s, a, r)
+	if err != nil {
+		return nil, err
+	}
+	stacks := (n + 1) / 2
+	if n*stacks > maxPolygons {
+		return nil, e.errorf(a.at, "spheres have more than %d polygons", maxPolygons)
+	}
+	// csg spheres have their poles on the Y axis, OpenSCAD spheres have them on the Z axis
+	c := csg.NewSphere(&csg.SphereOptions{Radius: r, Slices: n, Stacks: stacks})
+	return c.Rotate(&csg.Vector{X: 1}, 90), nil
+}
+
+func cylinder(e *evaluator, s *scope, a *arguments, children func() ([]*csg.CSG, error)) (*csg.CSG, error) {
+	h, err := e.number(a, "h", 0, 1)
+	if err != nil {
+		return nil, err
+	}
+	r, err := e.radius(a, "r", "d", -1, 1)
+	if err != nil {
+		return nil, err
+	}
+	r1, err := e.radius(a, "r1", "d1", 1, r)
+	if err != nil {
+		return nil, err
+	}
+	r2, err := e.radius(a, "r2", "d2", 2, r)
+	if err != nil {
+		return nil, err
+	}
+	center, _ := a.get("center", -1)
+	if h <= 0 || r1 < 0 || r2 < 0 || (r1 == 0 && r2 == 0) {
+		return nil, nil
+	}
+
+	z0, z1 := 0.0, h
+	if truthy(center) {
+		z0, z1 = -h/2, h/2
+	}
+	n, err := e.fragments(s, a, math.Max(r1, r2))
+	if err != nil {
+		return nil, err
+	}
+	ring := func(r, z float64) []*csg.Vector {
+		points := make([]*csg.Vector, n)
+		for i := range points {
+			phi := 2 * math.Pi * float64(i) / float64(n)
+			points[i] = &csg.Vector{X: r * math.Cos(phi), Y: r * math.Sin(phi), Z: z}
+		}
+		return points
+	}
+	bottom, top := ring(r1, z0), ring(r2, z1)
+
+	polygons := make([]*csg.Polygon, 0, n+2)
+	if r1 > 0 {
+		cap := make([]*csg.Vector, n)
+		for i := range cap {
+			cap[i] = bottom[n-1-i]
+		}
+		polygons = append(polygons, polygon(cap))
+	}
+	if r2 > 0 {
+		polygons = append(polygons, polygon(top))
+	}
+	for i := 0; i < n; i++ {
+		j := (i + 1) % n
+		switch {
+		case r1 == 0:
+			polygons = append(polygons, polygon([]*csg.Vector{bottom[i], top[j], top[i]}))
+		case r2 == 0:
+			polygons = append(polygons, polygon([]*csg.Vector{bottom[i], bottom[j], top[i]}))
+		default:
+			polygons = append(polygons, polygon([]*csg.Vector{bottom[i], bottom[j], top[j], top[i]}))
+		}
+	}
+	return csg.NewCSGFromPolygons(polygons), nil
+}
+
+func polyhedron(e *evaluator, s *scope, a *arguments, children func() ([]*csg.CSG, error)) (*csg.CSG, error) {
+	p, _ := a.get("points", 0)
+	f, ok := a.get("faces", 1)
+	if !ok {
+		f, _ = a.get("triangles", -1)
+	}
+	pointValues, ok := p.([]value)
+	if !ok {
+		return nil, e.errorf(a.at, "points must be a vector of points, not %s", describe(p))
+	}
+	faceValues, ok := f.([]value)
+	if !ok {
+		return nil, e.errorf(a.at, "faces must be a vector of faces, not %s", describe(f))
+	}
+	points := make([]*csg.Vector, len(pointValues))
+	for i, pv := range pointValues {
+		if points[i], ok = vector(pv); !ok {
+			return nil, e.errorf(a.at, "point %d must be a vector of 3 numbers, not %s", i, format(pv))
+		}
+	}
+	polygons := make([]*csg.Polygon, 0, len(faceValues))
+	for i, fv := range faceValues {
+		indices, ok := fv.([]value)
+		if !ok || len(indices) < 3 {
+			return nil, e.errorf(a.at, "face %d must be a vector of at least 3 point indices, not %s", i, format(fv))
+		}
+		// OpenSCAD faces are clockwise when viewed from outside
+		face := make([]*csg.Vector, len(indices))
+		for j, iv := range indices {
+			index, ok := iv.(float64)
+			if !ok || index != math.Trunc(index) || index < 0 || index >= float64(len(points)) {
+				return nil, e.errorf(a.at, "face %d has an invalid point index %s", i, format(iv))
+			}
+			face[len(indices)-1-j] = points[int(index)].Clone()
+		}
+		polygons = append(polygons, polygon(face))
+	}
+	return csg.NewCSGFromPolygons(polygons), nil
+}
+
+// transform returns a module which transforms the union of its children by the matrix returned by m
+func transform(m func(e *evaluator, a *arguments) (*csg.Matrix, error)) builtinModule {
+	return func(e *evaluator, s *scope, a *arguments, children func() ([]*csg.CSG, error)) (*csg.CSG, error) {
+		matrix, err := m(e, a)
+		if err != nil {
+			return nil, err
+		}
+		c, err := group(e, s, a, children)
+		if c == nil || err != nil {
+			return nil, err
+		}
+		return c.Transform(matrix), nil
+	}
+}
+
+func translate(e *evaluator, a *arguments) (*csg.Matrix, error) {
+	v, ok, err := e.vectorArgument(a, "v", 0, false)
+	if !ok || err != nil {
+		return csg.NewIdentityMatrix(), err
+	}
+	return csg.NewTranslationMatrix(v), nil
+}
+
+func rotate(e *evaluator, a *arguments) (*csg.Matrix, error) {
+	angle, ok := a.get("a", 0)
+	if !ok || angle == nil {
+		return csg.NewIdentityMatrix(), nil
+	}
+	if degrees, ok := angle.(float64); ok {
+		axis, ok, err := e.vectorArgument(a, "v", 1, false)
+		if err != nil {
+			return nil, err
+		}
+		if !ok || axis.Length() == 0 {
+			axis = &csg.Vector{Z: 1}
+		}
+		return csg.NewRotationMatrix(axis, degrees), nil
+	}
+	angles, ok := vector(angle)
+	if !ok {
+		return nil, e.errorf(a.at, "a must be a number or a vector of numbers, not %s", format(angle))
+	}
+	// rotations are applied around X, then Y, then Z
+	x := csg.NewRotationMatrix(&csg.Vector{X: 1}, angles.X)
+	y := csg.NewRotationMatrix(&csg.Vector{Y: 1}, angles.Y)
+	z := csg.NewRotationMatrix(&csg.Vector{Z: 1}, angles.Z)
+	return z.Multiply(y).Multiply(x), nil
+}
+
+func scale(e *evaluator, a *arguments) (*csg.Matrix, error) {
+	v, ok, err := e.vectorArgument(a, "v", 0, true)
+	if !ok || err != nil {
+		return csg.NewIdentityMatrix(), err
+	}
+	// missing components of the scale don't change that axis
+	if elements, ok := a.get("v", 0); ok {
+		if elements, ok := elements.([]value); ok && len(elements) < 3 {
+			v.Z = 1
+			if len(elements) < 2 {
+				v.Y = 1
+			}
+		}
+	}
+	return csg.NewScalingMatrix(v), nil
+}
+
+func mirror(e *evaluator, a *arguments) (*csg.Matrix, error) {
+	n, ok, err := e.vectorArgument(a, "v", 0, false)
+	if !ok || err != nil || n.Length() == 0 {
+		return csg.NewIdentityMatrix(), err
+	}
+	n = n.Unit()
+	return &csg.Matrix{
+		1 - 2*n.X*n.X, -2 * n.X * n.Y, -2 * n.X * n.Z, 0,
+		-2 * n.Y * n.X, 1 - 2*n.Y*n.Y, -2 * n.Y * n.Z, 0,
+		-2 * n.Z * n.X, -2 * n.Z * n.Y, 1 - 2*n.Z*n.Z, 0,
+		0, 0, 0, 1,
+	}, nil
+}
+
+func multmatrix(e *evaluator, a *arguments) (*csg.Matrix, error) {
+	v, ok := a.get("m", 0)
+	if !ok {
+		return csg.NewIdentityMatrix(), nil
+	}
+	m := csg.NewIdentityMatrix()
+	rows, ok := v.([]value)
+	if !ok || len(rows) < 3 || len(rows) > 4 {
+		return nil, e.errorf(a.at, "m must be a 3x4 or 4x4 matrix, not %s", format(v))
+	}
+	for i, row := range rows {
+		cols, ok := row.([]value)
+		if !ok || len(cols) > 4 {
+			return nil, e.errorf(a.at, "m must be a 3x4 or 4x4 matrix, not %s", format(v))
+		}
+		for j, col := range cols {
+			n, ok := col.(float64)
+			if !ok {
+				return nil, e.errorf(a.at, "m must be a matrix of numbers, not %s", format(v))
+			}
+			m[i*4+j] = n
+		}
+	}
+	return m, nil
+}
+
+// namedColors are the colors which may be given by name to color
+var namedColors = map[string]color.NRGBA{
+	"black": {0, 0, 0, 255}, "white": {255, 255, 255, 255}, "red": {255, 0, 0, 255},
+	"green": {0, 128, 0, 255}, "lime": {0, 255, 0, 255}, "blue": {0, 0, 255, 255},
+	"yellow": {255, 255, 0, 255}, "cyan": {0, 255, 255, 255}, "magenta": {255, 0, 255, 255},
+	"gray": {128, 128, 128, 255}, "grey": {128, 128, 128, 255}, "silver": {192, 192, 192, 255},
+	"orange": {255, 165, 0, 255}, "purple": {128, 0, 128, 255}, "brown": {165, 42, 42, 255},
+	"pink": {255, 192, 203, 255}, "navy": {0, 0, 128, 255}, "gold": {255, 215, 0, 255},
+}
+
+// colorModule sets the color of its children, colors are given as a vector of components
+// between 0 and 1, a hex string or a basic color name. Unknown color names leave the children
+// uncolored.
+func colorModule(e *evaluator, s *scope, a *arguments, children func() ([]*csg.CSG, error)) (*csg.CSG, error) {
+	c, err := group(e, s, a, children)
+	if c == nil || err != nil {
+		return nil, err
+	}
+	v, _ := a.get("c", 0)
+	alpha, err := e.number(a, "alpha", 1, -1)
+	if err != nil {
+		return nil, err
+	}
+
+	var rgba color.NRGBA
+	switch v := v.(type) {
+	case []value:
+		components := []float64{0, 0, 0, 1}
+		for i, component := range v {
+			n, ok := component.(float64)
+			if !ok || i > 3 {
+				return nil, e.errorf(a.at, "c must be a vector of up to 4 numbers, not %s", format(v))
+			}
+			components[i] = n
+		}
+		channel := func(f float64) uint8 { return uint8(math.Round(math.Max(0, math.Min(1, f)) * 255)) }
+		rgba = color.NRGBA{channel(components[0]), channel(components[1]), channel(components[2]), channel(components[3])}
+	case string:
+		var ok bool
+		if rgba, ok = parseColor(v); !ok {
+			return c, nil
+		}
+	default:
+		return c, nil
+	}
+	if alpha >= 0 {
+		rgba.A = uint8(math.Round(math.Max(0, math.Min(1, alpha)) * 255))
+	}
+	c = c.Clone()
+	c.SetShared(&csg.Shared{Color: rgba})
+	return c, nil
+}
+
+// parseColor parses a hex color such as #f80, #ff8800 or #ff8800ff, or a named color
+func parseColor(s string) (color.NRGBA, bool) {
+	if c, ok := namedColors[strings.ToLower(s)]; ok {
+		return c, true
+	}
+	if !strings.HasPrefix(s, "#") {
+		return color.NRGBA{}, false
+	}
+	hex := s[1:]
+	if len(hex) == 3 || len(hex) == 4 {
+		expanded := make([]byte, 0, 8)
+		for i := range hex {
+			expanded = append(expanded, hex[i], hex[i])
+		}
+		hex = string(expanded)
+	}
+	if len(hex) == 6 {
+		hex += "ff"
+	}
+	if len(hex) != 8 {
+		return color.NRGBA{}, false
+	}
+	n, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return color.NRGBA{}, false
+	}
+	return color.NRGBA{uint8(n >> 24), uint8(n >> 16), uint8(n >> 8), uint8(n)}, true
+}
+
+func group(e *evaluator, s *scope, a *arguments, children func() ([]*csg.CSG, error)) (*csg.CSG, error) {
+	objects, err := children()
+	if err != nil {
+		return nil, err
+	}
+	return e.union(a.at, objects)
+}
+
+func difference(e *evaluator, s *scope, a *arguments, children func() ([]*csg.CSG, error)) (*csg.CSG, error) {
+	objects, err := children()
+	if err != nil || len(objects) == 0 {
+		return nil, err
+	}
+	if len(objects) == 1 {
+		return objects[0], nil
+	}
+	c, err := e.options.SubtractAll(e.ctx, objects[0], objects[1:])
+	return c, e.wrap(a.at, err)
+}
+
+func intersection(e *evaluator, s *scope, a *arguments, children func() ([]*csg.CSG, error)) (*csg.CSG, error) {
+	objects, err := children()
+	if err != nil || len(objects) == 0 {
+		return nil, err
+	}
+	if len(objects) == 1 {
+		return objects[0], nil
+	}
+	c, err := e.options.IntersectAll(e.ctx, objects)
+	return c, e.wrap(a.at, err)
+}
+
+func hull(e *evaluator, s *scope, a *arguments, children func() ([]*csg.CSG, error)) (*csg.CSG, error) {
+	objects, err := children()
+	if err != nil || len(objects) == 0 {
+		return nil, err
+	}
+	h := &qhull.Hull{}
+	if err := h.BuildFromCSG(objects); err != nil {
+		return nil, e.wrap(a.at, err)
+	}
+	return h.ToCSG(), nil
+}
+
+func childrenModule(e *evaluator, s *scope, a *arguments, children func() ([]*csg.CSG, error)) (*csg.CSG, error) {
+	var indices []int
+	if v, ok := a.get("index", 0); ok {
+		var selected []value
+		switch v := v.(type) {
+		case float64:
+			selected = []value{v}
+		case []value:
+			selected = v
+		case *rangeValue:
+			var err error
+			if selected, err = v.values(); err != nil {
+				return nil, e.errorf(a.at, "%v", err)
+			}
+		default:
+			return nil, e.errorf(a.at, "index must be a number, vector or range, not %s", describe(v))
+		}
+		indices = make([]int, len(selected))
+		for i, index := range selected {
+			n, ok := index.(float64)
+			if !ok {
+				return nil, e.errorf(a.at, "index must be a number, not %s", describe(index))
+			}
+			indices[i] = int(n)
+		}
+	}
+	objects, err := e.children(s, a.at, indices)
+	if err != nil {
+		return nil, err
+	}
+	return e.union(a.at, objects)
+}
+
+func ignore(e *evaluator, s *scope, a *arguments, children func() ([]*csg.CSG, error)) (*csg.CSG, error) {
+	return nil, nil
+}
+
+// builtinFunction is a builtin function, errors are given the position of the call
+type builtinFunction func(a *arguments) (value, error)
+
+func numbers(a *arguments, count int) ([]float64, error) {
+	if len(a.positional) != count {
+		return nil, fmt.Errorf("expected %d arguments, got %d", count, len(a.positional))
+	}
+	n := make([]float64, count)
+	for i, v := range a.positional {
+		var ok bool
+		if n[i], ok = v.(float64); !ok {
+			return nil, fmt.Errorf("expected a number, not %s", describe(v))
+		}
+	}
+	return n, nil
+}
+
+// math1 returns a builtin function of one number
+func math1(f func(float64) float64) builtinFunction {
+	return func(a *arguments) (value, error) {
+		n, err := numbers(a, 1)
+		if err != nil {
+			return nil, err
+		}
+		return f(n[0]), nil
+	}
+}
+
+const degrees = 180 / math.Pi
+
+// reduce returns a builtin function which reduces its arguments, or the elements of a single vector argument
+func reduce(f func(a, b float64) float64) builtinFunction {
+	return func(a *arguments) (value, error) {
+		values := a.positional
+		if len(values) == 1 {
+			if v, ok := values[0].([]value); ok {
+				values = v
+			}
+		}
+		if len(values) == 0 {
+			return nil, nil
+		}
+		result := 0.0
+		for i, v := range values {
+			n, ok := v.(float64)
+			if !ok {
+				return nil, fmt.Errorf("expected a number, not %s", describe(v))
+			}
+			if i == 0 {
+				result = n
+			} else {
+				result = f(result, n)
+			}
+		}
+		return result, nil
+	}
+}
+
+var builtinFunctions = map[string]builtinFunction{
+	"sin":   math1(func(x float64) float64 { return math.Sin(x / degrees) }),
+	"cos":   math1(func(x float64) float64 { return math.Cos(x / degrees) }),
+	"tan":   math1(func(x float64) float64 { return math.Tan(x / degrees) }),
+	"asin":  math1(func(x float64) float64 { return math.Asin(x) * degrees }),
+	"acos":  math1(func(x float64) float64 { return math.Acos(x) * degrees }),
+	"atan":  math1(func(x float64) float64 { return math.Atan(x) * degrees }),
+	"abs":   math1(math.Abs),
+	"sqrt":  math1(math.Sqrt),
+	"exp":   math1(math.Exp),
+	"ln":    math1(math.Log),
+	"log":   math1(math.Log10),
+	"floor": math1(math.Floor),
+	"ceil":  math1(math.Ceil),
+	"round": math1(math.Round),
+	"sign": math1(func(x float64) float64 {
+		if x > 0 {
+			return 1
+		} else if x < 0 {
+			return -1
+		}
+		return 0
+	}),
+	"atan2": func(a *arguments) (value, error) {
+		n, err := numbers(a, 2)
+		if err != nil {
+			return nil, err
+		}
+		return math.Atan2(n[0], n[1]) * degrees, nil
+	},
+	"pow": func(a *arguments) (value, error) {
+		n, err := numbers(a, 2)
+		if err != nil {
+			return nil, err
+		}
+		return math.Pow(n[0], n[1]), nil
+	},
+	"min": reduce(math.Min),
+	"max": reduce(math.Max),
+	"len": func(a *arguments) (value, error) {
+		if len(a.positional) != 1 {
+			return nil, fmt.Errorf("expected 1 argument, got %d", len(a.positional))
+		}
+		switch v := a.positional[0].(type) {
+		case []value:
+			return float64(len(v)), nil
+		case string:
+			return float64(len([]rune(v))), nil
+		}
+		return nil, nil
+	},
+	"norm": func(a *arguments) (value, error) {
+		if len(a.positional) != 1 {
+			return nil, fmt.Errorf("expected 1 argument, got %d", len(a.positional))
+		}
+		v, ok := a.positional[0].([]value)
+		if !ok {
+			return nil, fmt.Errorf("expected a vector, not %s", describe(a.positional[0]))
+		}
+		sum := 0.0
+		for _, element := range v {
+			n, ok := element.(float64)
+			if !ok {
+				return nil, fmt.Errorf("expected a vector of numbers")
+			}
+			sum += n * n
+		}
+		return math.Sqrt(sum), nil
+	},
+	"cross": func(a *arguments) (value, error) {
+		if len(a.positional) != 2 {
+			return nil, fmt.Errorf("expected 2 arguments, got %d", len(a.positional))
+		}
+		u, uok := vector(a.positional[0])
+		v, vok := vector(a.positional[1])
+		if !uok || !vok {
+			return nil, fmt.Errorf("expected two vectors of 3 numbers")
+		}
+		c := u.Cross(v)
+		return []value{c.X, c.Y, c.Z}, nil
+	},
+	"concat": func(a *arguments) (value, error) {
+		result := make([]value, 0)
+		for _, v := range a.positional {
+			if elements, ok := v.([]value); ok {
+				result = append(result, elements...)
+			} else {
+				result = append(result, v)
+			}
+		}
+		return result, nil
+	},
+	"str": func(a *arguments) (value, error) {
+		var s strings.Builder
+		for _, v := range a.positional {
+			s.WriteString(format(v))
+		}
+		return s.String(), nil
+	},
+}
@@ -0,0 +1,783 @@
+package scad
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/celer/csg/csg"
+)
+
+// value is the result of an expression, which is one of float64, string, bool, nil for undef,
+// []value for a vector or *rangeValue
+type value interface{}
+
+type rangeValue struct {
+	start, step, end float64
+}
+
+// maxRange is the maximum number of elements in a range which is iterated
+const maxRange = 1000000
+
+// maxDepth is the maximum depth of nested module and function calls
+const maxDepth = 10000
+
+// values returns the elements of the range
+func (r *rangeValue) values() ([]value, error) {
+	if r.step == 0 || (r.end-r.start)/r.step < 0 {
+		return nil, nil
+	}
+	if n := (r.end - r.start) / r.step; n > maxRange || math.IsNaN(n) {
+		return nil, fmt.Errorf("range has more than %d elements", maxRange)
+	}
+	values := make([]value, 0)
+	for i := 0; ; i++ {
+		v := r.start + float64(i)*r.step
+		if (r.step > 0 && v > r.end) || (r.step < 0 && v < r.end) {
+			return values, nil
+		}
+		values = append(values, v)
+	}
+}
+
+type module struct {
+	def   *moduleDef
+	scope *scope
+}
+
+type function struct {
+	def   *functionDef
+	scope *scope
+}
+
+// scope holds the variables, modules and functions defined by a block of statements
+type scope struct {
+	vars      map[string]value
+	modules   map[string]*module
+	functions map[string]*function
+	// parent is the enclosing scope, where names are looked up if they aren't in this scope
+	parent *scope
+	// dynamic is where special variables such as $fn are looked up if they aren't in this scope,
+	// which is the caller for the scope of a module
+	dynamic *scope
+	// instance is set for the scope of a module, to the instantiation and the scope it was made in
+	instance      *instantiation
+	instanceScope *scope
+}
+
+func newScope(parent, dynamic *scope) *scope {
+	return &scope{
+		vars:      make(map[string]value),
+		modules:   make(map[string]*module),
+		functions: make(map[string]*function),
+		parent:    parent,
+		dynamic:   dynamic,
+	}
+}
+
+func (s *scope) lookup(name string) (value, bool) {
+	for cur := s; cur != nil; {
+		if v, ok := cur.vars[name]; ok {
+			return v, true
+		}
+		if strings.HasPrefix(name, "$") {
+			cur = cur.dynamic
+		} else {
+			cur = cur.parent
+		}
+	}
+	return nil, false
+}
+
+func (s *scope) module(name string) *module {
+	for cur := s; cur != nil; cur = cur.parent {
+		if m, ok := cur.modules[name]; ok {
+			return m
+		}
+	}
+	return nil
+}
+
+func (s *scope) function(name string) *function {
+	for cur := s; cur != nil; cur = cur.parent {
+		if f, ok := cur.functions[name]; ok {
+			return f
+		}
+	}
+	return nil
+}
+
+// evaluator evaluates the statements of a program
+type evaluator struct {
+	ctx     context.Context
+	name    string
+	options *csg.Options
+	depth   int
+}
+
+func (e *evaluator) errorf(at pos, format string, args ...interface{}) *Error {
+	return &Error{Name: e.name, Line: at.line, Column: at.column, Err: fmt.Errorf(format, args...)}
+}
+
+// wrap gives an error from a boolean or hull the position of the module, unless it's a cancellation
+func (e *evaluator) wrap(at pos, err error) error {
+	if _, ok := err.(*Error); ok || err == nil || err == e.ctx.Err() {
+		return err
+	}
+	return &Error{Name: e.name, Line: at.line, Column: at.column, Err: err}
+}
+
+// declare adds the definitions and assignments of the statements to the scope, as they apply to
+// the whole of the scope no matter where they appear within it
+func (e *evaluator) declare(s *scope, body []stmt) error {
+	for _, st := range body {
+		switch st := st.(type) {
+		case *moduleDef:
+			s.modules[st.name] = &module{def: st, scope: s}
+		case *functionDef:
+			s.functions[st.name] = &function{def: st, scope: s}
+		}
+	}
+	for _, st := range body {
+		if a, ok := st.(*assignStmt); ok {
+			v, err := e.eval(s, a.value)
+			if err != nil {
+				return err
+			}
+			s.vars[a.name] = v
+		}
+	}
+	return nil
+}
+
+// body evaluates the statements in the scope, returning the objects they produce
+func (e *evaluator) body(s *scope, body []stmt) ([]*csg.CSG, error) {
+	if err := e.declare(s, body); err != nil {
+		return nil, err
+	}
+	objects := make([]*csg.CSG, 0)
+	for _, st := range body {
+		o, err := e.statement(s, st)
+		if err != nil {
+			return nil, err
+		}
+		if o != nil {
+			objects = append(objects, o)
+		}
+	}
+	return objects, nil
+}
+
+// group evaluates the statements in a new scope, returning the union of the objects they produce
+func (e *evaluator) group(at pos, s *scope, body []stmt) (*csg.CSG, error) {
+	objects, err := e.body(newScope(s, s), body)
+	if err != nil {
+		return nil, err
+	}
+	return e.union(at, objects)
+}
+
+func (e *evaluator) union(at pos, objects []*csg.CSG) (*csg.CSG, error) {
+	switch len(objects) {
+	case 0:
+		return nil, nil
+	case 1:
+		return objects[0], nil
+	}
+	c, err := e.options.UnionAll(e.ctx, objects)
+	return c, e.wrap(at, err)
+}
+
+// statement evaluates a statement, returning the object it produces or nil if it produces nothing
+func (e *evaluator) statement(s *scope, st stmt) (*csg.CSG, error) {
+	if err := e.ctx.Err(); err != nil {
+		return nil, err
+	}
+	switch st := st.(type) {
+	case *blockStmt:
+		return e.group(st.pos, s, st.body)
+	case *ifStmt:
+		cond, err := e.eval(s, st.cond)
+		if err != nil {
+			return nil, err
+		}
+		if truthy(cond) {
+			return e.group(st.pos, s, st.then)
+		}
+		return e.group(st.pos, s, st.otherwise)
+	case *forStmt:
+		objects := make([]*csg.CSG, 0)
+		err := e.loop(s, st.vars, func(ls *scope) error {
+			o, err := e.body(ls, st.body)
+			objects = append(objects, o...)
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+		return e.union(st.pos, objects)
+	case *instantiation:
+		return e.instantiate(s, st)
+	}
+	return nil, nil
+}
+
+// loop calls fn with a new scope for each combination of the values of the loop variables
+func (e *evaluator) loop(s *scope, vars []*arg, fn func(ls *scope) error) error {
+	if len(vars) == 0 {
+		return fn(newScope(s, s))
+	}
+	v, err := e.eval(s, vars[0].value)
+	if err != nil {
+		return err
+	}
+	var elements []value
+	switch v := v.(type) {
+	case []value:
+		elements = v
+	case *rangeValue:
+		if elements, err = v.values(); err != nil {
+			return e.errorf(vars[0].pos, "%v", err)
+		}
+	case nil:
+	default:
+		elements = []value{v}
+	}
+	for _, element := range elements {
+		if err := e.ctx.Err(); err != nil {
+			return err
+		}
+		ls := newScope(s, s)
+		ls.vars[vars[0].name] = element
+		if err := e.loop(ls, vars[1:], fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// arguments are the evaluated arguments of a call
+type arguments struct {
+	at         pos
+	positional []value
+	named      map[string]value
+}
+
+func (e *evaluator) arguments(s *scope, at pos, args []*arg) (*arguments, error) {
+	a := &arguments{at: at, named: make(map[string]value)}
+	for _, arg := range args {
+		v, err := e.eval(s, arg.value)
+		if err != nil {
+			return nil, err
+		}
+		if arg.name == "" {
+			a.positional = append(a.positional, v)
+		} else {
+			a.named[arg.name] = v
+		}
+	}
+	return a, nil
+}
+
+// get returns the argument with the name, or the positional argument at the index if index isn't negative
+func (a *arguments) get(name string, index int) (value, bool) {
+	if v, ok := a.named[name]; ok {
+		return v, true
+	}
+	if index >= 0 && index < len(a.positional) {
+		return a.positional[index], true
+	}
+	return nil, false
+}
+
+// bind binds the arguments to the parameters in a new scope
+func (e *evaluator) bind(params []*param, a *arguments, parent, dynamic *scope) (*scope, error) {
+	s := newScope(parent, dynamic)
+	for name, v := range a.named {
+		if strings.HasPrefix(name, "$") {
+			s.vars[name] = v
+		}
+	}
+	for i, p := range params {
+		if v, ok := a.get(p.name, i); ok {
+			s.vars[p.name] = v
+		} else if p.value != nil {
+			// defaults are evaluated in the scope of the call, so they can refer to earlier parameters
+			v, err := e.eval(s, p.value)
+			if err != nil {
+				return nil, err
+			}
+			s.vars[p.name] = v
+		} else {
+			s.vars[p.name] = nil
+		}
+	}
+	return s, nil
+}
+
+func (e *evaluator) instantiate(s *scope, inst *instantiation) (*csg.CSG, error) {
+	if inst.modifier == "*" || inst.modifier == "%" {
+		// disabled and background objects aren't part of the result
+		return nil, nil
+	}
+	a, err := e.arguments(s, inst.pos, inst.args)
+	if err != nil {
+		return nil, err
+	}
+
+	if m := s.module(inst.name); m != nil {
+		if e.depth++; e.depth > maxDepth {
+			return nil, e.errorf(inst.pos, "modules nested more than %d deep", maxDepth)
+		}
+		defer func() { e.depth-- }()
+		ms, err := e.bind(m.def.params, a, m.scope, s)
+		if err != nil {
+			return nil, err
+		}
+		ms.instance = inst
+		ms.instanceScope = s
+		ms.vars["$children"] = float64(len(geometry(inst.children)))
+		objects, err := e.body(ms, m.def.body)
+		if err != nil {
+			return nil, err
+		}
+		return e.union(inst.pos, objects)
+	}
+
+	b, ok := builtinModules[inst.name]
+	if !ok {
+		return nil, e.errorf(inst.pos, "unknown module %q", inst.name)
+	}
+	// special variables passed as arguments apply to the module and its children
+	bs := newScope(s, s)
+	for name, v := range a.named {
+		if strings.HasPrefix(name, "$") {
+			bs.vars[name] = v
+		}
+	}
+	children := func() ([]*csg.CSG, error) {
+		return e.body(newScope(bs, bs), inst.children)
+	}
+	return b(e, bs, a, children)
+}
+
+// geometry returns the statements which may produce objects
+func geometry(body []stmt) []stmt {
+	g := make([]stmt, 0, len(body))
+	for _, st := range body {
+		switch st.(type) {
+		case *assignStmt, *moduleDef, *functionDef:
+		default:
+			g = append(g, st)
+		}
+	}
+	return g
+}
+
+// children evaluates the children of the module instance the scope belongs to, selected by the
+// indices, or all of them if indices is nil
+func (e *evaluator) children(s *scope, at pos, indices []int) ([]*csg.CSG, error) {
+	ms := s
+	for ms != nil && ms.instance == nil {
+		ms = ms.parent
+	}
+	if ms == nil {
+		return nil, nil
+	}
+	cs := newScope(ms.instanceScope, s)
+	if err := e.declare(cs, ms.instance.children); err != nil {
+		return nil, err
+	}
+	statements := geometry(ms.instance.children)
+	if indices != nil {
+		selected := make([]stmt, 0, len(indices))
+		for _, i := range indices {
+			if i < 0 || i >= len(statements) {
+				return nil, e.errorf(at, "child index %d out of range, there are %d children", i, len(statements))
+			}
+			selected = append(selected, statements[i])
+		}
+		statements = selected
+	}
+	objects := make([]*csg.CSG, 0)
+	for _, st := range statements {
+		o, err := e.statement(cs, st)
+		if err != nil {
+			return nil, err
+		}
+		if o != nil {
+			objects = append(objects, o)
+		}
+	}
+	return objects, nil
+}
+
+func truthy(v value) bool {
+	switch v := v.(type) {
+	case nil:
+		return false
+	case bool:
+		return v
+	case float64:
+		return v != 0
+	case string:
+		return v != ""
+	case []value:
+		return len(v) > 0
+	}
+	return true
+}
+
+func equal(a, b value) bool {
+	switch a := a.(type) {
+	case []value:
+		b, ok := b.([]value)
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for i := range a {
+			if !equal(a[i], b[i]) {
+				return false
+			}
+		}
+		return true
+	case *rangeValue:
+		b, ok := b.(*rangeValue)
+		return ok && *a == *b
+	}
+	return a == b
+}
+
+// describe returns the type of a value for use in errors
+func describe(v value) string {
+	switch v.(type) {
+	case nil:
+		return "undef"
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case []value:
+		return "vector"
+	case *rangeValue:
+		return "range"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+func format(v value) string {
+	switch v := v.(type) {
+	case nil:
+		return "undef"
+	case float64:
+		return fmt.Sprintf("%g", v)
+	case string:
+		return v
+	case []value:
+		s := make([]string, len(v))
+		for i, e := range v {
+			if str, ok := e.(string); ok {
+				s[i] = fmt.Sprintf("%q", str)
+			} else {
+				s[i] = format(e)
+			}
+		}
+		return "[" + strings.Join(s, ", ") + "]"
+	case *rangeValue:
+		return fmt.Sprintf("[%g : %g : %g]", v.start, v.step, v.end)
+	}
+	return fmt.Sprint(v)
+}
+
+func (e *evaluator) eval(s *scope, x expr) (value, error) {
+	switch x := x.(type) {
+	case *numberExpr:
+		return x.value, nil
+	case *stringExpr:
+		return x.value, nil
+	case *boolExpr:
+		return x.value, nil
+	case *undefExpr:
+		return nil, nil
+	case *identExpr:
+		v, ok := s.lookup(x.name)
+		if !ok {
+			return nil, e.errorf(x.pos, "unknown variable %q", x.name)
+		}
+		return v, nil
+	case *vectorExpr:
+		v := make([]value, len(x.elements))
+		for i, element := range x.elements {
+			var err error
+			if v[i], err = e.eval(s, element); err != nil {
+				return nil, err
+			}
+		}
+		return v, nil
+	case *rangeExpr:
+		r := &rangeValue{step: 1}
+		for _, part := range []struct {
+			x expr
+			v *float64
+		}{{x.start, &r.start}, {x.step, &r.step}, {x.end, &r.end}} {
+			if part.x == nil {
+				continue
+			}
+			v, err := e.eval(s, part.x)
+			if err != nil {
+				return nil, err
+			}
+			n, ok := v.(float64)
+			if !ok {
+				return nil, e.errorf(part.x.position(), "range bounds must be numbers, not %s", describe(v))
+			}
+			*part.v = n
+		}
+		return r, nil
+	case *unaryExpr:
+		v, err := e.eval(s, x.x)
+		if err != nil {
+			return nil, err
+		}
+		switch x.op {
+		case "!":
+			return !truthy(v), nil
+		case "+":
+			return v, nil
+		}
+		return e.arithmetic(x.pos, "*", -1.0, v)
+	case *binaryExpr:
+		return e.binary(s, x)
+	case *ternaryExpr:
+		cond, err := e.eval(s, x.cond)
+		if err != nil {
+			return nil, err
+		}
+		if truthy(cond) {
+			return e.eval(s, x.then)
+		}
+		return e.eval(s, x.otherwise)
+	case *indexExpr:
+		v, err := e.eval(s, x.x)
+		if err != nil {
+			return nil, err
+		}
+		index, err := e.eval(s, x.index)
+		if err != nil {
+			return nil, err
+		}
+		i, ok := index.(float64)
+		if !ok {
+			return nil, e.errorf(x.index.position(), "index must be a number, not %s", describe(index))
+		}
+		return element(v, i), nil
+	case *memberExpr:
+		v, err := e.eval(s, x.x)
+		if err != nil {
+			return nil, err
+		}
+		i := strings.Index("xyz", x.name)
+		if len(x.name) != 1 || i < 0 {
+			return nil, e.errorf(x.pos, "unknown member %q, expected x, y or z", x.name)
+		}
+		return element(v, float64(i)), nil
+	case *callExpr:
+		return e.call(s, x)
+	}
+	return nil, e.errorf(x.position(), "unknown expression %T", x)
+}
+
+// element returns the element of a vector or string at the index, or undef if there isn't one
+func element(v value, index float64) value {
+	i := int(math.Floor(index))
+	switch v := v.(type) {
+	case []value:
+		if i >= 0 && i < len(v) {
+			return v[i]
+		}
+	case string:
+		r := []rune(v)
+		if i >= 0 && i < len(r) {
+			return string(r[i])
+		}
+	}
+	return nil
+}
+
+func (e *evaluator) binary(s *scope, x *binaryExpr) (value, error) {
+	a, err := e.eval(s, x.x)
+	if err != nil {
+		return nil, err
+	}
+	// logical operators short circuit
+	switch x.op {
+	case "&&":
+		if !truthy(a) {
+			return false, nil
+		}
+	case "||":
+		if truthy(a) {
+			return true, nil
+		}
+	}
+	b, err := e.eval(s, x.y)
+	if err != nil {
+		return nil, err
+	}
+
+	switch x.op {
+	case "&&", "||":
+		return truthy(b), nil
+	case "==":
+		return equal(a, b), nil
+	case "!=":
+		return !equal(a, b), nil
+	case "<", "<=", ">", ">=":
+		if an, ok := a.(float64); ok {
+			if bn, ok := b.(float64); ok {
+				return compare(x.op, an, bn), nil
+			}
+		}
+		if as, ok := a.(string); ok {
+			if bs, ok := b.(string); ok {
+				return compare(x.op, float64(strings.Compare(as, bs)), 0), nil
+			}
+		}
+		return nil, e.errorf(x.pos, "cannot compare %s with %s", describe(a), describe(b))
+	}
+	return e.arithmetic(x.pos, x.op, a, b)
+}
+
+func compare(op string, a, b float64) bool {
+	switch op {
+	case "<":
+		return a < b
+	case "<=":
+		return a <= b
+	case ">":
+		return a > b
+	}
+	return a >= b
+}
+
+// arithmetic applies an arithmetic operator to numbers and vectors
+func (e *evaluator) arithmetic(at pos, op string, a, b value) (value, error) {
+	an, aNumber := a.(float64)
+	bn, bNumber := b.(float64)
+	av, aVector := a.([]value)
+	bv, bVector := b.([]value)
+	switch {
+	case aNumber && bNumber:
+		switch op {
+		case "+":
+			return an + bn, nil
+		case "-":
+			return an - bn, nil
+		case "*":
+			return an * bn, nil
+		case "/":
+			return an / bn, nil
+		case "%":
+			return math.Mod(an, bn), nil
+		case "^":
+			return math.Pow(an, bn), nil
+		}
+	case aVector && bVector && (op == "+" || op == "-"):
+		if len(av) != len(bv) {
+			return nil, e.errorf(at, "cannot %s vectors of length %d and %d", map[string]string{"+": "add", "-": "subtract"}[op], len(av), len(bv))
+		}
+		r := make([]value, len(av))
+		for i := range av {
+			var err error
+			if r[i], err = e.arithmetic(at, op, av[i], bv[i]); err != nil {
+				return nil, err
+			}
+		}
+		return r, nil
+	case aVector && bVector && op == "*":
+		return e.multiply(at, av, bv)
+	case aVector && bNumber && (op == "*" || op == "/"):
+		r := make([]value, len(av))
+		for i := range av {
+			var err error
+			if r[i], err = e.arithmetic(at, op, av[i], bn); err != nil {
+				return nil, err
+			}
+		}
+		return r, nil
+	case aNumber && bVector && op == "*":
+		return e.arithmetic(at, op, b, a)
+	}
+	return nil, e.errorf(at, "cannot apply %q to %s and %s", op, describe(a), describe(b))
+}
+
+// multiply multiplies vectors as a dot product, or matrices and vectors
+func (e *evaluator) multiply(at pos, a, b []value) (value, error) {
+	_, aMatrix := element(a, 0).([]value)
+	_, bMatrix := element(b, 0).([]value)
+	if !aMatrix && !bMatrix {
+		if len(a) != len(b) {
+			return nil, e.errorf(at, "cannot multiply vectors of length %d and %d", len(a), len(b))
+		}
+		sum := 0.0
+		for i := range a {
+			v, err := e.arithmetic(at, "*", a[i], b[i])
+			if err != nil {
+				return nil, err
+			}
+			n, ok := v.(float64)
+			if !ok {
+				return nil, e.errorf(at, "cannot multiply vectors of %s", describe(v))
+			}
+			sum += n
+		}
+		return sum, nil
+	}
+	if aMatrix && !bMatrix {
+		// matrix times vector
+		r := make([]value, len(a))
+		for i, row := range a {
+			vector, ok := row.([]value)
+			if !ok {
+				return nil, e.errorf(at, "cannot multiply a matrix with row %d a %s", i, describe(row))
+			}
+			v, err := e.multiply(at, vector, b)
+			if err != nil {
+				return nil, err
+			}
+			r[i] = v
+		}
+		return r, nil
+	}
+	return nil, e.errorf(at, "cannot multiply these vectors")
+}
+
+func (e *evaluator) call(s *scope, x *callExpr) (value, error) {
+	a, err := e.arguments(s, x.pos, x.args)
+	if err != nil {
+		return nil, err
+	}
+	if f := s.function(x.name); f != nil {
+		if e.depth++; e.depth > maxDepth {
+			return nil, e.errorf(x.pos, "functions nested more than %d deep", maxDepth)
+		}
+		defer func() { e.depth-- }()
+		fs, err := e.bind(f.def.params, a, f.scope, s)
+		if err != nil {
+			return nil, err
+		}
+		return e.eval(fs, f.def.body)
+	}
+	b, ok := builtinFunctions[x.name]
+	if !ok {
+		return nil, e.errorf(x.pos, "unknown function %q", x.name)
+	}
+	v, err := b(a)
+	if err != nil {
+		return nil, e.errorf(x.pos, "%s: %v", x.name, err)
+	}
+	return v, nil
+}
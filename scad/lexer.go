@@ -0,0 +1,212 @@
+package scad
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Error is a problem at a position in a source file
+type Error struct {
+	// Name of the source file
+	Name string
+	// Line and Column of the problem, starting from 1
+	Line, Column int
+	// Err is the problem
+	Err error
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s:%d:%d: %v", e.Name, e.Line, e.Column, e.Err)
+}
+
+// pos is a position in a source file
+type pos struct {
+	line, column int
+}
+
+type tokenType int
+
+const (
+	tokenEOF tokenType = iota
+	tokenIdent
+	tokenNumber
+	tokenString
+	// tokenPunct is an operator or punctuation, the text of the token says which
+	tokenPunct
+)
+
+type token struct {
+	typ    tokenType
+	text   string
+	number float64
+	pos    pos
+}
+
+func (t token) String() string {
+	switch t.typ {
+	case tokenEOF:
+		return "end of file"
+	case tokenString:
+		return strconv.Quote(t.text)
+	}
+	return fmt.Sprintf("%q", t.text)
+}
+
+// punctuation is every operator and punctuation, with the longer ones first so they are matched first
+var punctuation = []string{
+	"<=", ">=", "==", "!=", "&&", "||",
+	"(", ")", "[", "]", "{", "}", ",", ";", "=", ":", "?", ".",
+	"+", "-", "*", "/", "%", "^", "!", "<", ">", "#",
+}
+
+// lexer splits source into tokens
+type lexer struct {
+	name   string
+	src    string
+	offset int
+	pos    pos
+}
+
+func (l *lexer) errorf(p pos, format string, args ...interface{}) *Error {
+	return &Error{Name: l.name, Line: p.line, Column: p.column, Err: fmt.Errorf(format, args...)}
+}
+
+// advance moves forward n bytes, keeping track of the line and column
+func (l *lexer) advance(n int) {
+	for _, r := range l.src[l.offset : l.offset+n] {
+		if r == '\n' {
+			l.pos.line++
+			l.pos.column = 1
+		} else {
+			l.pos.column++
+		}
+	}
+	l.offset += n
+}
+
+// skip skips whitespace and comments
+func (l *lexer) skip() error {
+	for l.offset < len(l.src) {
+		rest := l.src[l.offset:]
+		switch {
+		case unicode.IsSpace(rune(rest[0])):
+			l.advance(1)
+		case strings.HasPrefix(rest, "//"):
+			end := strings.IndexByte(rest, '\n')
+			if end < 0 {
+				end = len(rest)
+			}
+			l.advance(end)
+		case strings.HasPrefix(rest, "/*"):
+			end := strings.Index(rest[2:], "*/")
+			if end < 0 {
+				return l.errorf(l.pos, "unterminated comment")
+			}
+			l.advance(end + 4)
+		default:
+			return nil
+		}
+	}
+	return nil
+}
+
+// tokenize returns all of the tokens in the source, ending with tokenEOF
+func tokenize(name, src string) ([]token, error) {
+	l := &lexer{name: name, src: src, pos: pos{1, 1}}
+	tokens := make([]token, 0)
+	for {
+		if err := l.skip(); err != nil {
+			return nil, err
+		}
+		start := l.pos
+		if l.offset == len(l.src) {
+			return append(tokens, token{typ: tokenEOF, pos: start}), nil
+		}
+		rest := l.src[l.offset:]
+		c := rest[0]
+
+		switch {
+		case c == '$' || c == '_' || isLetter(c):
+			n := 1
+			for n < len(rest) && (rest[n] == '_' || isLetter(rest[n]) || isDigit(rest[n])) {
+				n++
+			}
+			tokens = append(tokens, token{typ: tokenIdent, text: rest[:n], pos: start})
+			l.advance(n)
+		case isDigit(c) || (c == '.' && len(rest) > 1 && isDigit(rest[1])):
+			n := 0
+			for n < len(rest) && (isDigit(rest[n]) || rest[n] == '.') {
+				n++
+			}
+			if n < len(rest) && (rest[n] == 'e' || rest[n] == 'E') {
+				m := n + 1
+				if m < len(rest) && (rest[m] == '+' || rest[m] == '-') {
+					m++
+				}
+				if m < len(rest) && isDigit(rest[m]) {
+					for m < len(rest) && isDigit(rest[m]) {
+						m++
+					}
+					n = m
+				}
+			}
+			v, err := strconv.ParseFloat(rest[:n], 64)
+			if err != nil {
+				return nil, l.errorf(start, "invalid number %q", rest[:n])
+			}
+			tokens = append(tokens, token{typ: tokenNumber, text: rest[:n], number: v, pos: start})
+			l.advance(n)
+		case c == '"':
+			var s strings.Builder
+			n := 1
+			for ; n < len(rest) && rest[n] != '"'; n++ {
+				if rest[n] == '\n' {
+					break
+				}
+				if rest[n] != '\\' || n+1 >= len(rest) {
+					s.WriteByte(rest[n])
+					continue
+				}
+				n++
+				switch rest[n] {
+				case 'n':
+					s.WriteByte('\n')
+				case 't':
+					s.WriteByte('\t')
+				case 'r':
+					s.WriteByte('\r')
+				default:
+					s.WriteByte(rest[n])
+				}
+			}
+			if n >= len(rest) || rest[n] != '"' {
+				return nil, l.errorf(start, "unterminated string")
+			}
+			tokens = append(tokens, token{typ: tokenString, text: s.String(), pos: start})
+			l.advance(n + 1)
+		default:
+			matched := false
+			for _, p := range punctuation {
+				if strings.HasPrefix(rest, p) {
+					tokens = append(tokens, token{typ: tokenPunct, text: p, pos: start})
+					l.advance(len(p))
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				return nil, l.errorf(start, "unexpected character %q", c)
+			}
+		}
+	}
+}
+
+func isLetter(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
@@ -0,0 +1,636 @@
+package scad
+
+import (
+	"fmt"
+)
+
+// expr is an expression
+type expr interface {
+	position() pos
+}
+
+type numberExpr struct {
+	pos
+	value float64
+}
+
+type stringExpr struct {
+	pos
+	value string
+}
+
+type boolExpr struct {
+	pos
+	value bool
+}
+
+type undefExpr struct {
+	pos
+}
+
+type identExpr struct {
+	pos
+	name string
+}
+
+type vectorExpr struct {
+	pos
+	elements []expr
+}
+
+// rangeExpr is [start:end] or [start:step:end], step is nil if not given
+type rangeExpr struct {
+	pos
+	start, step, end expr
+}
+
+type unaryExpr struct {
+	pos
+	op string
+	x  expr
+}
+
+type binaryExpr struct {
+	pos
+	op   string
+	x, y expr
+}
+
+type ternaryExpr struct {
+	pos
+	cond, then, otherwise expr
+}
+
+type indexExpr struct {
+	pos
+	x, index expr
+}
+
+type memberExpr struct {
+	pos
+	x    expr
+	name string
+}
+
+type callExpr struct {
+	pos
+	name string
+	args []*arg
+}
+
+func (p pos) position() pos {
+	return p
+}
+
+// arg is an argument to a call, name is empty for positional arguments
+type arg struct {
+	pos
+	name  string
+	value expr
+}
+
+// param is a parameter of a module or function, value is the default and may be nil
+type param struct {
+	pos
+	name  string
+	value expr
+}
+
+// stmt is a statement
+type stmt interface {
+	position() pos
+}
+
+type assignStmt struct {
+	pos
+	name  string
+	value expr
+}
+
+type moduleDef struct {
+	pos
+	name   string
+	params []*param
+	body   []stmt
+}
+
+type functionDef struct {
+	pos
+	name   string
+	params []*param
+	body   expr
+}
+
+// instantiation is a call of a module, with the children it applies to
+type instantiation struct {
+	pos
+	name     string
+	args     []*arg
+	children []stmt
+	// modifier is one of "", "#", "%", "*" or "!"
+	modifier string
+}
+
+type blockStmt struct {
+	pos
+	body []stmt
+}
+
+type ifStmt struct {
+	pos
+	cond      expr
+	then      []stmt
+	otherwise []stmt
+}
+
+type forStmt struct {
+	pos
+	vars []*arg
+	body []stmt
+}
+
+// parser builds the statements of a program from its tokens
+type parser struct {
+	name   string
+	tokens []token
+	next   int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.next]
+}
+
+func (p *parser) take() token {
+	t := p.tokens[p.next]
+	if t.typ != tokenEOF {
+		p.next++
+	}
+	return t
+}
+
+func (p *parser) errorf(at pos, format string, args ...interface{}) *Error {
+	return &Error{Name: p.name, Line: at.line, Column: at.column, Err: fmt.Errorf(format, args...)}
+}
+
+// is returns true if the next token is the punctuation
+func (p *parser) is(punct string) bool {
+	t := p.peek()
+	return t.typ == tokenPunct && t.text == punct
+}
+
+// accept takes the next token if it is the punctuation
+func (p *parser) accept(punct string) bool {
+	if p.is(punct) {
+		p.take()
+		return true
+	}
+	return false
+}
+
+func (p *parser) expect(punct string) (token, error) {
+	t := p.take()
+	if t.typ != tokenPunct || t.text != punct {
+		return t, p.errorf(t.pos, "expected %q, found %s", punct, t)
+	}
+	return t, nil
+}
+
+func (p *parser) ident() (token, error) {
+	t := p.take()
+	if t.typ != tokenIdent || keywords[t.text] {
+		return t, p.errorf(t.pos, "expected a name, found %s", t)
+	}
+	return t, nil
+}
+
+var keywords = map[string]bool{
+	"module": true, "function": true, "if": true, "else": true, "for": true,
+	"true": true, "false": true, "undef": true, "include": true, "use": true,
+}
+
+// parse parses the source of a program into statements
+func parse(name, src string) ([]stmt, error) {
+	tokens, err := tokenize(name, src)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{name: name, tokens: tokens}
+	body := make([]stmt, 0)
+	for p.peek().typ != tokenEOF {
+		s, err := p.statement()
+		if err != nil {
+			return nil, err
+		}
+		if s != nil {
+			body = append(body, s)
+		}
+	}
+	return body, nil
+}
+
+// statement parses a statement, returning nil for an empty statement
+func (p *parser) statement() (stmt, error) {
+	t := p.peek()
+	switch {
+	case p.accept(";"):
+		return nil, nil
+	case p.accept("{"):
+		body, err := p.block()
+		if err != nil {
+			return nil, err
+		}
+		return &blockStmt{pos: t.pos, body: body}, nil
+	case t.typ == tokenPunct && (t.text == "#" || t.text == "%" || t.text == "*" || t.text == "!"):
+		p.take()
+		s, err := p.statement()
+		if err != nil {
+			return nil, err
+		}
+		i, ok := s.(*instantiation)
+		if !ok {
+			return nil, p.errorf(t.pos, "modifier %q must be followed by a module", t.text)
+		}
+		i.modifier = t.text
+		return i, nil
+	case t.typ != tokenIdent:
+		return nil, p.errorf(t.pos, "expected a statement, found %s", t)
+	}
+
+	switch t.text {
+	case "module":
+		p.take()
+		name, err := p.ident()
+		if err != nil {
+			return nil, err
+		}
+		params, err := p.params()
+		if err != nil {
+			return nil, err
+		}
+		body, err := p.child()
+		if err != nil {
+			return nil, err
+		}
+		return &moduleDef{pos: t.pos, name: name.text, params: params, body: body}, nil
+	case "function":
+		p.take()
+		name, err := p.ident()
+		if err != nil {
+			return nil, err
+		}
+		params, err := p.params()
+		if err != nil {
+			return nil, err
+		}
+		if _, err := p.expect("="); err != nil {
+			return nil, err
+		}
+		body, err := p.expression()
+		if err != nil {
+			return nil, err
+		}
+		if _, err := p.expect(";"); err != nil {
+			return nil, err
+		}
+		return &functionDef{pos: t.pos, name: name.text, params: params, body: body}, nil
+	case "if":
+		p.take()
+		if _, err := p.expect("("); err != nil {
+			return nil, err
+		}
+		cond, err := p.expression()
+		if err != nil {
+			return nil, err
+		}
+		if _, err := p.expect(")"); err != nil {
+			return nil, err
+		}
+		s := &ifStmt{pos: t.pos, cond: cond}
+		if s.then, err = p.child(); err != nil {
+			return nil, err
+		}
+		if e := p.peek(); e.typ == tokenIdent && e.text == "else" {
+			p.take()
+			if s.otherwise, err = p.child(); err != nil {
+				return nil, err
+			}
+		}
+		return s, nil
+	case "for":
+		p.take()
+		args, err := p.args()
+		if err != nil {
+			return nil, err
+		}
+		for _, a := range args {
+			if a.name == "" {
+				return nil, p.errorf(a.pos, "expected a loop variable")
+			}
+		}
+		body, err := p.child()
+		if err != nil {
+			return nil, err
+		}
+		return &forStmt{pos: t.pos, vars: args, body: body}, nil
+	case "include", "use":
+		return nil, p.errorf(t.pos, "%s is not supported", t.text)
+	}
+
+	p.take()
+	if p.accept("=") {
+		if keywords[t.text] {
+			return nil, p.errorf(t.pos, "cannot assign to %s", t.text)
+		}
+		value, err := p.expression()
+		if err != nil {
+			return nil, err
+		}
+		if _, err := p.expect(";"); err != nil {
+			return nil, err
+		}
+		return &assignStmt{pos: t.pos, name: t.text, value: value}, nil
+	}
+	if keywords[t.text] || !p.is("(") {
+		return nil, p.errorf(t.pos, "expected a statement, found %s", t)
+	}
+	args, err := p.args()
+	if err != nil {
+		return nil, err
+	}
+	children, err := p.child()
+	if err != nil {
+		return nil, err
+	}
+	return &instantiation{pos: t.pos, name: t.text, args: args, children: children}, nil
+}
+
+// block parses statements up to the closing brace
+func (p *parser) block() ([]stmt, error) {
+	body := make([]stmt, 0)
+	for !p.accept("}") {
+		if p.peek().typ == tokenEOF {
+			return nil, p.errorf(p.peek().pos, "expected \"}\", found end of file")
+		}
+		s, err := p.statement()
+		if err != nil {
+			return nil, err
+		}
+		if s != nil {
+			body = append(body, s)
+		}
+	}
+	return body, nil
+}
+
+// child parses the statement or block following a module, returning no statements for ";"
+func (p *parser) child() ([]stmt, error) {
+	if p.accept("{") {
+		return p.block()
+	}
+	s, err := p.statement()
+	if err != nil || s == nil {
+		return nil, err
+	}
+	return []stmt{s}, nil
+}
+
+func (p *parser) params() ([]*param, error) {
+	if _, err := p.expect("("); err != nil {
+		return nil, err
+	}
+	params := make([]*param, 0)
+	for !p.accept(")") {
+		name, err := p.ident()
+		if err != nil {
+			return nil, err
+		}
+		pr := &param{pos: name.pos, name: name.text}
+		if p.accept("=") {
+			if pr.value, err = p.expression(); err != nil {
+				return nil, err
+			}
+		}
+		params = append(params, pr)
+		if !p.is(")") {
+			if _, err := p.expect(","); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return params, nil
+}
+
+func (p *parser) args() ([]*arg, error) {
+	if _, err := p.expect("("); err != nil {
+		return nil, err
+	}
+	args := make([]*arg, 0)
+	for !p.accept(")") {
+		t := p.peek()
+		a := &arg{pos: t.pos}
+		if t.typ == tokenIdent && !keywords[t.text] && p.tokens[p.next+1].typ == tokenPunct && p.tokens[p.next+1].text == "=" {
+			p.take()
+			p.take()
+			a.name = t.text
+		}
+		var err error
+		if a.value, err = p.expression(); err != nil {
+			return nil, err
+		}
+		args = append(args, a)
+		if !p.is(")") {
+			if _, err := p.expect(","); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return args, nil
+}
+
+// binaryPrecedence is the precedence of each binary operator, higher binds tighter
+var binaryPrecedence = map[string]int{
+	"||": 1,
+	"&&": 2,
+	"==": 3, "!=": 3,
+	"<": 4, "<=": 4, ">": 4, ">=": 4,
+	"+": 5, "-": 5,
+	"*": 6, "/": 6, "%": 6,
+	"^": 8,
+}
+
+func (p *parser) expression() (expr, error) {
+	cond, err := p.binary(1)
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); p.accept("?") {
+		then, err := p.expression()
+		if err != nil {
+			return nil, err
+		}
+		if _, err := p.expect(":"); err != nil {
+			return nil, err
+		}
+		otherwise, err := p.expression()
+		if err != nil {
+			return nil, err
+		}
+		return &ternaryExpr{pos: t.pos, cond: cond, then: then, otherwise: otherwise}, nil
+	}
+	return cond, nil
+}
+
+// binary parses binary operators with at least the precedence
+func (p *parser) binary(precedence int) (expr, error) {
+	x, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		t := p.peek()
+		prec, ok := binaryPrecedence[t.text]
+		if t.typ != tokenPunct || !ok || prec < precedence {
+			return x, nil
+		}
+		p.take()
+		next := prec + 1
+		if t.text == "^" {
+			// exponentiation is right associative
+			next = prec
+		}
+		y, err := p.binary(next)
+		if err != nil {
+			return nil, err
+		}
+		x = &binaryExpr{pos: t.pos, op: t.text, x: x, y: y}
+	}
+}
+
+func (p *parser) unary() (expr, error) {
+	t := p.peek()
+	if t.typ == tokenPunct && (t.text == "-" || t.text == "+" || t.text == "!") {
+		p.take()
+		// unary operators bind looser than exponentiation, so -2^2 is -4
+		x, err := p.binary(binaryPrecedence["^"])
+		if err != nil {
+			return nil, err
+		}
+		return &unaryExpr{pos: t.pos, op: t.text, x: x}, nil
+	}
+	return p.postfix()
+}
+
+func (p *parser) postfix() (expr, error) {
+	x, err := p.primary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		t := p.peek()
+		switch {
+		case p.accept("["):
+			index, err := p.expression()
+			if err != nil {
+				return nil, err
+			}
+			if _, err := p.expect("]"); err != nil {
+				return nil, err
+			}
+			x = &indexExpr{pos: t.pos, x: x, index: index}
+		case p.accept("."):
+			name, err := p.ident()
+			if err != nil {
+				return nil, err
+			}
+			x = &memberExpr{pos: t.pos, x: x, name: name.text}
+		default:
+			return x, nil
+		}
+	}
+}
+
+func (p *parser) primary() (expr, error) {
+	t := p.take()
+	switch t.typ {
+	case tokenNumber:
+		return &numberExpr{pos: t.pos, value: t.number}, nil
+	case tokenString:
+		return &stringExpr{pos: t.pos, value: t.text}, nil
+	case tokenIdent:
+		switch t.text {
+		case "true", "false":
+			return &boolExpr{pos: t.pos, value: t.text == "true"}, nil
+		case "undef":
+			return &undefExpr{pos: t.pos}, nil
+		}
+		if keywords[t.text] {
+			return nil, p.errorf(t.pos, "expected an expression, found %s", t)
+		}
+		if p.is("(") {
+			args, err := p.args()
+			if err != nil {
+				return nil, err
+			}
+			return &callExpr{pos: t.pos, name: t.text, args: args}, nil
+		}
+		return &identExpr{pos: t.pos, name: t.text}, nil
+	case tokenPunct:
+		switch t.text {
+		case "(":
+			x, err := p.expression()
+			if err != nil {
+				return nil, err
+			}
+			if _, err := p.expect(")"); err != nil {
+				return nil, err
+			}
+			return x, nil
+		case "[":
+			return p.vector(t)
+		}
+	}
+	return nil, p.errorf(t.pos, "expected an expression, found %s", t)
+}
+
+// vector parses a vector or range, after the opening bracket
+func (p *parser) vector(open token) (expr, error) {
+	if p.accept("]") {
+		return &vectorExpr{pos: open.pos}, nil
+	}
+	first, err := p.expression()
+	if err != nil {
+		return nil, err
+	}
+	if p.accept(":") {
+		r := &rangeExpr{pos: open.pos, start: first}
+		if r.end, err = p.expression(); err != nil {
+			return nil, err
+		}
+		if p.accept(":") {
+			r.step = r.end
+			if r.end, err = p.expression(); err != nil {
+				return nil, err
+			}
+		}
+		if _, err := p.expect("]"); err != nil {
+			return nil, err
+		}
+		return r, nil
+	}
+	v := &vectorExpr{pos: open.pos, elements: []expr{first}}
+	for !p.accept("]") {
+		if _, err := p.expect(","); err != nil {
+			return nil, err
+		}
+		// allow a trailing comma
+		if p.accept("]") {
+			break
+		}
+		e, err := p.expression()
+		if err != nil {
+			return nil, err
+		}
+		v.elements = append(v.elements, e)
+	}
+	return v, nil
+}
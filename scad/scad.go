@@ -0,0 +1,69 @@
+// Package scad interprets a subset of the OpenSCAD language, building the solid it describes with
+// the csg and qhull packages.
+//
+// The supported subset is the 3D primitives cube, sphere, cylinder and polyhedron, the transforms
+// translate, rotate, scale, mirror, multmatrix and color, the booleans union, difference,
+// intersection and hull, user defined modules with children, functions, variables, for loops, if
+// statements and the common math functions. $fn, $fa and $fs control the number of segments in
+// circles as they do in OpenSCAD. 2D shapes, extrusions, include and use aren't supported.
+package scad
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+
+	"github.com/celer/csg/csg"
+)
+
+// Program is a parsed OpenSCAD program
+type Program struct {
+	name string
+	body []stmt
+}
+
+// Parse parses an OpenSCAD program, name is the name of the source used in errors
+func Parse(name string, in io.Reader) (*Program, error) {
+	src, err := ioutil.ReadAll(in)
+	if err != nil {
+		return nil, err
+	}
+	body, err := parse(name, string(src))
+	if err != nil {
+		return nil, err
+	}
+	return &Program{name: name, body: body}, nil
+}
+
+// Evaluate runs the program, returning the union of the objects it produces. Booleans are
+// performed with the options, which may be nil. Errors are an *Error giving the position of the
+// statement or expression at fault, unless the context is cancelled.
+func (p *Program) Evaluate(ctx context.Context, options *csg.Options) (*csg.CSG, error) {
+	e := &evaluator{ctx: ctx, name: p.name, options: options}
+	s := newScope(nil, nil)
+	s.vars["PI"] = 3.141592653589793
+	s.vars["$fn"] = 0.0
+	s.vars["$fa"] = 12.0
+	s.vars["$fs"] = 2.0
+	objects, err := e.body(s, p.body)
+	if err != nil {
+		return nil, err
+	}
+	c, err := e.union(pos{1, 1}, objects)
+	if err != nil {
+		return nil, err
+	}
+	if c == nil {
+		return csg.NewCSGFromPolygons(nil), nil
+	}
+	return c, nil
+}
+
+// Eval parses and runs an OpenSCAD program
+func Eval(ctx context.Context, name string, in io.Reader, options *csg.Options) (*csg.CSG, error) {
+	p, err := Parse(name, in)
+	if err != nil {
+		return nil, err
+	}
+	return p.Evaluate(ctx, options)
+}
@@ -0,0 +1,123 @@
+package scad
+
+import (
+	"bytes"
+	"context"
+	"image/color"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/celer/csg/csg"
+)
+
+func eval(t *testing.T, src string) *csg.CSG {
+	t.Helper()
+	c, err := Eval(context.Background(), "test.scad", strings.NewReader(src), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestEval(t *testing.T) {
+	for _, test := range []struct {
+		src    string
+		volume float64
+	}{
+		{`
+			size = 10;
+			function half(x) = x / 2;
+			module plate(w, h = 1) { cube([w, w, h]); }
+			module holes(n) {
+				for (i = [0 : n - 1]) translate([2 + i * 3, half(size), -1]) cube([1, 1, 3]);
+			}
+			difference() {
+				plate(size);
+				holes(3);
+			}`, 97},
+		{`
+			module twice(d) {
+				children();
+				translate([d, 0, 0]) children(0);
+			}
+			twice(5) cube(2, center = true);`, 16},
+		{`cylinder(h = 2, r = 1, $fn = 4);`, 4},
+		{`$fn = 4; cylinder(h = 2, r1 = 1, r2 = 0, center = true);`, 4.0 / 3},
+		{`mirror([1, 0, 0]) scale(2) cube(1);`, 8},
+		{`hull() { cube(1); translate([0, 0, 2]) cube(1); }`, 3},
+		{`intersection() { cube(2); translate([1, 1, 1]) cube(2); }`, 1},
+		{`polyhedron(points = [[0, 0, 0], [1, 0, 0], [0, 1, 0], [0, 0, 1]], faces = [[0, 1, 2], [0, 3, 1], [0, 2, 3], [1, 3, 2]]);`, 1.0 / 6},
+		{`for (i = [0 : 2 : 4], j = [0, 2]) if (i != 2 && j == 0) translate([i, j, 0]) cube(1); else *cube(5);`, 2},
+		{`x = [1, 2, 3]; cube(x.z > 2 ? [len(x), max(x), pow(x[0] + 1, 2) / 2] : 1);`, 18},
+	} {
+		c := eval(t, test.src)
+		if v := c.Volume(); math.Abs(v-test.volume) > 1e-9 {
+			t.Errorf("Expected a volume of %f got %f for:\n%s", test.volume, v, test.src)
+		}
+	}
+
+	// spheres have their poles on the Z axis
+	b := eval(t, `sphere(d = 4, $fn = 10);`).BoundingBox()
+	if math.Abs(b.Max.Z-2) > 1e-9 || b.Max.Y >= 2 {
+		t.Errorf("Expected the sphere to have poles on the Z axis got %v", b)
+	}
+
+	// $fa and $fs are no smaller than 0.01, so a unit circle has 2 * PI / 0.01 sides
+	if n := len(eval(t, `cylinder(h = 1, r = 1, $fa = 0, $fs = 0);`).ToPolygons()); n != 629+2 {
+		t.Errorf("Expected a cylinder with 629 sides got %d polygons", n)
+	}
+	eval(t, `sphere(0.1, $fa = 0, $fs = 0);`)
+
+	b = eval(t, `rotate([0, 0, 90]) translate([1, 0, 0]) cube(1);`).BoundingBox()
+	if b.Min.Minus(&csg.Vector{X: -1, Y: 1}).Length() > 1e-9 || b.Max.Minus(&csg.Vector{Y: 2, Z: 1}).Length() > 1e-9 {
+		t.Errorf("Expected the cube to be rotated around Z got %v", b)
+	}
+
+	c := eval(t, `color("red") cube(1);`)
+	if s := c.ToPolygons()[0].Shared; s == nil || s.Color != (color.NRGBA{255, 0, 0, 255}) {
+		t.Errorf("Expected the cube to be red got %v", s)
+	}
+}
+
+func TestEvalErrors(t *testing.T) {
+	for _, test := range []struct {
+		src string
+		err string
+	}{
+		{"cube(1);\n  foo();", `test.scad:2:3: unknown module "foo"`},
+		{"x = 1 +;", `test.scad:1:8: expected an expression, found ";"`},
+		{"module m() {\n  cube(size = y);\n}\nm();", `test.scad:2:15: unknown variable "y"`},
+		{"translate([1, 2, 3] cube(1);", `test.scad:1:21: expected ",", found "cube"`},
+		{"cube(1);\n/* unterminated", "test.scad:2:1: unterminated comment"},
+		{"cube(1) {", `test.scad:1:10: expected "}", found end of file`},
+		{`polyhedron(points = [[0, 0, 0]], faces = [[0, 1, 2]]);`, "test.scad:1:1: face 0 has an invalid point index 1"},
+		{"function f(n) = f(n + 1);\ncube(f(0));", "test.scad:1:17: functions nested more than 10000 deep"},
+		{`include <parts.scad>`, "test.scad:1:1: include is not supported"},
+		{`polyhedron(points = [[0, 0, 0], [1, 0, 0], [0, 1, 0]], faces = [[0, 1, 1e300]]);`, "test.scad:1:1: face 0 has an invalid point index 1e+300"},
+		{"cube(1);\nsphere(1, $fn = 1e9);", "test.scad:2:1: circles have more than 10000 fragments"},
+		{"cylinder(h = 1, r = 1e6, $fa = 0, $fs = 0);", "test.scad:1:1: circles have more than 10000 fragments"},
+		{"sphere(1, $fn = 10000);", "test.scad:1:1: spheres have more than 1000000 polygons"},
+		{"x = [[1, 2], 3] * [1, 2];\ncube(x);", "test.scad:1:17: cannot multiply a matrix with row 1 a number"},
+	} {
+		_, err := Eval(context.Background(), "test.scad", strings.NewReader(test.src), nil)
+		if err == nil || err.Error() != test.err {
+			t.Errorf("Expected %q got %v", test.err, err)
+		}
+	}
+}
+
+func TestEvalExportedSCAD(t *testing.T) {
+	hole := csg.NewCylinderOp(&csg.CylinderOptions{Start: &csg.Vector{X: -2}, End: &csg.Vector{X: 2}, Radius: 0.5})
+	op := csg.NewCubeOp(&csg.CubeOptions{Size: &csg.Vector{X: 2, Y: 2, Z: 2}}).
+		Subtract(hole, hole.Rotate(&csg.Vector{Z: 1}, 90), csg.NewSphereOp(&csg.SphereOptions{Center: &csg.Vector{Z: 1}, Radius: 0.5}))
+
+	var out bytes.Buffer
+	if err := op.MarshalToSCAD(&out); err != nil {
+		t.Fatal(err)
+	}
+	expected := csg.NewEvaluator().Evaluate(op).Volume()
+	if v := eval(t, out.String()).Volume(); math.Abs(v-expected) > 1e-6 {
+		t.Errorf("Expected a volume of %f got %f for:\n%s", expected, v, out.String())
+	}
+}
@@ -0,0 +1,389 @@
+// Package scene loads a declarative JSON or YAML description of a solid, made of primitives,
+// transforms, booleans and hulls, and evaluates it to a CSG.
+//
+// Each node of the scene is an object with a "type" and the fields of that type:
+//
+//	{"type": "cube", "center": [0, 0, 0], "size": [1, 1, 1]}
+//	{"type": "sphere", "center": [0, 0, 0], "radius": 1, "slices": 16, "stacks": 8}
+//	{"type": "cylinder", "start": [0, -1, 0], "end": [0, 1, 0], "radius": 1, "slices": 16}
+//	{"type": "union" | "subtract" | "intersect" | "hull", "children": [...]}
+//	{"type": "translate", "offset": [x, y, z], "children": [...]}
+//	{"type": "rotate", "axis": [x, y, z], "degrees": 45, "children": [...]}
+//	{"type": "scale", "factor": [x, y, z], "children": [...]}
+//	{"type": "transform", "matrix": [16 numbers, row major], "children": [...]}
+//
+// The fields of primitives are optional and default to the defaults of the csg package. Subtract
+// removes the rest of its children from the first, and the children of a transform are combined
+// as a union before being transformed. YAML scenes have the same nodes, see DecodeYAML.
+package scene
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/celer/csg/csg"
+	"github.com/celer/csg/qhull"
+)
+
+// Error is a problem with a node of a scene
+type Error struct {
+	// Path is the JSON path of the node or field, such as $.children[1].radius
+	Path string
+	// Err is the problem
+	Err error
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("scene: %s: %v", e.Path, e.Err)
+}
+
+func errorf(path string, format string, args ...interface{}) *Error {
+	return &Error{Path: path, Err: fmt.Errorf(format, args...)}
+}
+
+// Node is a node of a scene
+type Node struct {
+	// Type of the node, such as cube or union
+	Type string
+	// Path of the node within the scene it was decoded from
+	Path string
+
+	// Cube, Sphere and Cylinder are the options of primitive nodes
+	Cube     *csg.CubeOptions
+	Sphere   *csg.SphereOptions
+	Cylinder *csg.CylinderOptions
+
+	// Matrix is the transform of translate, rotate, scale and transform nodes
+	Matrix *csg.Matrix
+
+	// Children are the operands of booleans, hulls and transforms
+	Children []*Node
+}
+
+// nodeFields are the fields allowed for each type of node, other than type
+var nodeFields = map[string][]string{
+	"cube":      {"center", "size"},
+	"sphere":    {"center", "radius", "slices", "stacks"},
+	"cylinder":  {"start", "end", "radius", "slices"},
+	"union":     {"children"},
+	"subtract":  {"children"},
+	"intersect": {"children"},
+	"hull":      {"children"},
+	"translate": {"offset", "children"},
+	"rotate":    {"axis", "degrees", "children"},
+	"scale":     {"factor", "children"},
+	"transform": {"matrix", "children"},
+}
+
+// Decode reads a scene, checking that every node is well formed
+func Decode(in io.Reader) (*Node, error) {
+	data, err := ioutil.ReadAll(in)
+	if err != nil {
+		return nil, err
+	}
+	var raw json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, &Error{Path: "$", Err: err}
+	}
+	return decodeNode("$", raw)
+}
+
+// fields are the fields of a node being decoded
+type fields struct {
+	path   string
+	values map[string]json.RawMessage
+}
+
+func (f *fields) has(name string) bool {
+	_, ok := f.values[name]
+	return ok
+}
+
+func (f *fields) number(name string) (float64, error) {
+	var v float64
+	if err := json.Unmarshal(f.values[name], &v); err != nil {
+		return 0, errorf(f.path+"."+name, "expected a number")
+	}
+	return v, nil
+}
+
+func (f *fields) positive(name string) (float64, error) {
+	v, err := f.number(name)
+	if err == nil && !(v > 0) {
+		return 0, errorf(f.path+"."+name, "must be greater than 0")
+	}
+	return v, err
+}
+
+func (f *fields) integer(name string, min int) (int, error) {
+	v, err := f.number(name)
+	if err != nil {
+		return 0, err
+	}
+	if v != math.Trunc(v) || v < float64(min) || v > math.MaxInt32 {
+		return 0, errorf(f.path+"."+name, "must be a whole number of at least %d", min)
+	}
+	return int(v), nil
+}
+
+func (f *fields) numbers(name string, count int) ([]float64, error) {
+	var values []json.RawMessage
+	if err := json.Unmarshal(f.values[name], &values); err != nil || len(values) != count {
+		return nil, errorf(f.path+"."+name, "expected an array of %d numbers", count)
+	}
+	v := make([]float64, count)
+	for i, value := range values {
+		if err := json.Unmarshal(value, &v[i]); err != nil {
+			return nil, errorf(fmt.Sprintf("%s.%s[%d]", f.path, name, i), "expected a number")
+		}
+	}
+	return v, nil
+}
+
+func (f *fields) vector(name string) (*csg.Vector, error) {
+	v, err := f.numbers(name, 3)
+	if err != nil {
+		return nil, err
+	}
+	return &csg.Vector{X: v[0], Y: v[1], Z: v[2]}, nil
+}
+
+func decodeNode(path string, raw json.RawMessage) (*Node, error) {
+	f := &fields{path: path}
+	if err := json.Unmarshal(raw, &f.values); err != nil || f.values == nil {
+		return nil, errorf(path, "expected an object")
+	}
+	var typ string
+	if !f.has("type") {
+		return nil, errorf(path, "missing type")
+	}
+	if err := json.Unmarshal(f.values["type"], &typ); err != nil {
+		return nil, errorf(path+".type", "expected a string")
+	}
+	allowed, ok := nodeFields[typ]
+	if !ok {
+		return nil, errorf(path+".type", "unknown type %q", typ)
+	}
+	names := make([]string, 0, len(f.values))
+	for name := range f.values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if name != "type" && !contains(allowed, name) {
+			return nil, errorf(path+"."+name, "unknown field for %s, expected one of %s", typ, strings.Join(allowed, ", "))
+		}
+	}
+
+	n := &Node{Type: typ, Path: path}
+	var err error
+	switch typ {
+	case "cube":
+		n.Cube = &csg.CubeOptions{}
+		if f.has("center") {
+			if n.Cube.Center, err = f.vector("center"); err != nil {
+				return nil, err
+			}
+		}
+		if f.has("size") {
+			if n.Cube.Size, err = f.vector("size"); err != nil {
+				return nil, err
+			}
+			if !(n.Cube.Size.X > 0 && n.Cube.Size.Y > 0 && n.Cube.Size.Z > 0) {
+				return nil, errorf(path+".size", "must be greater than 0")
+			}
+		}
+	case "sphere":
+		n.Sphere = &csg.SphereOptions{}
+		if f.has("center") {
+			if n.Sphere.Center, err = f.vector("center"); err != nil {
+				return nil, err
+			}
+		}
+		if f.has("radius") {
+			if n.Sphere.Radius, err = f.positive("radius"); err != nil {
+				return nil, err
+			}
+		}
+		if f.has("slices") {
+			if n.Sphere.Slices, err = f.integer("slices", 3); err != nil {
+				return nil, err
+			}
+		}
+		if f.has("stacks") {
+			if n.Sphere.Stacks, err = f.integer("stacks", 2); err != nil {
+				return nil, err
+			}
+		}
+	case "cylinder":
+		n.Cylinder = &csg.CylinderOptions{Start: &csg.Vector{Y: -1}, End: &csg.Vector{Y: 1}}
+		if f.has("start") {
+			if n.Cylinder.Start, err = f.vector("start"); err != nil {
+				return nil, err
+			}
+		}
+		if f.has("end") {
+			if n.Cylinder.End, err = f.vector("end"); err != nil {
+				return nil, err
+			}
+		}
+		if n.Cylinder.End.Minus(n.Cylinder.Start).Length() == 0 {
+			return nil, errorf(path, "start and end must be different")
+		}
+		if f.has("radius") {
+			if n.Cylinder.Radius, err = f.positive("radius"); err != nil {
+				return nil, err
+			}
+		}
+		if f.has("slices") {
+			if n.Cylinder.Slices, err = f.integer("slices", 3); err != nil {
+				return nil, err
+			}
+		}
+	case "translate":
+		if !f.has("offset") {
+			return nil, errorf(path, "missing offset")
+		}
+		offset, err := f.vector("offset")
+		if err != nil {
+			return nil, err
+		}
+		n.Matrix = csg.NewTranslationMatrix(offset)
+	case "rotate":
+		if !f.has("axis") || !f.has("degrees") {
+			return nil, errorf(path, "missing axis or degrees")
+		}
+		axis, err := f.vector("axis")
+		if err != nil {
+			return nil, err
+		}
+		if axis.Length() == 0 {
+			return nil, errorf(path+".axis", "must not be zero")
+		}
+		degrees, err := f.number("degrees")
+		if err != nil {
+			return nil, err
+		}
+		n.Matrix = csg.NewRotationMatrix(axis, degrees)
+	case "scale":
+		if !f.has("factor") {
+			return nil, errorf(path, "missing factor")
+		}
+		factor, err := f.vector("factor")
+		if err != nil {
+			return nil, err
+		}
+		if factor.X == 0 || factor.Y == 0 || factor.Z == 0 {
+			return nil, errorf(path+".factor", "must not be zero")
+		}
+		n.Matrix = csg.NewScalingMatrix(factor)
+	case "transform":
+		if !f.has("matrix") {
+			return nil, errorf(path, "missing matrix")
+		}
+		values, err := f.numbers("matrix", 16)
+		if err != nil {
+			return nil, err
+		}
+		n.Matrix = &csg.Matrix{}
+		copy(n.Matrix[:], values)
+		if n.Matrix.Determinant() == 0 {
+			return nil, errorf(path+".matrix", "must not be singular")
+		}
+	}
+
+	if contains(allowed, "children") {
+		var children []json.RawMessage
+		if !f.has("children") {
+			return nil, errorf(path, "missing children")
+		}
+		if err := json.Unmarshal(f.values["children"], &children); err != nil {
+			return nil, errorf(path+".children", "expected an array")
+		}
+		if len(children) == 0 {
+			return nil, errorf(path+".children", "must not be empty")
+		}
+		for i, c := range children {
+			child, err := decodeNode(fmt.Sprintf("%s.children[%d]", path, i), c)
+			if err != nil {
+				return nil, err
+			}
+			n.Children = append(n.Children, child)
+		}
+	}
+	return n, nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// Evaluate builds the CSG described by the node, performing booleans and hulls with the options, which may be nil
+func (n *Node) Evaluate(ctx context.Context, options *csg.Options) (*csg.CSG, error) {
+	switch n.Type {
+	case "cube":
+		return csg.NewCube(n.Cube), nil
+	case "sphere":
+		return csg.NewSphere(n.Sphere), nil
+	case "cylinder":
+		return csg.NewCylinder(n.Cylinder), nil
+	}
+
+	children := make([]*csg.CSG, len(n.Children))
+	for i, child := range n.Children {
+		c, err := child.Evaluate(ctx, options)
+		if err != nil {
+			return nil, err
+		}
+		children[i] = c
+	}
+
+	var result *csg.CSG
+	var err error
+	switch n.Type {
+	case "union":
+		result, err = options.UnionAll(ctx, children)
+	case "subtract":
+		result, err = options.SubtractAll(ctx, children[0], children[1:])
+	case "intersect":
+		result, err = options.IntersectAll(ctx, children)
+	case "hull":
+		h := &qhull.Hull{Options: options}
+		if err = h.BuildFromCSG(children); err == nil {
+			result = h.ToCSG()
+		}
+	case "translate", "rotate", "scale", "transform":
+		if result, err = options.UnionAll(ctx, children); err == nil && n.Matrix != nil {
+			result = result.Transform(n.Matrix)
+		}
+	default:
+		return nil, errorf(n.Path, "unknown type %q", n.Type)
+	}
+	if err != nil {
+		if _, ok := err.(*Error); ok || err == ctx.Err() {
+			return nil, err
+		}
+		return nil, &Error{Path: n.Path, Err: err}
+	}
+	return result, nil
+}
+
+// Load reads a scene and evaluates it, performing booleans with the options, which may be nil
+func Load(ctx context.Context, in io.Reader, options *csg.Options) (*csg.CSG, error) {
+	n, err := Decode(in)
+	if err != nil {
+		return nil, err
+	}
+	return n.Evaluate(ctx, options)
+}
@@ -0,0 +1,115 @@
+package scene
+
+import (
+	"context"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestLoad(t *testing.T) {
+	c, err := Load(context.Background(), strings.NewReader(`{
+		"type": "subtract",
+		"children": [
+			{"type": "translate", "offset": [1, 1, 1], "children": [
+				{"type": "cube", "size": [2, 2, 2]}
+			]},
+			{"type": "cube", "center": [2, 2, 2], "size": [1, 1, 1]},
+			{"type": "rotate", "axis": [0, 0, 1], "degrees": 90, "children": [
+				{"type": "cylinder", "start": [1, 0, -1], "end": [1, 0, 0], "radius": 0.1, "slices": 8}
+			]}
+		]
+	}`), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := c.Volume(); math.Abs(v-7.875) > 1e-9 {
+		t.Errorf("Expected a volume of 7.875 got %f", v)
+	}
+
+	hull, err := Load(context.Background(), strings.NewReader(`{"type": "hull", "children": [
+		{"type": "cube"},
+		{"type": "cube", "center": [0, 0, 2]}
+	]}`), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := hull.Volume(); math.Abs(v-3) > 1e-9 {
+		t.Errorf("Expected the hull to have a volume of 3 got %f", v)
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	for _, test := range []struct {
+		scene string
+		err   string
+	}{
+		{`[1]`, "scene: $: expected an object"},
+		{`{"type": "cone"}`, `scene: $.type: unknown type "cone"`},
+		{`{"type": "union", "children": [{"type": "cube"}, {"type": "sphere", "radius": -1}]}`, "scene: $.children[1].radius: must be greater than 0"},
+		{`{"type": "union", "children": [{"type": "cube", "size": [1, "a", 1]}]}`, "scene: $.children[0].size[1]: expected a number"},
+		{`{"type": "subtract", "children": [{"type": "cube", "radius": 1}]}`, "scene: $.children[0].radius: unknown field for cube, expected one of center, size"},
+		{`{"type": "translate", "offset": [1, 2, 3], "children": []}`, "scene: $.children: must not be empty"},
+		{`{"type": "rotate", "axis": [0, 0, 0], "degrees": 1, "children": [{"type": "cube"}]}`, "scene: $.axis: must not be zero"},
+		{`{"type": "sphere", "slices": 2.5}`, "scene: $.slices: must be a whole number of at least 3"},
+		{`{"type": "cylinder", "start": [0, 1, 0]}`, "scene: $: start and end must be different"},
+	} {
+		_, err := Decode(strings.NewReader(test.scene))
+		if err == nil || err.Error() != test.err {
+			t.Errorf("Expected %q for %s got %v", test.err, test.scene, err)
+		}
+	}
+}
+
+func TestLoadYAML(t *testing.T) {
+	c, err := LoadYAML(context.Background(), strings.NewReader(`---
+# the same scene as TestLoad
+type: subtract
+children:
+  - type: translate
+    offset: [1, 1, 1]
+    children:
+      - {type: cube, size: [2, 2, 2]}
+  - type: "cube"  # a quoted string
+    center: [2, 2, 2]
+    size:
+      - 1
+      - 1
+      - 1
+  - type: rotate
+    axis: [0, 0,
+      1]
+    degrees: 90
+    children:
+    - type: cylinder
+      start: [1, 0, -1]
+      end: [1, 0, 0]
+      radius: 0.1
+      slices: 8
+`), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := c.Volume(); math.Abs(v-7.875) > 1e-9 {
+		t.Errorf("Expected a volume of 7.875 got %f", v)
+	}
+
+	for _, test := range []struct {
+		scene string
+		err   string
+	}{
+		{"type: union\nchildren:\n  - type: cube\n  - type: sphere\n    radius: -1", "scene: $.children[1].radius: must be greater than 0"},
+		{"type: cube\nsize: [1, a, 1]", "scene: $.size[1]: expected a number"},
+		{"type: cube\ntype: sphere", "scene: $.type: line 2: duplicate key"},
+		{"type: union\nchildren:\n  - type: cube\n      size: 1", "scene: $.children[0].type: line 4: unexpected indentation"},
+		{"type: cube\nsize: [1, 1, 1", "scene: $.size: line 2: expected \",\" or \"]\""},
+		{"type: cube\ncenter: &c [0, 0, 0]", "scene: $.center: line 2: unsupported YAML \"&\""},
+		{"type: 'cube\n", "scene: $.type: line 1: unterminated string"},
+		{"type: cube\n---\ntype: sphere", "scene: $: line 2: only one document is supported"},
+	} {
+		_, err := DecodeYAML(strings.NewReader(test.scene))
+		if err == nil || err.Error() != test.err {
+			t.Errorf("Expected %q for %q got %v", test.err, test.scene, err)
+		}
+	}
+}
@@ -0,0 +1,480 @@
+package scene
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/celer/csg/csg"
+)
+
+// DecodeYAML reads a scene written in YAML, with the same nodes and fields as a JSON scene:
+//
+//	type: subtract
+//	children:
+//	  - type: cube
+//	    size: [2, 2, 2]
+//	  - {type: sphere, center: [1, 1, 1], radius: 1.2}
+//
+// Block and flow mappings and sequences, quoted and plain scalars and comments are supported,
+// anchors, tags, block scalars and multiple documents are not. Errors give the JSON path of the
+// node at fault, along with the line for problems with the YAML itself.
+func DecodeYAML(in io.Reader) (*Node, error) {
+	data, err := ioutil.ReadAll(in)
+	if err != nil {
+		return nil, err
+	}
+	p, err := newYAMLParser(string(data))
+	if err != nil {
+		return nil, err
+	}
+	v, err := p.document()
+	if err != nil {
+		return nil, err
+	}
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return nil, &Error{Path: "$", Err: err}
+	}
+	return decodeNode("$", raw)
+}
+
+// LoadYAML reads a YAML scene and evaluates it, performing booleans with the options, which may be nil
+func LoadYAML(ctx context.Context, in io.Reader, options *csg.Options) (*csg.CSG, error) {
+	n, err := DecodeYAML(in)
+	if err != nil {
+		return nil, err
+	}
+	return n.Evaluate(ctx, options)
+}
+
+// yamlLine is a line of YAML without its indentation and comment
+type yamlLine struct {
+	number int
+	indent int
+	text   string
+}
+
+// yamlParser parses the subset of YAML used for scenes into the values encoding/json produces
+type yamlParser struct {
+	lines []yamlLine
+	pos   int
+}
+
+func newYAMLParser(src string) (*yamlParser, error) {
+	p := &yamlParser{}
+	for i, l := range strings.Split(strings.Replace(src, "\r\n", "\n", -1), "\n") {
+		text := strings.TrimLeft(l, " ")
+		line := yamlLine{number: i + 1, indent: len(l) - len(text)}
+		if strings.HasPrefix(text, "\t") {
+			return nil, line.errorf("$", "tabs can't be used for indentation")
+		}
+		line.text = strings.TrimRight(stripYAMLComment(text), " \t")
+		if line.text == "" || (len(p.lines) == 0 && line.indent == 0 && line.text == "---") {
+			continue
+		}
+		if line.indent == 0 && (line.text == "---" || line.text == "...") {
+			return nil, line.errorf("$", "only one document is supported")
+		}
+		p.lines = append(p.lines, line)
+	}
+	return p, nil
+}
+
+func (l yamlLine) errorf(path string, format string, args ...interface{}) *Error {
+	return errorf(path, "line %d: %s", l.number, fmt.Sprintf(format, args...))
+}
+
+// unquotedYAML calls visit with the index of each character of the text which isn't quoted, until
+// visit returns false
+func unquotedYAML(text string, visit func(i int) bool) {
+	var quote byte
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		switch {
+		case quote == '"' && c == '\\':
+			i++
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		default:
+			if !visit(i) {
+				return
+			}
+		}
+	}
+}
+
+// stripYAMLComment removes a comment from the end of a line, a # which isn't quoted starts a
+// comment at the start of the line or after a space
+func stripYAMLComment(text string) string {
+	end := len(text)
+	unquotedYAML(text, func(i int) bool {
+		if text[i] == '#' && (i == 0 || text[i-1] == ' ' || text[i-1] == '\t') {
+			end = i
+			return false
+		}
+		return true
+	})
+	return text[:end]
+}
+
+// document parses the whole of the YAML
+func (p *yamlParser) document() (interface{}, error) {
+	if len(p.lines) == 0 {
+		return nil, nil
+	}
+	v, err := p.block("$", p.lines[0].indent)
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.lines) {
+		l := p.lines[p.pos]
+		return nil, l.errorf("$", "unexpected indentation")
+	}
+	return v, nil
+}
+
+// block parses the sequence, mapping or value starting at the current line, which has the indent
+func (p *yamlParser) block(path string, indent int) (interface{}, error) {
+	l := p.lines[p.pos]
+	if l.indent != indent {
+		return nil, l.errorf(path, "unexpected indentation")
+	}
+	if isYAMLItem(l.text) {
+		return p.sequence(path, indent)
+	}
+	if _, _, ok := splitYAMLKey(l.text); ok {
+		return p.mapping(path, indent)
+	}
+	p.pos++
+	return p.inline(path, l)
+}
+
+// nested parses the value of a key or item which is on the following lines, which may be a
+// sequence at the same indent as a key
+func (p *yamlParser) nested(path string, indent int, key bool) (interface{}, error) {
+	if p.pos >= len(p.lines) {
+		return nil, nil
+	}
+	next := p.lines[p.pos]
+	if next.indent > indent || (key && next.indent == indent && isYAMLItem(next.text)) {
+		return p.block(path, next.indent)
+	}
+	return nil, nil
+}
+
+func isYAMLItem(text string) bool {
+	return text == "-" || strings.HasPrefix(text, "- ")
+}
+
+// sequence parses the items of a block sequence with the indent
+func (p *yamlParser) sequence(path string, indent int) ([]interface{}, error) {
+	items := make([]interface{}, 0)
+	for p.pos < len(p.lines) && p.lines[p.pos].indent == indent && isYAMLItem(p.lines[p.pos].text) {
+		l := p.lines[p.pos]
+		itemPath := fmt.Sprintf("%s[%d]", path, len(items))
+		rest := strings.TrimLeft(l.text[1:], " ")
+		var item interface{}
+		var err error
+		if rest == "" {
+			p.pos++
+			item, err = p.nested(itemPath, indent, false)
+		} else {
+			// the rest of the line is parsed as if it started a line of its own, so that a
+			// mapping continues on the following lines at the same indent as its first key
+			p.lines[p.pos] = yamlLine{number: l.number, indent: indent + len(l.text) - len(rest), text: rest}
+			item, err = p.block(itemPath, p.lines[p.pos].indent)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if err := p.dedented(itemPath, indent); err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// mapping parses the keys of a block mapping with the indent
+func (p *yamlParser) mapping(path string, indent int) (map[string]interface{}, error) {
+	m := make(map[string]interface{})
+	for p.pos < len(p.lines) && p.lines[p.pos].indent == indent && !isYAMLItem(p.lines[p.pos].text) {
+		l := p.lines[p.pos]
+		key, rest, ok := splitYAMLKey(l.text)
+		if !ok {
+			return nil, l.errorf(path, "expected a key")
+		}
+		name, err := yamlKey(key)
+		if err != nil {
+			return nil, l.errorf(path, "%v", err)
+		}
+		keyPath := path + "." + name
+		if _, ok := m[name]; ok {
+			return nil, l.errorf(keyPath, "duplicate key")
+		}
+		p.pos++
+		if rest == "" {
+			m[name], err = p.nested(keyPath, indent, true)
+		} else {
+			m[name], err = p.inline(keyPath, yamlLine{number: l.number, indent: l.indent, text: rest})
+		}
+		if err != nil {
+			return nil, err
+		}
+		if err := p.dedented(keyPath, indent); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// dedented checks that the line following a key or item isn't indented further, as it would
+// have been part of its value
+func (p *yamlParser) dedented(path string, indent int) error {
+	if p.pos < len(p.lines) && p.lines[p.pos].indent > indent {
+		return p.lines[p.pos].errorf(path, "unexpected indentation")
+	}
+	return nil
+}
+
+// splitYAMLKey splits a line of a mapping into the key and the value following the colon
+func splitYAMLKey(text string) (key string, value string, ok bool) {
+	depth := 0
+	unquotedYAML(text, func(i int) bool {
+		switch text[i] {
+		case '[', '{':
+			depth++
+		case ']', '}':
+			depth--
+		case ':':
+			if depth == 0 && i > 0 && (i+1 == len(text) || text[i+1] == ' ') {
+				key, value, ok = strings.TrimSpace(text[:i]), strings.TrimSpace(text[i+1:]), true
+				return false
+			}
+		}
+		return true
+	})
+	return key, value, ok
+}
+
+// yamlKey returns the key of a mapping without any quotes
+func yamlKey(key string) (string, error) {
+	if strings.HasPrefix(key, "\"") || strings.HasPrefix(key, "'") {
+		return unquoteYAML(key)
+	}
+	return key, nil
+}
+
+// inline parses a value on a single line, flow collections may continue on the following lines
+// until their brackets are closed
+func (p *yamlParser) inline(path string, l yamlLine) (interface{}, error) {
+	text := l.text
+	if strings.HasPrefix(text, "[") || strings.HasPrefix(text, "{") {
+		for !balancedYAML(text) && p.pos < len(p.lines) {
+			text += " " + p.lines[p.pos].text
+			p.pos++
+		}
+	}
+	f := &yamlFlow{line: l, text: text}
+	v, err := f.value(path)
+	if err != nil {
+		return nil, err
+	}
+	f.space()
+	if f.pos < len(f.text) {
+		return nil, l.errorf(path, "unexpected %q", f.text[f.pos:])
+	}
+	return v, nil
+}
+
+// balancedYAML checks if the brackets of a flow collection have all been closed
+func balancedYAML(text string) bool {
+	depth := 0
+	unquotedYAML(text, func(i int) bool {
+		switch text[i] {
+		case '[', '{':
+			depth++
+		case ']', '}':
+			depth--
+		}
+		return true
+	})
+	return depth <= 0
+}
+
+// yamlFlow parses a value written in the flow style, or a plain or quoted scalar
+type yamlFlow struct {
+	line yamlLine
+	text string
+	pos  int
+}
+
+func (f *yamlFlow) space() {
+	for f.pos < len(f.text) && f.text[f.pos] == ' ' {
+		f.pos++
+	}
+}
+
+func (f *yamlFlow) value(path string) (interface{}, error) {
+	f.space()
+	if f.pos == len(f.text) {
+		return nil, f.line.errorf(path, "expected a value")
+	}
+	switch c := f.text[f.pos]; c {
+	case '[':
+		f.pos++
+		items := make([]interface{}, 0)
+		for {
+			f.space()
+			if f.pos < len(f.text) && f.text[f.pos] == ']' && len(items) == 0 {
+				f.pos++
+				return items, nil
+			}
+			item, err := f.value(fmt.Sprintf("%s[%d]", path, len(items)))
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+			if done, err := f.separator(path, ']'); done || err != nil {
+				return items, err
+			}
+		}
+	case '{':
+		f.pos++
+		m := make(map[string]interface{})
+		for {
+			f.space()
+			if f.pos < len(f.text) && f.text[f.pos] == '}' && len(m) == 0 {
+				f.pos++
+				return m, nil
+			}
+			key, err := f.scalar(path, true)
+			if err != nil {
+				return nil, err
+			}
+			name, err := yamlKey(key)
+			if err != nil {
+				return nil, f.line.errorf(path, "%v", err)
+			}
+			if _, ok := m[name]; ok {
+				return nil, f.line.errorf(path+"."+name, "duplicate key")
+			}
+			f.space()
+			if f.pos == len(f.text) || f.text[f.pos] != ':' {
+				return nil, f.line.errorf(path+"."+name, "expected \":\"")
+			}
+			f.pos++
+			if m[name], err = f.value(path + "." + name); err != nil {
+				return nil, err
+			}
+			if done, err := f.separator(path, '}'); done || err != nil {
+				return m, err
+			}
+		}
+	case '&', '*', '!', '|', '>', '%', '@', '`':
+		return nil, f.line.errorf(path, "unsupported YAML %q", string(c))
+	}
+	s, err := f.scalar(path, false)
+	if err != nil {
+		return nil, err
+	}
+	if strings.HasPrefix(s, "\"") || strings.HasPrefix(s, "'") {
+		v, err := unquoteYAML(s)
+		if err != nil {
+			return nil, f.line.errorf(path, "%v", err)
+		}
+		return v, nil
+	}
+	return plainYAML(s), nil
+}
+
+// separator reads the comma between the items of a flow collection, or the closing bracket in
+// which case done is true
+func (f *yamlFlow) separator(path string, end byte) (done bool, err error) {
+	f.space()
+	if f.pos < len(f.text) {
+		switch f.text[f.pos] {
+		case ',':
+			f.pos++
+			return false, nil
+		case end:
+			f.pos++
+			return true, nil
+		}
+	}
+	return true, f.line.errorf(path, "expected \",\" or %q", string(end))
+}
+
+// scalar reads a quoted or plain scalar, a plain scalar ends at a flow indicator or at a colon
+// if it's a key
+func (f *yamlFlow) scalar(path string, key bool) (string, error) {
+	start := f.pos
+	if q := f.text[f.pos]; q == '"' || q == '\'' {
+		for f.pos++; f.pos < len(f.text); f.pos++ {
+			switch f.text[f.pos] {
+			case '\\':
+				if q == '"' {
+					f.pos++
+				}
+			case q:
+				if q == '\'' && f.pos+1 < len(f.text) && f.text[f.pos+1] == '\'' {
+					f.pos++
+					continue
+				}
+				f.pos++
+				return f.text[start:f.pos], nil
+			}
+		}
+		return "", f.line.errorf(path, "unterminated string")
+	}
+	for f.pos < len(f.text) && !strings.ContainsRune(",[]{}", rune(f.text[f.pos])) && !(key && f.text[f.pos] == ':') {
+		f.pos++
+	}
+	return strings.TrimSpace(f.text[start:f.pos]), nil
+}
+
+// unquoteYAML returns the string within single or double quotes
+func unquoteYAML(s string) (string, error) {
+	if len(s) < 2 || s[len(s)-1] != s[0] {
+		return "", fmt.Errorf("unterminated string")
+	}
+	if s[0] == '\'' {
+		return strings.Replace(s[1:len(s)-1], "''", "'", -1), nil
+	}
+	var v string
+	if err := json.Unmarshal([]byte(s), &v); err != nil {
+		return "", fmt.Errorf("invalid string %s", s)
+	}
+	return v, nil
+}
+
+// yamlNumber matches the plain scalars which are numbers
+var yamlNumber = regexp.MustCompile(`^[-+]?(\.[0-9]+|[0-9]+(\.[0-9]*)?)([eE][-+]?[0-9]+)?$`)
+
+// plainYAML returns the value of a plain scalar, which is a number, boolean, null or string
+func plainYAML(s string) interface{} {
+	switch s {
+	case "true", "True", "TRUE":
+		return true
+	case "false", "False", "FALSE":
+		return false
+	case "null", "Null", "NULL", "~", "":
+		return nil
+	}
+	if yamlNumber.MatchString(s) {
+		if v, err := strconv.ParseFloat(s, 64); err == nil {
+			return v
+		}
+	}
+	return s
+}